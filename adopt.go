@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// validateAdopt checks the panes that adopt a running process. The adopted process takes
+// over the pane's terminal, so there is no shell to type commands into.
+func validateAdopt(config *Config) error {
+	for _, window := range config.Session.Windows {
+		for _, pane := range window.Panes {
+			if pane.AdoptPID < 0 {
+				return fmt.Errorf("window %s: pane %s: adopt-pid must be a process ID", window.Name, pane.Name)
+			}
+			if pane.AdoptPID > 0 && (pane.Command != "" || len(pane.Commands) > 0 || len(pane.Send) > 0) {
+				return fmt.Errorf("window %s: pane %s: adopt-pid excludes command, commands and send", window.Name, pane.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// adoptProcess moves an already running process into a pane with reptyr, which has to run
+// in the terminal that takes the process over. The pane's shell gets its prompt back once
+// the process exits. Without reptyr, or when the process is gone, the pane keeps its shell.
+func (t *TMUX) adoptProcess(paneTarget string, pid int) error {
+	if !t.DryRun && t.Executor == nil {
+		if _, err := exec.LookPath("reptyr"); err != nil {
+			return fmt.Errorf("cannot adopt process %d: reptyr is not installed", pid)
+		}
+		out, err := exec.Command("ps", "-p", strconv.Itoa(pid), "-o", "comm=").Output()
+		if err != nil || strings.TrimSpace(string(out)) == "" {
+			return fmt.Errorf("cannot adopt process %d: no such process", pid)
+		}
+	}
+	_, err := t.run("send-keys", "-t", paneTarget, "reptyr "+strconv.Itoa(pid), "C-m")
+	return err
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// projectResult is the outcome of bringing up one project with --all
+type projectResult struct {
+	dir      string
+	err      error
+	output   string
+	duration time.Duration
+}
+
+// upAll brings up the sessions of all registered projects in parallel and detached, then
+// prints a summary. Each project runs in its own gridlock process, as provisioning changes
+// the working directory.
+func upAll(opts upOptions) {
+	var dirs []string
+	for _, dir := range readRegistry() {
+		if _, err := os.Stat(resolveConfigPath(filepath.Join(dir, ".gridlock.yaml"))); err == nil {
+			dirs = append(dirs, dir)
+		}
+	}
+	if len(dirs) == 0 {
+		log.Fatalf("No registered projects, open some with gridlock open first")
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		log.Fatalf("Failed to find gridlock executable: %v", err)
+	}
+	args := []string{"-d", "--timeout", opts.timeout.String(), "--retries", strconv.Itoa(opts.retries)}
+	if opts.socket != "" {
+		args = append(args, "--socket", opts.socket)
+	}
+	if opts.recreate {
+		args = append(args, "--recreate")
+	}
+	if opts.recreateIfChanged {
+		args = append(args, "--recreate-if-changed")
+	}
+	if opts.inPlace {
+		args = append(args, "--in-place")
+	}
+	if opts.renameExisting != "" {
+		args = append(args, "--rename-existing", opts.renameExisting)
+	}
+	if opts.noCommands {
+		args = append(args, "--no-commands")
+	}
+	if opts.controlMode {
+		args = append(args, "--control-mode")
+	}
+	if opts.dryRun {
+		args = append(args, "--dry-run")
+	}
+	if opts.wait {
+		args = append(args, "--wait", "--wait-timeout", opts.waitTimeout.String())
+	}
+	args = append(args, templateVars.args()...)
+
+	fmt.Printf("Bringing up %d projects\n", len(dirs))
+	results := make([]projectResult, len(dirs))
+	limit := make(chan struct{}, runtime.NumCPU())
+	var wg sync.WaitGroup
+	for i, dir := range dirs {
+		wg.Add(1)
+		go func(i int, dir string) {
+			defer wg.Done()
+			limit <- struct{}{}
+			defer func() { <-limit }()
+
+			start := time.Now()
+			var output bytes.Buffer
+			cmd := exec.Command(executable, args...)
+			cmd.Dir = dir
+			cmd.Stdout = &output
+			cmd.Stderr = &output
+			err := cmd.Run()
+			results[i] = projectResult{dir: dir, err: err, output: output.String(), duration: time.Since(start)}
+		}(i, dir)
+	}
+	wg.Wait()
+
+	failed := 0
+	out := newOutput()
+	t := out.table("")
+	for _, result := range results {
+		state := out.style(styleGreen, "ok")
+		detail := ""
+		if result.err != nil {
+			failed++
+			state = out.style(styleRed, "failed")
+			detail = lastLine(result.output)
+			if detail == "" {
+				detail = result.err.Error()
+			}
+		}
+		t.row(result.dir, state, result.duration.Round(time.Millisecond).String(), detail)
+	}
+	t.flush()
+
+	if failed > 0 {
+		out.printf("%d of %d projects failed\n", failed, len(results))
+		os.Exit(1)
+	}
+}
+
+func lastLine(output string) string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/esaiaswestberg/gridlock/pkg/tmux"
+)
+
+// Attaching right after new-session can fail while the server is still starting up, mostly
+// on slow machines. Such failures are retried this many times with a growing backoff.
+const (
+	attachAttempts = 5
+	attachBackoff  = 100 * time.Millisecond
+	// Attempts that fail after running this long were attached, so they are not retried
+	attachStartup = time.Second
+)
+
+// Output fragments of attach failures that happen while the server is starting up, on top
+// of those tmux.IsTransient knows
+var attachStartupErrors = []string{
+	"no server running",
+	"can't find session",
+	"no sessions",
+	"error connecting to",
+}
+
+func attachRetryable(output string) bool {
+	if tmux.IsTransient(output) {
+		return true
+	}
+	for _, fragment := range attachStartupErrors {
+		if strings.Contains(output, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+// retryAttach runs attach until it succeeds, retrying failures that look like the server is
+// still starting up. attach returns the output of tmux along with its error. After the last
+// attempt the error names the session, the server and what tmux said.
+func (t *TMUX) retryAttach(sessionName string, attach func() (string, error)) error {
+	backoff := attachBackoff
+	var out string
+	var err error
+	attempt := 1
+	for ; ; attempt++ {
+		start := time.Now()
+		out, err = attach()
+		if err == nil {
+			return nil
+		}
+		if attempt == attachAttempts || time.Since(start) > attachStartup || !attachRetryable(out) {
+			break
+		}
+		if t.Verbose {
+			fmt.Printf("Attaching to session %s failed, retrying in %s: %s\n", sessionName, backoff, strings.TrimSpace(out))
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+
+	server := "the default tmux server"
+	if t.Socket != "" {
+		server = fmt.Sprintf("tmux server %s", t.Socket)
+	}
+	message := fmt.Sprintf("failed to attach to session %s on %s", sessionName, server)
+	if attempt > 1 {
+		message += fmt.Sprintf(" after %d attempts", attempt)
+	}
+	message += fmt.Sprintf(": %v", err)
+	if out = strings.TrimSpace(out); out != "" && !strings.Contains(err.Error(), out) {
+		message += "\nOutput: " + out
+	}
+	return fmt.Errorf("%s", message)
+}
+
+// attachClient runs tmux attach-session in the terminal gridlock runs in, retrying while the
+// server is starting up
+func (t *TMUX) attachClient(sessionName string, args ...string) error {
+	return t.retryAttach(sessionName, func() (string, error) {
+		var stderr bytes.Buffer
+		cmd := exec.Command("tmux", t.Args(args...)...)
+		cmd.Stdin = os.Stdin
+		cmd.Stdout = os.Stdout
+		// tmux only reports failures on stderr, which are kept to tell startup failures
+		// apart from others
+		cmd.Stderr = &stderr
+		err := cmd.Run()
+		return stderr.String(), err
+	})
+}
+
+// switchClient switches the tmux client gridlock runs in to the target session, retrying
+// while the server is starting up
+func (t *TMUX) switchClient(sessionName string, target string) error {
+	return t.retryAttach(sessionName, func() (string, error) {
+		out, err := t.run("switch-client", "-t", target)
+		if err != nil {
+			// The error of run already carries the output of tmux
+			return err.Error(), err
+		}
+		return out, nil
+	})
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Terminal multiplexers a session can be brought up in, as named by backend
+const (
+	backendTMUX   = "tmux"
+	backendZellij = "zellij"
+	backendScreen = "screen"
+)
+
+// multiplexer is a terminal multiplexer gridlock brings the session of a configuration up
+// in. TMUX is one, and up uses what only tmux can do, such as recreating or syncing a running
+// session, on top of it; zellij and screen only create a session and attach to it.
+type multiplexer interface {
+	// sessionExists reports whether a session of the name is running
+	sessionExists(name string) bool
+	// create creates the session of the configuration in the background, with the chosen
+	// layout of every window
+	create(config *Config, layouts []LayoutNode) error
+	// attach attaches the terminal to a running session
+	attach(name string) error
+}
+
+// backendName returns the multiplexer selected by the --backend flag or the configuration
+func backendName(opts upOptions, config *Config) (string, error) {
+	name := firstNonEmpty(opts.backend, config.Session.Backend, backendTMUX)
+	switch name {
+	case backendTMUX, backendZellij, backendScreen:
+		return name, nil
+	}
+	return "", fmt.Errorf("unknown backend %q, expected tmux, zellij or screen", name)
+}
+
+// newMultiplexer returns the multiplexer of a backend
+func newMultiplexer(name string, opts upOptions, config *Config) multiplexer {
+	switch name {
+	case backendTMUX:
+		return newTMUX(opts, config)
+	case backendZellij:
+		return &zellij{dryRun: opts.dryRun, verbose: opts.verbose}
+	case backendScreen:
+		return &screen{dryRun: opts.dryRun, verbose: opts.verbose}
+	}
+	return nil
+}
+
+// upBackend creates (or attaches to) the session of the configuration in a multiplexer, as
+// up does for zellij and screen. The session's before and after hooks run around creating it,
+// and its on-attach hooks before attaching.
+func upBackend(m multiplexer, config *Config, opts upOptions) {
+	sessionName := config.Session.Name
+	// Hooks only run commands, so a TMUX that never reaches tmux runs them
+	t := newTMUX(opts, config)
+
+	if !m.sessionExists(sessionName) {
+		width, height, sizeKnown := t.clientSize(false)
+		if !sizeKnown {
+			width, height = defaultWindowWidth, defaultWindowHeight
+		}
+		layouts, err := chooseLayouts(&config.Session, width, height, sizeKnown && isSmallClient(&config.Session, width, height))
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		if err := t.runSessionHook(hookBefore, &config.Session, sessionName); err != nil {
+			log.Fatalf("%v", err)
+		}
+		if err := m.create(config, layouts); err != nil {
+			log.Fatalf("Failed to create session %s: %v", sessionName, err)
+		}
+		if err := t.runSessionHook(hookAfter, &config.Session, sessionName); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	} else {
+		fmt.Printf("Session already exists: %s\n", sessionName)
+	}
+	if opts.detached {
+		return
+	}
+	if err := t.runSessionHook(hookOnAttach, &config.Session, sessionName); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	fmt.Printf("Attaching to session: %s\n", sessionName)
+	if err := m.attach(sessionName); err != nil {
+		log.Fatalf("failed to attach to session: %v", err)
+	}
+}
+
+// paneScript returns the sh script setting up a pane in a multiplexer other than tmux: it
+// exports the pane's env, runs its commands and ends in its shell, so the pane stays open.
+// It is "" when the pane only needs the default shell.
+func paneScript(pane *PaneConfig) string {
+	// A script cannot type a command without running it, so every command is run
+	commands := pane.Commands.Strings()
+	if pane.Command != "" {
+		commands = append([]string{pane.Command}, commands...)
+	}
+	if len(commands) == 0 && len(pane.Env) == 0 && pane.Shell == "" {
+		return ""
+	}
+	var lines []string
+	for _, k := range envKeys(pane.Env) {
+		lines = append(lines, "export "+k+"="+shellQuote(pane.Env[k]))
+	}
+	lines = append(lines, commands...)
+	lines = append(lines, "exec "+firstNonEmpty(pane.Shell, "\"${SHELL:-sh}\""))
+	return strings.Join(lines, "\n")
+}
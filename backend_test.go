@@ -0,0 +1,31 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestUpBackendCreatesTMUXSession(t *testing.T) {
+	t.Setenv("TMUX", "")
+	fake := &fakeTMUX{}
+	opts := upOptions{detached: true, executor: fake.exec}
+	config := &Config{Session: SessionConfig{Name: "dev", Windows: []WindowConfig{
+		{Name: "code", Panes: []PaneConfig{{Name: "vim", Command: "vim"}, {Name: "shell"}}, Layout: LayoutNode{Columns: []LayoutNode{{PaneName: "vim"}, {PaneName: "shell"}}}},
+		{Name: "logs", Panes: []PaneConfig{{Name: "tail"}}, Layout: LayoutNode{PaneName: "tail"}},
+	}}}
+
+	upBackend(newMultiplexer(backendTMUX, opts, config), config, opts)
+
+	for _, want := range []string{
+		"tmux has-session -t dev",
+		"tmux new-session -d -s dev -n code -P -F #{window_id}",
+		"tmux set-option -p -t dev:code.0 @gridlock-pane vim",
+		"tmux send-keys -t dev:code.0 vim C-m",
+		"tmux new-window -d -P -F #{window_id} -t dev: -n logs",
+	} {
+		if !slices.Contains(fake.commands, want) {
+			t.Errorf("commands do not contain %q:\n%s", want, strings.Join(fake.commands, "\n"))
+		}
+	}
+}
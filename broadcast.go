@@ -0,0 +1,136 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+)
+
+// broadcastTarget is a live pane a broadcast command is sent to
+type broadcastTarget struct {
+	id     string
+	window string
+	pane   string
+}
+
+func (b broadcastTarget) String() string {
+	if b.pane == "" {
+		return fmt.Sprintf("%s:%s", b.window, b.id)
+	}
+	return fmt.Sprintf("%s:%s", b.window, b.pane)
+}
+
+// broadcastCommand sends a command to every pane of the session, or to the panes of some
+// windows or with some tags, after listing them and asking for confirmation
+func broadcastCommand(fs *flag.FlagSet) func(args []string, opts upOptions) {
+	windows := fs.String("window", "", "Only send to the panes of these windows, comma separated")
+	tags := fs.String("tag", "", "Only send to the panes with one of these tags, comma separated")
+	yes := fs.Bool("yes", false, "Do not ask for confirmation")
+	return func(args []string, opts upOptions) {
+		if len(args) == 0 {
+			log.Fatalf("Usage: gridlock broadcast [--window names] [--tag tags] -- <command>")
+		}
+		command := strings.Join(args, " ")
+
+		config, err := loadConfig(opts.configFile)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		if err := prepareConfig(config); err != nil {
+			log.Fatalf("invalid config: %v", err)
+		}
+		sessionName := config.Session.Name
+
+		t := newTMUX(opts, config)
+		query := newTMUX(opts, config)
+		query.DryRun = false
+		if !query.sessionExists(sessionName) {
+			log.Fatalf("Session %s is not running", sessionName)
+		}
+		targets, err := query.broadcastTargets(config, splitList(*windows), splitList(*tags))
+		if err != nil {
+			log.Fatalf("Failed to list panes: %v", err)
+		}
+		if len(targets) == 0 {
+			fmt.Printf("No panes in session %s match\n", sessionName)
+			return
+		}
+
+		fmt.Printf("Panes in session %s:\n", sessionName)
+		for _, target := range targets {
+			fmt.Printf("  %s\n", target)
+		}
+		if !*yes && !opts.dryRun && !confirm(fmt.Sprintf("Send %q to %d pane(s)?", command, len(targets))) {
+			fmt.Println("Aborted")
+			return
+		}
+		for _, target := range targets {
+			if _, err := t.run("send-keys", "-t", target.id, command, "C-m"); err != nil {
+				log.Printf("Warning: failed to send to %s: %v", target, err)
+			}
+		}
+	}
+}
+
+// broadcastTargets lists the live panes of the session matching the window and tag filters.
+// Panes are matched to the configuration by the names gridlock recorded on them, so tags
+// only select panes gridlock created. The pane gridlock runs in is left out.
+func (t *TMUX) broadcastTargets(config *Config, windows []string, tags []string) ([]broadcastTarget, error) {
+	out, err := t.run("list-panes", "-s", "-t", config.Session.Name, "-F",
+		"#{pane_id}\t#{window_name}\t#{"+metadataWindowName+"}\t#{"+metadataPaneName+"}")
+	if err != nil {
+		return nil, err
+	}
+	currentPane := ""
+	if os.Getenv("TMUX") != "" {
+		currentPane = os.Getenv("TMUX_PANE")
+	}
+	var targets []broadcastTarget
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		parts := strings.SplitN(line, "\t", 4)
+		if len(parts) < 4 || parts[0] == currentPane {
+			continue
+		}
+		target := broadcastTarget{id: parts[0], window: firstNonEmpty(parts[2], parts[1]), pane: parts[3]}
+		if len(windows) > 0 && !slices.Contains(windows, target.window) {
+			continue
+		}
+		if len(tags) > 0 && !paneHasTag(config, target, tags) {
+			continue
+		}
+		targets = append(targets, target)
+	}
+	return targets, nil
+}
+
+// paneHasTag reports whether the configured pane of a live pane has one of the tags
+func paneHasTag(config *Config, target broadcastTarget, tags []string) bool {
+	window := findWindow(config.Session.Windows, target.window)
+	if window == nil || target.pane == "" {
+		return false
+	}
+	pane := findPane(window, target.pane)
+	if pane == nil {
+		return false
+	}
+	for _, tag := range pane.Tags {
+		if slices.Contains(tags, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitList splits a comma separated flag value, dropping empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// detectClipboardCommand picks the command that copies stdin to the system clipboard on this machine
+func detectClipboardCommand() string {
+	has := func(name string) bool {
+		_, err := exec.LookPath(name)
+		return err == nil
+	}
+
+	switch runtime.GOOS {
+	case "darwin":
+		return "pbcopy"
+	case "windows":
+		return "clip.exe"
+	}
+
+	switch {
+	case os.Getenv("WAYLAND_DISPLAY") != "" && has("wl-copy"):
+		return "wl-copy"
+	case os.Getenv("DISPLAY") != "" && has("xclip"):
+		return "xclip -selection clipboard -in"
+	case os.Getenv("DISPLAY") != "" && has("xsel"):
+		return "xsel --clipboard --input"
+	case has("clip.exe"):
+		// WSL
+		return "clip.exe"
+	}
+	return ""
+}
+
+// setupClipboard configures tmux clipboard integration. The setting is "auto" (or "true")
+// to detect the copy command for this OS, or an explicit copy command.
+// Both options are server-wide in tmux, so this affects every session on the server.
+func (t *TMUX) setupClipboard(setting string) {
+	setting = strings.TrimSpace(setting)
+	if setting == "" || setting == "false" || setting == "off" {
+		return
+	}
+
+	command := setting
+	if setting == "auto" || setting == "true" || setting == "on" {
+		command = detectClipboardCommand()
+	}
+
+	t.run("set-option", "-s", "set-clipboard", "on")
+	if command == "" {
+		fmt.Println("No clipboard command found (install wl-clipboard, xclip or xsel), relying on OSC 52 only")
+		return
+	}
+	fmt.Printf("Configuring clipboard: %s\n", command)
+	t.run("set-option", "-s", "copy-command", command)
+}
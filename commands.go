@@ -0,0 +1,176 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// command describes a subcommand of gridlock. setup defines the subcommand's flags on fs
+// and returns the function that runs it with the remaining positional arguments
+type command struct {
+	name    string
+	args    string
+	summary string
+	setup   func(fs *flag.FlagSet) func(args []string, opts upOptions)
+}
+
+// shorthands maps the global flags to their single letter shorthands
+var shorthands = map[string]string{
+	"config":   "f",
+	"detached": "d",
+	"current":  "c",
+	"verbose":  "v",
+	"socket":   "L",
+}
+
+// commandList returns the subcommands of gridlock in the order they are documented
+func commandList() []command {
+	return []command{
+		{"up", "", "Create or attach to the session of the configuration (default)", upCommand},
+		{"run-commands", "[window...]", "Run the configured commands in a session created with --no-commands", runCommandsCommand},
+		{"broadcast", "[--window names] [--tag tags] -- <command>", "Send a command to the panes of the session after confirmation", broadcastCommand},
+		{"init", "", "Write an example configuration to the configuration file", initCommand},
+		{"new", "<project>", "Create the configuration of a named project in ~/.config/gridlock/projects", newCommand},
+		{"convert", "<file> [-o file]", "Convert a tmuxinator or tmuxp configuration to a gridlock one", convertCommand},
+		{"edit", "[project] [--apply]", "Edit the configuration of a project or the local one in $EDITOR and validate it", editCommand},
+		{"open", "[query]", "Find or initialize a project's configuration and bring its session up", openCommand},
+		{"pick", "", "Pick a running session or a project with a fuzzy finder and bring it up", pickCommand},
+		{"start", "[project]", "Bring up the session of a named project from any directory, or list them", startCommand},
+		{"status", "", "Print the live state of the configured session", statusCommand},
+		{"logs", "[window/]pane [--follow] [-n lines]", "Print the output of a pane from its log or scrollback, without attaching", logsCommand},
+		{"diff", "", "Print the differences between the configuration and the live session", diffCommand},
+		{"apply", "[--prune]", "Bring the running session in line with the configuration, creating missing windows", applyCommand},
+		{"validate", "", "Check the configuration, or compare it with another one using --against", validateCommand},
+		{"stats", "", "Summarize how often the windows and panes of the session were selected", statsCommand},
+		{"test", "", "Compare the tmux commands of the configuration with a golden file", testCommand},
+		{"export", "script|zellij [-o file]", "Write the session as a shell script that needs only tmux, or as a zellij layout", exportCommand},
+		{"snapshot", "[session] [--push | --pull]", "Capture a running session into the snapshots directory and sync it with a remote", snapshotCommand},
+		{"monitor", "", "Collapse the collapsible panes of the session while idle (started by up)", monitorCommand},
+		{"schedule", "", "Run the scheduled commands of the session's panes when they are due (started by up)", scheduleCommand},
+		{"history", "", "List the recorded runs of gridlock up for the session", historyCommand},
+		{"list", "", "List the sessions of the tmux server and the configurations they were created from", listCommand},
+		{"projects", "", "List known projects and whether their sessions are running", projectsCommand},
+		{"restart", "[--window name]", "Kill and recreate the session, or only one of its windows, running their hooks", restartCommand},
+		{"prune-windows", "", "Kill live windows that are no longer in the configuration", pruneWindowsCommand},
+		{"kill", "", "Run the on-kill hooks of the session and kill it", killCommand},
+		{"tmux", "<command> [args...]", "Run a raw tmux command against the configured server and session", tmuxCommand},
+		{"encrypt", "[file]", "Encrypt a configuration file with age or gpg", encryptCommand},
+		{"decrypt", "[file]", "Write the plaintext of an encrypted configuration file", decryptCommand},
+		{"hook", "bash|zsh|fish | install <shell> | allow|deny [dir]", "Print or install the shell hook bringing sessions up on entering their directory", hookCommand},
+		{"gen", "docs|completions [args...]", "Generate the man page or shell completions", genCommand},
+	}
+}
+
+// findCommand returns the subcommand with the given name
+func findCommand(name string) (command, bool) {
+	for _, cmd := range commandList() {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+	return command{}, false
+}
+
+// upCommand creates or attaches to the session. Its flags are the global ones
+func upCommand(fs *flag.FlagSet) func(args []string, opts upOptions) {
+	return func(args []string, opts upOptions) {
+		up(opts)
+	}
+}
+
+// commandFlags returns the flags of a subcommand, without running it
+func commandFlags(cmd command) *flag.FlagSet {
+	fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	cmd.setup(fs)
+	return fs
+}
+
+// globalFlags returns the global flags, leaving out the shorthand aliases
+func globalFlags() []*flag.Flag {
+	isShorthand := map[string]bool{}
+	for _, short := range shorthands {
+		isShorthand[short] = true
+	}
+	var flags []*flag.Flag
+	flag.CommandLine.VisitAll(func(f *flag.Flag) {
+		if !isShorthand[f.Name] {
+			flags = append(flags, f)
+		}
+	})
+	return flags
+}
+
+// flagType returns the name of a flag's value type, or "" for booleans
+func flagType(f *flag.Flag) string {
+	if b, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && b.IsBoolFlag() {
+		return ""
+	}
+	name, _ := flag.UnquoteUsage(f)
+	return name
+}
+
+// printFlags writes the usage of flags in the format of the flag package
+func printFlags(w io.Writer, flags []*flag.Flag) {
+	for _, f := range flags {
+		line := "  --" + f.Name
+		if short, ok := shorthands[f.Name]; ok && flag.CommandLine.Lookup(f.Name) == f {
+			line += ", -" + short
+		}
+		if typ := flagType(f); typ != "" {
+			line += " " + typ
+		}
+		_, usage := flag.UnquoteUsage(f)
+		line += "\n        " + usage
+		switch {
+		case f.DefValue == "" || f.DefValue == "false" || f.DefValue == "0":
+		case flagType(f) == "string":
+			line += fmt.Sprintf(" (default %q)", f.DefValue)
+		default:
+			line += fmt.Sprintf(" (default %s)", f.DefValue)
+		}
+		fmt.Fprintln(w, line)
+	}
+}
+
+// envName returns the environment variable that sets a flag, e.g. GRIDLOCK_DRY_RUN for --dry-run
+func envName(flagName string) string {
+	return "GRIDLOCK_" + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// applyEnv sets the flags whose environment variable is set. It runs before the command
+// line is parsed, so flags given there take precedence over the environment, which in
+// turn takes precedence over the configuration file.
+func applyEnv(fs *flag.FlagSet) error {
+	isShorthand := map[string]bool{}
+	for _, short := range shorthands {
+		isShorthand[short] = true
+	}
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if err != nil || (fs == flag.CommandLine && isShorthand[f.Name]) {
+			return
+		}
+		value, ok := os.LookupEnv(envName(f.Name))
+		if !ok || value == "" {
+			return
+		}
+		if setErr := f.Value.Set(value); setErr != nil {
+			err = fmt.Errorf("invalid value %q for %s: %v", value, envName(f.Name), setErr)
+		}
+	})
+	return err
+}
+
+// usage prints the global flags and the subcommands
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage of %s:\n", os.Args[0])
+	printFlags(os.Stderr, globalFlags())
+	fmt.Fprintf(os.Stderr, "\nCommands:\n")
+	for _, cmd := range commandList() {
+		fmt.Fprintf(os.Stderr, "  %-14s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintf(os.Stderr, "\nEvery flag can also be set with an environment variable, e.g. %s=1 for --dry-run.\n", envName("dry-run"))
+}
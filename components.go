@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+var componentParam = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_-]+)\s*\}\}`)
+
+// loadComponentFiles adds the components of the files listed in components-from to the
+// configuration. Components defined in the configuration itself take precedence.
+func loadComponentFiles(config *Config, path string) error {
+	for _, file := range config.ComponentFiles {
+		file = expandPath(file)
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(filepath.Dir(path), file)
+		}
+		data, err := readConfigFile(file)
+		if err != nil {
+			return fmt.Errorf("failed to read components: %v", err)
+		}
+		var library Config
+		if err := yaml.Unmarshal(data, &library); err != nil {
+			return fmt.Errorf("failed to parse components %s: %v", file, err)
+		}
+		for name, component := range library.Components {
+			if _, ok := config.Components[name]; ok {
+				continue
+			}
+			if config.Components == nil {
+				config.Components = make(map[string]Component)
+			}
+			config.Components[name] = component
+		}
+	}
+	return nil
+}
+
+// instantiateComponent decodes the pane or window definition of a component into out, with
+// its parameters replaced by the values of with
+func instantiateComponent(c Component, definition yaml.Node, with map[string]string, out interface{}) error {
+	values := make(map[string]string)
+	for name, value := range c.Params {
+		values[name] = value
+	}
+	for name, value := range with {
+		if _, ok := c.Params[name]; !ok {
+			return fmt.Errorf("unknown parameter %s", name)
+		}
+		values[name] = value
+	}
+
+	var missing []string
+	node := substituteParams(definition, values, &missing)
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("missing parameters %s", strings.Join(missing, ", "))
+	}
+	return node.Decode(out)
+}
+
+// substituteParams returns a copy of a YAML node with the parameters in its scalars replaced
+func substituteParams(node yaml.Node, values map[string]string, missing *[]string) yaml.Node {
+	if node.Kind == yaml.ScalarNode {
+		node.Value = componentParam.ReplaceAllStringFunc(node.Value, func(ref string) string {
+			name := componentParam.FindStringSubmatch(ref)[1]
+			value, ok := values[name]
+			if (!ok || value == "") && !slices.Contains(*missing, name) {
+				*missing = append(*missing, name)
+			}
+			return value
+		})
+		return node
+	}
+	content := make([]*yaml.Node, len(node.Content))
+	for i, child := range node.Content {
+		substituted := substituteParams(*child, values, missing)
+		content[i] = &substituted
+	}
+	node.Content = content
+	return node
+}
+
+// overrideFields sets the fields given in the using entry on the instantiated component
+func overrideFields(entry interface{}, instance interface{}) error {
+	data, err := yaml.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return yaml.Unmarshal(data, instance)
+}
+
+// expandComponents replaces the windows and panes that use a component by its instance
+func expandComponents(config *Config) error {
+	for i := range config.Session.Windows {
+		window := &config.Session.Windows[i]
+		if window.Use != "" {
+			component, ok := config.Components[window.Use]
+			if !ok || component.Window.IsZero() {
+				return fmt.Errorf("window %s: no window component %s", window.Name, window.Use)
+			}
+			var instance WindowConfig
+			if err := instantiateComponent(component, component.Window, window.With, &instance); err != nil {
+				return fmt.Errorf("window %s: component %s: %v", window.Name, window.Use, err)
+			}
+			entry := *window
+			entry.Use, entry.With = "", nil
+			if err := overrideFields(entry, &instance); err != nil {
+				return fmt.Errorf("window %s: %v", window.Name, err)
+			}
+			if instance.Use != "" {
+				return fmt.Errorf("window %s: component %s cannot use another component", window.Name, window.Use)
+			}
+			*window = instance
+		}
+
+		for j := range window.Panes {
+			pane := &window.Panes[j]
+			if pane.Use == "" {
+				continue
+			}
+			component, ok := config.Components[pane.Use]
+			if !ok || component.Pane.IsZero() {
+				return fmt.Errorf("window %s: pane %s: no pane component %s", window.Name, pane.Name, pane.Use)
+			}
+			var instance PaneConfig
+			if err := instantiateComponent(component, component.Pane, pane.With, &instance); err != nil {
+				return fmt.Errorf("window %s: pane %s: component %s: %v", window.Name, pane.Name, pane.Use, err)
+			}
+			entry := *pane
+			entry.Use, entry.With = "", nil
+			if err := overrideFields(entry, &instance); err != nil {
+				return fmt.Errorf("window %s: pane %s: %v", window.Name, pane.Name, err)
+			}
+			if instance.Use != "" {
+				return fmt.Errorf("window %s: pane %s: component %s cannot use another component", window.Name, pane.Name, pane.Use)
+			}
+			*pane = instance
+		}
+	}
+	return nil
+}
@@ -0,0 +1,325 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/esaiaswestberg/gridlock/pkg/capture"
+	"gopkg.in/yaml.v3"
+)
+
+// tmuxinatorConfig is a tmuxinator project file. Each window is a single-key mapping from
+// its name to a command, or to a mapping with root, layout, pre and panes.
+type tmuxinatorConfig struct {
+	Name           string                 `yaml:"name"`
+	ProjectName    string                 `yaml:"project_name"`
+	Root           string                 `yaml:"root"`
+	ProjectRoot    string                 `yaml:"project_root"`
+	SocketName     string                 `yaml:"socket_name"`
+	OnProjectStart commandLines           `yaml:"on_project_start"`
+	OnProjectStop  commandLines           `yaml:"on_project_stop"`
+	PreWindow      commandLines           `yaml:"pre_window"`
+	Windows        []map[string]yaml.Node `yaml:"windows"`
+}
+
+type tmuxinatorWindow struct {
+	Root   string       `yaml:"root"`
+	Layout string       `yaml:"layout"`
+	Pre    commandLines `yaml:"pre"`
+	Panes  []yaml.Node  `yaml:"panes"`
+}
+
+// tmuxpConfig is a tmuxp session file, in YAML or JSON
+type tmuxpConfig struct {
+	SessionName        string            `yaml:"session_name"`
+	StartDirectory     string            `yaml:"start_directory"`
+	BeforeScript       string            `yaml:"before_script"`
+	ShellCommandBefore commandLines      `yaml:"shell_command_before"`
+	Environment        map[string]string `yaml:"environment"`
+	Windows            []tmuxpWindow     `yaml:"windows"`
+}
+
+type tmuxpWindow struct {
+	WindowName         string            `yaml:"window_name"`
+	Layout             string            `yaml:"layout"`
+	StartDirectory     string            `yaml:"start_directory"`
+	ShellCommandBefore commandLines      `yaml:"shell_command_before"`
+	Environment        map[string]string `yaml:"environment"`
+	Panes              []yaml.Node       `yaml:"panes"`
+}
+
+type tmuxpPane struct {
+	ShellCommand       commandLines      `yaml:"shell_command"`
+	ShellCommandBefore commandLines      `yaml:"shell_command_before"`
+	StartDirectory     string            `yaml:"start_directory"`
+	Environment        map[string]string `yaml:"environment"`
+}
+
+// commandLines are the commands of a tmuxinator or tmuxp file, a list or a block scalar like
+// the commands of a gridlock pane
+type commandLines []string
+
+func (c *commandLines) UnmarshalYAML(value *yaml.Node) error {
+	var commands Commands
+	if err := value.Decode(&commands); err != nil {
+		return err
+	}
+	*c = commands.Strings()
+	return nil
+}
+
+// convertConfig maps a tmuxinator or tmuxp file onto a gridlock configuration. tmuxp files
+// are told apart by their session_name.
+func convertConfig(data []byte) (*Config, error) {
+	var probe map[string]yaml.Node
+	if err := yaml.Unmarshal(data, &probe); err != nil {
+		return nil, fmt.Errorf("failed to parse: %v", err)
+	}
+	if _, ok := probe["session_name"]; ok {
+		var tmuxp tmuxpConfig
+		if err := yaml.Unmarshal(data, &tmuxp); err != nil {
+			return nil, fmt.Errorf("failed to parse tmuxp config: %v", err)
+		}
+		return convertTmuxp(&tmuxp)
+	}
+	if _, ok := probe["windows"]; !ok {
+		return nil, fmt.Errorf("neither a tmuxinator nor a tmuxp config")
+	}
+	var tmuxinator tmuxinatorConfig
+	if err := yaml.Unmarshal(data, &tmuxinator); err != nil {
+		return nil, fmt.Errorf("failed to parse tmuxinator config: %v", err)
+	}
+	return convertTmuxinator(&tmuxinator)
+}
+
+func convertTmuxinator(in *tmuxinatorConfig) (*Config, error) {
+	session := SessionConfig{
+		Name:             firstNonEmpty(in.Name, in.ProjectName),
+		WorkingDirectory: firstNonEmpty(in.Root, in.ProjectRoot),
+		Socket:           in.SocketName,
+		Hooks:            Hooks{Before: in.OnProjectStart, OnKill: in.OnProjectStop},
+	}
+	for _, entry := range in.Windows {
+		for name, value := range entry {
+			window := WindowConfig{Name: name}
+			var panes [][]string
+			var layout string
+			switch value.Kind {
+			case yaml.MappingNode:
+				var w tmuxinatorWindow
+				if err := value.Decode(&w); err != nil {
+					return nil, fmt.Errorf("window %s: %v", name, err)
+				}
+				window.WorkingDirectory = w.Root
+				layout = w.Layout
+				for _, paneNode := range w.Panes {
+					commands, err := tmuxinatorPane(&paneNode)
+					if err != nil {
+						return nil, fmt.Errorf("window %s: %v", name, err)
+					}
+					panes = append(panes, append(append(commandLines{}, w.Pre...), commands...))
+				}
+				if len(w.Panes) == 0 {
+					panes = append(panes, w.Pre)
+				}
+			default:
+				commands, err := tmuxinatorPane(&value)
+				if err != nil {
+					return nil, fmt.Errorf("window %s: %v", name, err)
+				}
+				panes = append(panes, commands)
+			}
+			for i := range panes {
+				panes[i] = append(append([]string{}, in.PreWindow...), panes[i]...)
+			}
+			if err := setConvertedPanes(&window, panes, layout, nil); err != nil {
+				return nil, err
+			}
+			session.Windows = append(session.Windows, window)
+		}
+	}
+	return &Config{Session: session}, nil
+}
+
+// tmuxinatorPane returns the commands of a tmuxinator pane: a command, a list of commands,
+// or a mapping from a pane title to either
+func tmuxinatorPane(node *yaml.Node) ([]string, error) {
+	if node.Kind == yaml.MappingNode && len(node.Content) == 2 {
+		node = node.Content[1]
+	}
+	if node.Kind == 0 || node.Tag == "!!null" {
+		return nil, nil
+	}
+	var commands commandLines
+	if err := node.Decode(&commands); err != nil {
+		return nil, fmt.Errorf("unsupported pane at line %d: %v", node.Line, err)
+	}
+	return commands, nil
+}
+
+func convertTmuxp(in *tmuxpConfig) (*Config, error) {
+	session := SessionConfig{
+		Name:             in.SessionName,
+		WorkingDirectory: in.StartDirectory,
+		Env:              in.Environment,
+	}
+	if in.BeforeScript != "" {
+		session.Hooks.Before = []string{in.BeforeScript}
+	}
+	for _, w := range in.Windows {
+		window := WindowConfig{Name: w.WindowName, WorkingDirectory: w.StartDirectory, Env: w.Environment}
+		var panes [][]string
+		var dirs []string
+		var envs []map[string]string
+		for _, paneNode := range w.Panes {
+			var pane tmuxpPane
+			switch {
+			case paneNode.Kind == yaml.MappingNode:
+				if err := paneNode.Decode(&pane); err != nil {
+					return nil, fmt.Errorf("window %s: %v", w.WindowName, err)
+				}
+			case paneNode.Tag == "!!null" || paneNode.Value == "blank" || paneNode.Value == "pane":
+			default:
+				if err := paneNode.Decode(&pane.ShellCommand); err != nil {
+					return nil, fmt.Errorf("window %s: unsupported pane at line %d: %v", w.WindowName, paneNode.Line, err)
+				}
+			}
+			commands := append(append(append([]string{}, in.ShellCommandBefore...), w.ShellCommandBefore...), pane.ShellCommandBefore...)
+			panes = append(panes, append(commands, pane.ShellCommand...))
+			dirs = append(dirs, pane.StartDirectory)
+			envs = append(envs, pane.Environment)
+		}
+		if len(panes) == 0 {
+			panes = append(panes, append(append([]string{}, in.ShellCommandBefore...), w.ShellCommandBefore...))
+			dirs = append(dirs, "")
+		}
+		if err := setConvertedPanes(&window, panes, w.Layout, dirs); err != nil {
+			return nil, err
+		}
+		for i, env := range envs {
+			window.Panes[i].Env = env
+		}
+		session.Windows = append(session.Windows, window)
+	}
+	return &Config{Session: session}, nil
+}
+
+// setConvertedPanes adds panes with the given commands and directories to a window, named
+// by index as gridlock init does, and lays them out like the tmux layout
+func setConvertedPanes(window *WindowConfig, panes [][]string, layout string, dirs []string) error {
+	var names []string
+	for i, commands := range panes {
+		pane := PaneConfig{Name: fmt.Sprintf("%s-pane-%d", window.Name, i)}
+		if len(commands) == 1 {
+			pane.Command = commands[0]
+		} else {
+			for _, command := range commands {
+				pane.Commands = append(pane.Commands, CommandEntry{Run: command})
+			}
+		}
+		if i < len(dirs) {
+			pane.WorkingDirectory = dirs[i]
+		}
+		window.Panes = append(window.Panes, pane)
+		names = append(names, pane.Name)
+	}
+
+	leaves := func(names []string) []LayoutNode {
+		nodes := make([]LayoutNode, len(names))
+		for i, name := range names {
+			nodes[i] = LayoutNode{PaneName: name}
+		}
+		return nodes
+	}
+	if len(names) == 1 {
+		window.Layout = LayoutNode{PaneName: names[0]}
+		return nil
+	}
+	switch layout {
+	case "even-horizontal":
+		window.Layout = LayoutNode{Columns: leaves(names)}
+	case "even-vertical":
+		window.Layout = LayoutNode{Rows: leaves(names)}
+	case "main-vertical":
+		window.Layout = LayoutNode{Columns: []LayoutNode{{PaneName: names[0]}, stack(leaves(names[1:]), false)}}
+	case "main-horizontal":
+		window.Layout = LayoutNode{Rows: []LayoutNode{{PaneName: names[0]}, stack(leaves(names[1:]), true)}}
+	case "", "tiled":
+		window.Grid = autoGrid(len(names))
+	default:
+		// A layout string of tmux list-windows, whose panes are numbered in the order they appear
+		node, err := capture.ParseLayout(layout, map[int]string{})
+		if err != nil {
+			log.Printf("Warning: window %s: unsupported layout %q, using tiled: %v", window.Name, layout, err)
+			window.Grid = autoGrid(len(names))
+			return nil
+		}
+		next := 0
+		var rename func(node *LayoutNode)
+		rename = func(node *LayoutNode) {
+			if node.PaneName != "" && next < len(names) {
+				node.PaneName = names[next]
+				next++
+			}
+			for i := range node.Columns {
+				rename(&node.Columns[i])
+			}
+			for i := range node.Rows {
+				rename(&node.Rows[i])
+			}
+		}
+		rename(&node)
+		if next != len(names) {
+			return fmt.Errorf("window %s: layout %q has %d panes, not %d", window.Name, layout, next, len(names))
+		}
+		window.Layout = node
+	}
+	return nil
+}
+
+// stack returns a single node for nodes, either in columns or in rows
+func stack(nodes []LayoutNode, columns bool) LayoutNode {
+	if len(nodes) == 1 {
+		return nodes[0]
+	}
+	if columns {
+		return LayoutNode{Columns: nodes}
+	}
+	return LayoutNode{Rows: nodes}
+}
+
+// convertCommand writes a tmuxinator or tmuxp file as a gridlock configuration
+func convertCommand(fs *flag.FlagSet) func(args []string, opts upOptions) {
+	output := fs.String("o", "", "File to write the configuration to (default standard output)")
+	return func(args []string, opts upOptions) {
+		if len(args) > 0 {
+			fs.Parse(args[1:])
+		}
+		if len(args) == 0 || fs.NArg() > 0 {
+			log.Fatalf("Usage: gridlock convert <tmuxinator or tmuxp file> [-o file]")
+		}
+		data, err := os.ReadFile(expandPath(args[0]))
+		if err != nil {
+			log.Fatalf("failed to read %s: %v", args[0], err)
+		}
+		config, err := convertConfig(data)
+		if err != nil {
+			log.Fatalf("%s: %v", args[0], err)
+		}
+		if config.Session.Name == "" {
+			log.Fatalf("%s: no session name", args[0])
+		}
+		if *output != "" {
+			if err := writeConfig(*output, config); err != nil {
+				log.Fatalf("%v", err)
+			}
+			fmt.Printf("Converted %s to %s\n", args[0], *output)
+			return
+		}
+		if err := encodeConfig(os.Stdout, config); err != nil {
+			log.Fatalf("%v", err)
+		}
+	}
+}
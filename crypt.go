@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Extensions of encrypted configuration files, tried in order when the plain file is missing
+var encryptedConfigExtensions = []string{".age", ".gpg"}
+
+// resolveConfigPath returns path, or its encrypted variant when only that exists
+func resolveConfigPath(path string) string {
+	if _, err := os.Stat(path); err == nil {
+		return path
+	}
+	for _, ext := range encryptedConfigExtensions {
+		if _, err := os.Stat(path + ext); err == nil {
+			return path + ext
+		}
+	}
+	return path
+}
+
+func isEncryptedConfig(path string) bool {
+	ext := filepath.Ext(path)
+	return ext == ".age" || ext == ".gpg"
+}
+
+// ageIdentity returns the age identity file used for decryption
+func ageIdentity() string {
+	if identity := os.Getenv("GRIDLOCK_AGE_IDENTITY"); identity != "" {
+		return expandPath(identity)
+	}
+	return expandPath("~/.config/age/keys.txt")
+}
+
+// readConfigFile reads a configuration file, decrypting it with age or gpg when needed
+func readConfigFile(path string) ([]byte, error) {
+	var cmd *exec.Cmd
+	switch filepath.Ext(path) {
+	case ".age":
+		cmd = exec.Command("age", "--decrypt", "-i", ageIdentity(), path)
+	case ".gpg":
+		cmd = exec.Command("gpg", "--quiet", "--batch", "--decrypt", path)
+	default:
+		return os.ReadFile(path)
+	}
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt %s: %v\nOutput: %s", path, err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// encryptCommand encrypts a configuration file with age (default) or gpg
+func encryptCommand(fs *flag.FlagSet) func(args []string, opts upOptions) {
+	recipient := fs.String("recipient", os.Getenv("GRIDLOCK_AGE_RECIPIENT"), "age recipient (defaults to the public key of the identity)")
+	gpgRecipient := fs.String("gpg-recipient", "", "Encrypt with gpg for this recipient instead of age")
+	remove := fs.Bool("remove", false, "Remove the plaintext file after encrypting")
+	return func(args []string, opts upOptions) {
+		path := opts.configFile
+		if len(args) > 0 {
+			path = args[0]
+		}
+
+		var cmd *exec.Cmd
+		var target string
+		if *gpgRecipient != "" {
+			target = path + ".gpg"
+			cmd = exec.Command("gpg", "--batch", "--yes", "--encrypt", "-r", *gpgRecipient, "-o", target, path)
+		} else {
+			if *recipient == "" {
+				out, err := exec.Command("age-keygen", "-y", ageIdentity()).Output()
+				if err != nil {
+					log.Fatalf("No recipient given and failed to read public key from %s: %v", ageIdentity(), err)
+				}
+				*recipient = strings.TrimSpace(string(out))
+			}
+			target = path + ".age"
+			cmd = exec.Command("age", "--encrypt", "-r", *recipient, "-o", target, path)
+		}
+
+		if out, err := cmd.CombinedOutput(); err != nil {
+			log.Fatalf("failed to encrypt %s: %v\nOutput: %s", path, err, strings.TrimSpace(string(out)))
+		}
+		fmt.Printf("Encrypted %s to %s\n", path, target)
+
+		if *remove {
+			if err := os.Remove(path); err != nil {
+				log.Fatalf("failed to remove %s: %v", path, err)
+			}
+			fmt.Printf("Removed %s\n", path)
+		}
+	}
+}
+
+// decryptCommand writes the plaintext of an encrypted configuration file next to it
+func decryptCommand(fs *flag.FlagSet) func(args []string, opts upOptions) {
+	return func(args []string, opts upOptions) {
+		path := resolveConfigPath(opts.configFile)
+		if len(args) > 0 {
+			path = args[0]
+		}
+		if !isEncryptedConfig(path) {
+			log.Fatalf("%s is not an encrypted configuration (expected .age or .gpg)", path)
+		}
+
+		target := strings.TrimSuffix(path, filepath.Ext(path))
+		if _, err := os.Stat(target); err == nil {
+			log.Fatalf("%s already exists", target)
+		}
+
+		data, err := readConfigFile(path)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		if err := os.WriteFile(target, data, 0600); err != nil {
+			log.Fatalf("failed to write %s: %v", target, err)
+		}
+		fmt.Printf("Decrypted %s to %s\n", path, target)
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// applyPaneDefaults resolves the pane-defaults of the session and its windows into every pane.
+//
+// Precedence, from highest to lowest: the pane itself, the window's pane-defaults, the
+// window's own settings (working-directory, keep-open, env), the session's pane-defaults, and
+// finally the session's own settings. Env maps are merged key by key in the same order.
+func applyPaneDefaults(config *Config) {
+	session := &config.Session
+	for i := range session.Windows {
+		window := &session.Windows[i]
+		for j := range window.Panes {
+			pane := &window.Panes[j]
+
+			if pane.WorkingDirectory == "" {
+				if window.PaneDefaults.WorkingDirectory != "" {
+					pane.WorkingDirectory = window.PaneDefaults.WorkingDirectory
+				} else if window.WorkingDirectory == "" {
+					pane.WorkingDirectory = session.PaneDefaults.WorkingDirectory
+				}
+			}
+			if pane.Shell == "" {
+				pane.Shell = firstNonEmpty(window.PaneDefaults.Shell, session.PaneDefaults.Shell)
+			}
+			if pane.Style == "" {
+				pane.Style = firstNonEmpty(window.PaneDefaults.Style, session.PaneDefaults.Style)
+			}
+			if pane.KeepOpen == nil {
+				switch {
+				case window.PaneDefaults.KeepOpen != nil:
+					pane.KeepOpen = window.PaneDefaults.KeepOpen
+				case window.KeepOpen:
+					keepOpen := true
+					pane.KeepOpen = &keepOpen
+				default:
+					pane.KeepOpen = session.PaneDefaults.KeepOpen
+				}
+			}
+			if pane.AsScript == nil {
+				if window.PaneDefaults.AsScript != nil {
+					pane.AsScript = window.PaneDefaults.AsScript
+				} else {
+					pane.AsScript = session.PaneDefaults.AsScript
+				}
+			}
+			if pane.Log == nil {
+				if window.PaneDefaults.Log != nil {
+					pane.Log = window.PaneDefaults.Log
+				} else {
+					pane.Log = session.PaneDefaults.Log
+				}
+			}
+			pane.Env = mergeEnv(session.Env, session.PaneDefaults.Env, window.Env, window.PaneDefaults.Env, pane.Env)
+		}
+	}
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// mergeEnv merges env maps, later maps taking precedence
+func mergeEnv(maps ...map[string]string) map[string]string {
+	var merged map[string]string
+	for _, m := range maps {
+		for k, v := range m {
+			if merged == nil {
+				merged = make(map[string]string)
+			}
+			merged[k] = v
+		}
+	}
+	return merged
+}
+
+// envKeys returns the keys of an env map in a stable order
+func envKeys(env map[string]string) []string {
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// envArgs returns -e KEY=VALUE arguments in a stable order
+func envArgs(env map[string]string) []string {
+	var args []string
+	for _, k := range envKeys(env) {
+		args = append(args, "-e", fmt.Sprintf("%s=%s", k, env[k]))
+	}
+	return args
+}
+
+// Scopes the variables of the session's env can be set in, see env-scope
+const (
+	// The tmux server's global environment, inherited by panes of every session
+	envScopeGlobal = "global"
+	// The session's tmux environment, inherited by panes opened later in the session (default)
+	envScopeSession = "session"
+	// Only the panes gridlock creates
+	envScopePane = "pane"
+)
+
+// validateEnvScope checks that env-scope gives known scopes to variables of the session's env
+func validateEnvScope(session *SessionConfig) error {
+	for _, k := range envKeys(session.EnvScope) {
+		switch session.EnvScope[k] {
+		case envScopeGlobal, envScopeSession, envScopePane:
+		default:
+			return fmt.Errorf("env-scope of %s must be global, session or pane", k)
+		}
+		if _, ok := session.Env[k]; !ok {
+			return fmt.Errorf("env-scope names %s, which is not in the session's env", k)
+		}
+	}
+	return nil
+}
+
+// setSessionEnvironment adds the session's env to the tmux environment of its scope, the
+// session's by default, so panes the user opens later get it too. The configured panes are
+// started with it by respawnPane whatever its scope.
+func (t *TMUX) setSessionEnvironment(sessionName string, session *SessionConfig) {
+	for _, k := range envKeys(session.Env) {
+		switch session.EnvScope[k] {
+		case envScopeGlobal:
+			t.run("set-environment", "-g", k, session.Env[k])
+		case envScopePane:
+		default:
+			t.run("set-environment", "-t", sessionName, k, session.Env[k])
+		}
+	}
+}
+
+// respawnPane restarts a freshly created pane with its configured shell and environment
+func (t *TMUX) respawnPane(target string, pane *PaneConfig, workDir string) error {
+	args := []string{"respawn-pane", "-k", "-t", target}
+	if workDir != "" {
+		args = append(args, "-c", workDir)
+	}
+	args = append(args, envArgs(pane.Env)...)
+	if pane.Shell != "" {
+		args = append(args, pane.Shell)
+	}
+	_, err := t.run(args...)
+	return err
+}
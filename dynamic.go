@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// dynamicPane is a JSON line printed by a panes-from-command
+type dynamicPane struct {
+	Name             string `json:"name"`
+	WorkingDirectory string `json:"working-directory"`
+	Cwd              string `json:"cwd"`
+	Command          string `json:"command"`
+}
+
+// parseDynamicPane parses one line of panes-from-command output, either a JSON object
+// or tab-separated "name<TAB>working-directory<TAB>command" (trailing fields optional)
+func parseDynamicPane(line string) (PaneConfig, error) {
+	if strings.HasPrefix(line, "{") {
+		var p dynamicPane
+		if err := json.Unmarshal([]byte(line), &p); err != nil {
+			return PaneConfig{}, fmt.Errorf("invalid pane json %q: %v", line, err)
+		}
+		return PaneConfig{
+			Name:             p.Name,
+			WorkingDirectory: firstNonEmpty(p.WorkingDirectory, p.Cwd),
+			Command:          p.Command,
+		}, nil
+	}
+
+	fields := strings.SplitN(line, "\t", 3)
+	pane := PaneConfig{Name: strings.TrimSpace(fields[0])}
+	if len(fields) > 1 {
+		pane.WorkingDirectory = strings.TrimSpace(fields[1])
+	}
+	if len(fields) > 2 {
+		pane.Command = fields[2]
+	}
+	return pane, nil
+}
+
+// expandPanesFromCommand runs a window's panes-from-command and appends the panes it prints
+func expandPanesFromCommand(window *WindowConfig, sessionWorkDir string) error {
+	if window.PanesFromCommand == "" {
+		return nil
+	}
+
+	cmd := exec.Command("sh", "-c", window.PanesFromCommand)
+	if dir := expandPath(firstNonEmpty(window.WorkingDirectory, sessionWorkDir)); dir != "" {
+		cmd.Dir = dir
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("panes-from-command failed: %v\nOutput: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	for _, line := range strings.Split(string(out), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		pane, err := parseDynamicPane(line)
+		if err != nil {
+			return err
+		}
+		if pane.Name == "" {
+			pane.Name = fmt.Sprintf("%s-pane-%d", window.Name, len(window.Panes))
+		}
+		window.Panes = append(window.Panes, pane)
+	}
+	return nil
+}
+
+// resolveWorkingDirectoryCmds sets the working directory of every pane with a
+// working-directory-cmd to the first line the command prints. The command runs in the
+// directory the pane would otherwise start in, which relative output is resolved against;
+// when it prints nothing the pane keeps that directory.
+func resolveWorkingDirectoryCmds(config *Config) error {
+	session := &config.Session
+	for i := range session.Windows {
+		window := &session.Windows[i]
+		for j := range window.Panes {
+			pane := &window.Panes[j]
+			if pane.WorkingDirectoryCmd == "" {
+				continue
+			}
+			dir := expandPath(firstNonEmpty(pane.WorkingDirectory, window.WorkingDirectory, session.WorkingDirectory))
+			cmd := exec.Command("sh", "-c", pane.WorkingDirectoryCmd)
+			cmd.Dir = dir
+			var stderr bytes.Buffer
+			cmd.Stderr = &stderr
+			out, err := cmd.Output()
+			if err != nil {
+				return fmt.Errorf("window %s: pane %s: working-directory-cmd failed: %v\nOutput: %s", window.Name, pane.Name, err, strings.TrimSpace(stderr.String()))
+			}
+			resolved, _, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+			if resolved == "" {
+				log.Printf("Warning: window %s: pane %s: working-directory-cmd printed nothing", window.Name, pane.Name)
+				continue
+			}
+			resolved = expandPath(resolved)
+			if !filepath.IsAbs(resolved) && dir != "" {
+				resolved = filepath.Join(dir, resolved)
+			}
+			pane.WorkingDirectory = resolved
+		}
+	}
+	return nil
+}
+
+// autoGrid returns a grid specification that fits n panes in a roughly square grid
+func autoGrid(n int) string {
+	cols := int(math.Ceil(math.Sqrt(float64(n))))
+	if cols < 1 {
+		cols = 1
+	}
+	rows := (n + cols - 1) / cols
+	if rows < 1 {
+		rows = 1
+	}
+	return fmt.Sprintf("%dx%d", cols, rows)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+)
+
+// editCommand opens the configuration of a named project, or the local one, in the user's
+// editor and validates it once the editor exits. When the session of the configuration is
+// running, the changes can be applied to it like with gridlock apply.
+func editCommand(fs *flag.FlagSet) func(args []string, opts upOptions) {
+	apply := fs.Bool("apply", false, "Apply the changes to the running session without asking")
+	noApply := fs.Bool("no-apply", false, "Do not offer to apply the changes to the running session")
+	return func(args []string, opts upOptions) {
+		path := resolveConfigPath(opts.configFile)
+		if len(args) > 1 {
+			log.Fatalf("Usage: gridlock edit [project]")
+		}
+		if len(args) == 1 {
+			var err error
+			if path, err = namedProjectPath(args[0]); err != nil {
+				log.Fatalf("%v", err)
+			}
+			if _, err := os.Stat(path); err != nil {
+				log.Fatalf("No project named %s, create it with gridlock new %s", args[0], args[0])
+			}
+		} else if _, err := os.Stat(path); err != nil {
+			log.Fatalf("No %s here, create one with gridlock init", opts.configFile)
+		}
+		if isEncryptedConfig(path) {
+			log.Fatalf("%s is encrypted, decrypt it with gridlock decrypt before editing it", path)
+		}
+		before, err := os.ReadFile(path)
+		if err != nil {
+			log.Fatalf("failed to read %s: %v", path, err)
+		}
+
+		var config *Config
+		for {
+			if err := runEditor(path); err != nil {
+				log.Fatalf("%v", err)
+			}
+			if config, err = loadConfig(path); err == nil {
+				if err = prepareConfig(config); err == nil {
+					break
+				}
+				err = fmt.Errorf("invalid config: %v", err)
+			}
+			fmt.Printf("%s: %v\n", path, err)
+			if !isTerminal(os.Stdin) || !confirm("Edit it again?") {
+				os.Exit(1)
+			}
+		}
+		after, _ := os.ReadFile(path)
+		if bytes.Equal(before, after) {
+			fmt.Printf("%s is unchanged\n", path)
+			return
+		}
+		fmt.Printf("%s is valid\n", path)
+
+		query := newTMUX(opts, config)
+		query.DryRun = false
+		if *noApply || !query.sessionExists(config.Session.Name) {
+			return
+		}
+		if !*apply && (!isTerminal(os.Stdin) || !confirm(fmt.Sprintf("Apply the changes to the running session %s?", config.Session.Name))) {
+			return
+		}
+		opts.configFile = path
+		opts.sync = true
+		up(opts)
+	}
+}
+
+// runEditor opens path in $VISUAL or $EDITOR, falling back to vi. The editor is run by the
+// shell, so the variables may hold arguments such as "code --wait".
+func runEditor(path string) error {
+	editor := firstNonEmpty(os.Getenv("VISUAL"), os.Getenv("EDITOR"), "vi")
+	cmd := exec.Command("sh", "-c", editor+` "$1"`, "sh", path)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("editor %s: %v", editor, err)
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package main
+
+import "fmt"
+
+// validateEphemeral checks that no pane of an ephemeral window is meant to stay open
+func validateEphemeral(config *Config) error {
+	for _, window := range config.Session.Windows {
+		if !window.Ephemeral {
+			continue
+		}
+		if window.KeepOpen {
+			return fmt.Errorf("window %s: ephemeral and keep-open exclude each other", window.Name)
+		}
+		for _, pane := range window.Panes {
+			if pane.KeepOpen != nil && *pane.KeepOpen {
+				return fmt.Errorf("window %s: pane %s: keep-open is not allowed in an ephemeral window", window.Name, pane.Name)
+			}
+			if pane.OnExit != "" && pane.OnExit != onExitDestroy {
+				return fmt.Errorf("window %s: pane %s: on-exit %s is not allowed in an ephemeral window", window.Name, pane.Name, pane.OnExit)
+			}
+		}
+	}
+	return nil
+}
+
+// closeOnExit makes a pane of an ephemeral window close when its process exits, whatever
+// remain-on-exit is set to globally. tmux closes the window with its last pane.
+func (t *TMUX) closeOnExit(paneTarget string) {
+	t.run("set-option", "-p", "-t", paneTarget, "remain-on-exit", "off")
+}
+
+// exitOnSuccess makes the shell of a pane exit once its last command succeeded, leaving a
+// failed command's output on screen
+func exitOnSuccess(command string) string {
+	return command + " && exit"
+}
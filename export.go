@@ -0,0 +1,242 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// scriptRecorder stands in for the tmux binary while exporting a script. It answers like
+// fakeTMUX and keeps the invocations that change the server, leaving out the queries.
+type scriptRecorder struct {
+	fakeTMUX
+	lines []string
+}
+
+// Commands that only query the server and have no place in a script
+var queryCommands = map[string]bool{
+	"has-session":     true,
+	"list-sessions":   true,
+	"list-windows":    true,
+	"list-panes":      true,
+	"show-options":    true,
+	"display-message": true,
+	"capture-pane":    true,
+}
+
+func (r *scriptRecorder) exec(args []string) (string, error) {
+	out, err := r.fakeTMUX.exec(args)
+	command := args
+	if len(command) > 2 && command[0] == "-L" {
+		command = command[2:]
+	}
+	if queryCommands[command[0]] {
+		return out, err
+	}
+
+	// A tmux config fragment is a temporary file, so it goes into the script itself
+	if command[0] == "source-file" && len(command) == 2 && strings.HasPrefix(command[1], os.TempDir()) {
+		if data, readErr := os.ReadFile(command[1]); readErr == nil {
+			quoted := append(quoteArgs(args[:len(args)-1]), "-")
+			r.lines = append(r.lines, "tmux "+strings.Join(quoted, " ")+" <<'GRIDLOCK_EOF'\n"+strings.TrimRight(string(data), "\n")+"\nGRIDLOCK_EOF")
+			return out, err
+		}
+	}
+	line := "tmux " + strings.Join(quoteArgs(args), " ")
+	if slices.Contains(command, "-P") {
+		// gridlock reads the printed window ID, the script has no use for it
+		line += " >/dev/null"
+	}
+	r.lines = append(r.lines, line)
+	return out, err
+}
+
+// quoteArgs quotes arguments for a POSIX shell where needed
+func quoteArgs(args []string) []string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = shellWord(arg)
+	}
+	return quoted
+}
+
+// shellWord returns an argument as is when the shell would not change it, quoted otherwise
+func shellWord(s string) string {
+	if s == "" {
+		return "''"
+	}
+	for _, r := range s {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_./:=@%+,", r)) {
+			return shellQuote(s)
+		}
+	}
+	return s
+}
+
+// Size of the client layouts are chosen for when exporting, unless given
+const (
+	defaultExportWidth  = 200
+	defaultExportHeight = 50
+)
+
+// writeScript writes a POSIX script that creates the session with the recorded commands,
+// unless it is running already, and then attaches to it
+func writeScript(w io.Writer, configFile string, sessionName string, socket string, lines []string) {
+	tmux := "tmux"
+	if socket != "" {
+		tmux += " -L " + shellWord(socket)
+	}
+	target := shellWord("=" + sessionName)
+	fmt.Fprintf(w, "#!/bin/sh\n")
+	fmt.Fprintf(w, "# Creates the tmux session %s of %s and attaches to it, or only creates\n", sessionName, filepath.Base(configFile))
+	fmt.Fprintf(w, "# it when run with -d. Generated by gridlock, needs only tmux.\n")
+	fmt.Fprintf(w, "set -e\n\n")
+	fmt.Fprintf(w, "if ! %s has-session -t %s 2>/dev/null; then\n", tmux, target)
+	for _, line := range lines {
+		fmt.Fprintf(w, "\t%s\n", line)
+	}
+	fmt.Fprintf(w, "fi\n\n")
+	fmt.Fprintf(w, "if [ \"$1\" != \"-d\" ]; then\n")
+	fmt.Fprintf(w, "\tif [ -n \"$TMUX\" ]; then\n")
+	fmt.Fprintf(w, "\t\t%s switch-client -t %s\n", tmux, target)
+	fmt.Fprintf(w, "\telse\n")
+	fmt.Fprintf(w, "\t\texec %s attach-session -t %s\n", tmux, target)
+	fmt.Fprintf(w, "\tfi\n")
+	fmt.Fprintf(w, "fi\n")
+}
+
+// exportCommand writes the session of the configuration in a form that does not need gridlock
+func exportCommand(fs *flag.FlagSet) func(args []string, opts upOptions) {
+	output := fs.String("o", "", "File to write to (default standard output)")
+	size := fs.String("size", fmt.Sprintf("%dx%d", defaultExportWidth, defaultExportHeight), "Size of the client the layouts are chosen for, WIDTHxHEIGHT")
+	return func(args []string, opts upOptions) {
+		if len(args) == 0 || (args[0] != "script" && args[0] != "zellij") {
+			log.Fatalf("Usage: gridlock export script|zellij [-o file]")
+		}
+		fs.Parse(args[1:])
+
+		config, err := loadConfig(opts.configFile)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		if args[0] == "zellij" {
+			exportZellij(config, *size, *output)
+			return
+		}
+		var width, height int
+		if _, err := fmt.Sscanf(*size, "%dx%d", &width, &height); err != nil {
+			log.Fatalf("Invalid size %q, expected WIDTHxHEIGHT", *size)
+		}
+		lines := recordScript(opts, width, height)
+
+		w := io.Writer(os.Stdout)
+		if *output != "" {
+			f, err := os.OpenFile(*output, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0755)
+			if err != nil {
+				log.Fatalf("Failed to write script: %v", err)
+			}
+			defer f.Close()
+			w = f
+		}
+		writeScript(w, opts.configFile, config.Session.Name, newTMUX(opts, config).Socket, lines)
+	}
+}
+
+// recordScript provisions the session against a scriptRecorder, as gridlock test does, for a
+// client of the given size and returns the recorded commands. The progress messages go to
+// standard error, out of a script written to standard output.
+func recordScript(opts upOptions, width, height int) []string {
+	recorder := &scriptRecorder{}
+	recorder.width, recorder.height = width, height
+	os.Setenv("TMUX", "gridlock-export")
+	opts.executor = recorder.exec
+	opts.dryRun = false
+	opts.dryRunScript = false
+	opts.detached = true
+	opts.retries = 0
+	stdout := os.Stdout
+	os.Stdout = os.Stderr
+	up(opts)
+	os.Stdout = stdout
+	return recorder.lines
+}
+
+// Value of --dry-run that writes a script, see dryRunFlag
+const dryRunScript = "script"
+
+// dryRunFlag is --dry-run, which is a boolean that can also be set to script. It holds ""
+// when off, "true" to print the commands and "script" to write them as a script.
+type dryRunFlag string
+
+func (f *dryRunFlag) String() string {
+	if f == nil || *f == "" {
+		return "false"
+	}
+	return string(*f)
+}
+
+func (f *dryRunFlag) Set(s string) error {
+	if s == dryRunScript {
+		*f = dryRunScript
+		return nil
+	}
+	on, err := strconv.ParseBool(s)
+	if err != nil {
+		return fmt.Errorf("expected true, false or script")
+	}
+	*f = ""
+	if on {
+		*f = "true"
+	}
+	return nil
+}
+
+func (f *dryRunFlag) IsBoolFlag() bool { return true }
+
+// writeDryRunScript writes the script of --dry-run=script to standard output: the commands
+// up would run for the terminal gridlock runs in, as export script writes them, so it can be
+// audited, committed or run where gridlock is not installed
+func writeDryRunScript(opts upOptions) {
+	config, err := loadConfig(opts.configFile)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	width, height, ok := terminalSize()
+	if !ok {
+		width, height = defaultExportWidth, defaultExportHeight
+	}
+	lines := recordScript(opts, width, height)
+	writeScript(os.Stdout, opts.configFile, config.Session.Name, newTMUX(opts, config).Socket, lines)
+}
+
+// exportZellij writes the zellij layout of the configuration, with the layouts chosen for
+// a client of the given size
+func exportZellij(config *Config, size string, output string) {
+	if err := prepareConfig(config); err != nil {
+		log.Fatalf("invalid config: %v", err)
+	}
+	var width, height int
+	if _, err := fmt.Sscanf(size, "%dx%d", &width, &height); err != nil {
+		log.Fatalf("Invalid size %q, expected WIDTHxHEIGHT", size)
+	}
+	layouts, err := chooseLayouts(&config.Session, width, height, isSmallClient(&config.Session, width, height))
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	w := io.Writer(os.Stdout)
+	if output != "" {
+		f, err := os.Create(output)
+		if err != nil {
+			log.Fatalf("Failed to write layout: %v", err)
+		}
+		defer f.Close()
+		w = f
+	}
+	io.WriteString(w, zellijLayout(config, layouts))
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// savePaneContents writes the contents and scrollback of a pane to a file in the state
+// directory and returns its path
+func (t *TMUX) savePaneContents(paneTarget string) (string, error) {
+	out, err := t.run("capture-pane", "-p", "-J", "-S", "-", "-t", paneTarget)
+	if err != nil {
+		return "", err
+	}
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	dir = filepath.Join(dir, "failures")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	name := fmt.Sprintf("%s-%s.txt", slugify(paneTarget), time.Now().Format("20060102-150405"))
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(out), 0644); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// reportPaneFailure logs a failed provisioning step along with a capture of the pane, so
+// failures of unattended or remote provisioning can be debugged after the fact
+func (t *TMUX) reportPaneFailure(paneTarget string, step string, err error) {
+	if t.DryRun {
+		return
+	}
+	path, captureErr := t.savePaneContents(paneTarget)
+	if captureErr != nil {
+		log.Printf("Warning: %s failed for %s: %v (pane could not be captured: %v)", step, paneTarget, err, captureErr)
+		return
+	}
+	log.Printf("Warning: %s failed for %s: %v (pane contents saved to %s)", step, paneTarget, err, path)
+}
+
+// slugify lowercases text and replaces everything but letters and digits with single dashes,
+// so that names are safe to use in tmux targets and YAML
+func slugify(text string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(text) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "-")
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// provisionInBackground starts a detached gridlock run that provisions the session of a
+// window created with --fast-attach, so the caller can attach right away. The run inherits
+// the environment and directory, which the configuration may depend on.
+func (t *TMUX) provisionInBackground(windowID string, opts upOptions) error {
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	configFile := opts.configFile
+	if abs, err := filepath.Abs(configFile); err == nil {
+		configFile = abs
+	}
+	args := []string{"-f", configFile, "-d", "--resume-window", windowID, "--timeout", opts.timeout.String(), "--retries", strconv.Itoa(opts.retries)}
+	if t.Socket != "" {
+		args = append(args, "--socket", t.Socket)
+	}
+	// The rest of the session is provisioned as the first window was
+	if opts.root != "" {
+		args = append(args, "--root", opts.root)
+	}
+	if opts.backend != "" {
+		args = append(args, "--backend", opts.backend)
+	}
+	if opts.noCommands {
+		args = append(args, "--no-commands")
+	}
+	if opts.controlMode {
+		args = append(args, "--control-mode")
+	}
+	if opts.wait {
+		args = append(args, "--wait", "--wait-timeout", opts.waitTimeout.String())
+	}
+	args = append(args, templateVars.args()...)
+
+	// Without output, as it would garble the terminal the session is attached in
+	cmd := exec.Command(executable, args...)
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start gridlock in the background: %v", err)
+	}
+	fmt.Printf("Provisioning the rest of the session in the background\n")
+	return nil
+}
+
+// resumedSession returns the name of the session of a window created with --fast-attach
+func (t *TMUX) resumedSession(windowID string) string {
+	out, err := t.run("display-message", "-p", "-t", windowID, "#{session_name}")
+	name := strings.TrimSpace(out)
+	if err != nil || name == "" {
+		log.Fatalf("Session of window %s to continue provisioning is gone", windowID)
+	}
+	return name
+}
+
+// notifyClients shows a message in the status line of every client attached to the session
+func (t *TMUX) notifyClients(sessionName string, message string) {
+	out, err := t.run("list-clients", "-t", sessionName, "-F", "#{client_name}")
+	if err != nil {
+		return
+	}
+	for _, client := range strings.Split(strings.TrimSpace(out), "\n") {
+		if client != "" {
+			t.run("display-message", "-c", client, message)
+		}
+	}
+}
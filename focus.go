@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/esaiaswestberg/gridlock/pkg/tmux"
+)
+
+// Session user option holding the window and pane names of the pane last focused in a
+// session with restore-focus, separated by a tab
+const metadataLastFocused = "@gridlock-last-focused"
+
+// lastFocusedFormat expands to the value of metadataLastFocused for the current pane
+const lastFocusedFormat = "#{@gridlock-window}\t#{@gridlock-pane}"
+
+// paneTarget returns the target of the index-th pane of a window, counting from zero
+// whatever the pane-base-index of the window
+func (t *TMUX) paneTarget(windowTarget string, index int) string {
+	return fmt.Sprintf("%s.%d", windowTarget, t.paneBase+index)
+}
+
+// validateFocus checks that at most one window, and one pane per window, has focus: true
+func validateFocus(config *Config) error {
+	focusWindow := ""
+	for _, window := range config.Session.Windows {
+		if window.Focus {
+			if focusWindow != "" {
+				return fmt.Errorf("windows %s and %s both have focus", focusWindow, window.Name)
+			}
+			focusWindow = window.Name
+		}
+		focusPane := ""
+		for _, pane := range window.Panes {
+			if pane.Focus {
+				if focusPane != "" {
+					return fmt.Errorf("window %s: panes %s and %s both have focus", window.Name, focusPane, pane.Name)
+				}
+				focusPane = pane.Name
+			}
+		}
+	}
+	return nil
+}
+
+// focusedPane returns the index of the pane with focus: true in the layout of a window, or
+// -1 when no pane has it
+func focusedPane(layout LayoutNode, window *WindowConfig) int {
+	for i, name := range layoutPaneNames(layout) {
+		if pane := findPane(window, name); pane != nil && pane.Focus {
+			return i
+		}
+	}
+	return -1
+}
+
+// Index of the focus hooks in their hook arrays. A fixed index beside the usage stats hooks
+// keeps both, and is replaced rather than repeated when a session is rebuilt in place.
+const focusHookIndex = "[10]"
+
+// trackWindowFocus records the focused pane in the session whenever a pane of the window
+// is selected
+func (t *TMUX) trackWindowFocus(windowTarget string) {
+	t.run("set-hook", "-w", "-t", windowTarget, "window-pane-changed"+focusHookIndex, focusHook())
+}
+
+// trackSessionFocus records the focused pane whenever another window of the session is selected
+func (t *TMUX) trackSessionFocus(sessionName string) {
+	t.run("set-hook", "-t", sessionName, "session-window-changed"+focusHookIndex, focusHook())
+}
+
+func focusHook() string {
+	return "set-option -F " + metadataLastFocused + " " + tmux.Quote(lastFocusedFormat)
+}
+
+// lastFocused returns the names recorded for the pane focused last in the session, taking
+// the active pane when nothing was recorded yet
+func (t *TMUX) lastFocused(sessionName string) string {
+	if focused := t.sessionMetadata(sessionName, metadataLastFocused); focused != "" {
+		return focused
+	}
+	out, err := t.run("display-message", "-p", "-t", sessionName, lastFocusedFormat)
+	if err != nil || strings.TrimSpace(out) == "\t" {
+		return ""
+	}
+	return strings.TrimSuffix(out, "\n")
+}
+
+// restoreFocus selects the pane focused last in the session, found by the names of its
+// window and pane, so that it survives the session being recreated
+func (t *TMUX) restoreFocus(sessionName string) {
+	focused := t.sessionMetadata(sessionName, metadataLastFocused)
+	if focused == "" {
+		return
+	}
+	out, err := t.run("list-panes", "-s", "-t", sessionName, "-F", "#{pane_id} "+lastFocusedFormat)
+	if err != nil {
+		return
+	}
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		paneID, names, ok := strings.Cut(line, " ")
+		if ok && names == focused {
+			t.run("select-window", "-t", paneID)
+			t.run("select-pane", "-t", paneID)
+			return
+		}
+	}
+}
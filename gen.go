@@ -0,0 +1,271 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// genCommand generates the man page or the shell completions from the command metadata
+func genCommand(fs *flag.FlagSet) func(args []string, opts upOptions) {
+	outDir := fs.String("o", ".", "Directory to write the man page to")
+	return func(args []string, opts upOptions) {
+		if len(args) == 0 {
+			log.Fatalf("Usage: gridlock gen docs [-o dir] | gridlock gen completions bash|zsh|fish")
+		}
+
+		switch args[0] {
+		case "docs":
+			fs.Parse(args[1:])
+			path := filepath.Join(*outDir, "gridlock.1")
+			if err := os.MkdirAll(*outDir, 0755); err != nil {
+				log.Fatalf("Failed to create %s: %v", *outDir, err)
+			}
+			file, err := os.Create(path)
+			if err != nil {
+				log.Fatalf("Failed to create %s: %v", path, err)
+			}
+			defer file.Close()
+			writeManPage(file)
+			fmt.Printf("Wrote %s\n", path)
+		case "completions":
+			if len(args) < 2 {
+				log.Fatalf("Usage: gridlock gen completions bash|zsh|fish")
+			}
+			switch args[1] {
+			case "bash":
+				writeBashCompletion(os.Stdout)
+			case "zsh":
+				writeZshCompletion(os.Stdout)
+			case "fish":
+				writeFishCompletion(os.Stdout)
+			default:
+				log.Fatalf("Unsupported shell %q, expected bash, zsh or fish", args[1])
+			}
+		default:
+			log.Fatalf("Unknown gen target %q, expected docs or completions", args[0])
+		}
+	}
+}
+
+// roffEscape escapes text for use in a man page
+func roffEscape(text string) string {
+	text = strings.ReplaceAll(text, `\`, `\e`)
+	return strings.ReplaceAll(text, "-", `\-`)
+}
+
+// writeManPage writes the gridlock(1) man page in roff
+func writeManPage(w io.Writer) {
+	fmt.Fprintln(w, `.TH GRIDLOCK 1 "" "gridlock" "User Commands"`)
+	fmt.Fprintln(w, ".SH NAME")
+	fmt.Fprintln(w, `gridlock \- a TMUX session manager and automator`)
+	fmt.Fprintln(w, ".SH SYNOPSIS")
+	fmt.Fprintln(w, `.B gridlock
+[\fIoptions\fR] [\fIcommand\fR] [\fIargs\fR]`)
+	fmt.Fprintln(w, ".SH DESCRIPTION")
+	fmt.Fprintln(w, "gridlock creates TMUX sessions, windows and panes from a YAML configuration file, .gridlock.yaml by default.")
+	fmt.Fprintln(w, ".SH OPTIONS")
+	writeManFlags(w, globalFlags())
+	fmt.Fprintln(w, ".SH COMMANDS")
+	for _, cmd := range commandList() {
+		fmt.Fprintln(w, ".TP")
+		fmt.Fprintf(w, ".B %s", roffEscape(cmd.name))
+		if cmd.args != "" {
+			fmt.Fprintf(w, " \\fI%s\\fR", roffEscape(cmd.args))
+		}
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, roffEscape(cmd.summary))
+		if cmd.name == "up" {
+			continue
+		}
+		var flags []*flag.Flag
+		commandFlags(cmd).VisitAll(func(f *flag.Flag) {
+			flags = append(flags, f)
+		})
+		if len(flags) > 0 {
+			fmt.Fprintln(w, ".RS")
+			writeManFlags(w, flags)
+			fmt.Fprintln(w, ".RE")
+		}
+	}
+	fmt.Fprintln(w, ".SH ENVIRONMENT")
+	fmt.Fprintln(w, roffEscape("Every flag can also be set with an environment variable named after it, GRIDLOCK_ followed by the flag name in upper case with dashes replaced by underscores, e.g. GRIDLOCK_DRY_RUN=1 for --dry-run or GRIDLOCK_SOCKET for --socket. Flags on the command line take precedence over the environment, which takes precedence over the configuration file."))
+	fmt.Fprintln(w, ".SH FILES")
+	fmt.Fprintln(w, ".TP")
+	fmt.Fprintln(w, ".I .gridlock.yaml")
+	fmt.Fprintln(w, "The default configuration file, looked up in the current directory.")
+	fmt.Fprintln(w, ".SH SEE ALSO")
+	fmt.Fprintln(w, ".BR tmux (1)")
+}
+
+// writeManFlags writes flags as a list of tagged paragraphs
+func writeManFlags(w io.Writer, flags []*flag.Flag) {
+	for _, f := range flags {
+		fmt.Fprintln(w, ".TP")
+		fmt.Fprintf(w, ".B %s", roffEscape(dashed(f.Name)))
+		if short, ok := shorthands[f.Name]; ok && flag.CommandLine.Lookup(f.Name) == f {
+			fmt.Fprintf(w, `, \-%s`, short)
+		}
+		if typ := flagType(f); typ != "" {
+			fmt.Fprintf(w, ` \fI%s\fR`, typ)
+		}
+		fmt.Fprintln(w)
+		_, usage := flag.UnquoteUsage(f)
+		fmt.Fprintln(w, roffEscape(usage))
+	}
+}
+
+// dashed returns a flag name as written on the command line
+func dashed(name string) string {
+	if len(name) == 1 {
+		return "-" + name
+	}
+	return "--" + name
+}
+
+// completionFlags returns the long and short flag names of the global flags
+func completionFlags() []string {
+	var names []string
+	for _, f := range globalFlags() {
+		names = append(names, "--"+f.Name)
+		if short, ok := shorthands[f.Name]; ok {
+			names = append(names, "-"+short)
+		}
+	}
+	return names
+}
+
+// commandNames returns the names of the subcommands
+func commandNames() []string {
+	var names []string
+	for _, cmd := range commandList() {
+		names = append(names, cmd.name)
+	}
+	return names
+}
+
+// subcommandFlags returns the long flag names of a subcommand
+func subcommandFlags(cmd command) []string {
+	var names []string
+	if cmd.name == "up" {
+		return names
+	}
+	commandFlags(cmd).VisitAll(func(f *flag.Flag) {
+		names = append(names, dashed(f.Name))
+	})
+	return names
+}
+
+// writeBashCompletion writes a bash completion script
+func writeBashCompletion(w io.Writer) {
+	fmt.Fprintln(w, "# bash completion for gridlock")
+	fmt.Fprintln(w, "_gridlock() {")
+	fmt.Fprintln(w, `    local cur="${COMP_WORDS[COMP_CWORD]}" cmd="" word`)
+	fmt.Fprintln(w, `    for word in "${COMP_WORDS[@]:1:COMP_CWORD-1}"; do`)
+	fmt.Fprintln(w, `        case "$word" in -*) ;; *) cmd="$word"; break ;; esac`)
+	fmt.Fprintln(w, "    done")
+	fmt.Fprintf(w, "    local flags=%q\n", strings.Join(completionFlags(), " "))
+	fmt.Fprintln(w, `    case "$cmd" in`)
+	for _, cmd := range commandList() {
+		if sub := subcommandFlags(cmd); len(sub) > 0 {
+			fmt.Fprintf(w, "    %s) flags=\"$flags %s\" ;;\n", cmd.name, strings.Join(sub, " "))
+		}
+	}
+	fmt.Fprintln(w, "    esac")
+	fmt.Fprintln(w, `    if [[ "$cur" == -* ]]; then`)
+	fmt.Fprintln(w, `        COMPREPLY=($(compgen -W "$flags" -- "$cur"))`)
+	fmt.Fprintln(w, `    elif [[ -z "$cmd" ]]; then`)
+	fmt.Fprintf(w, "        COMPREPLY=($(compgen -W %q -- \"$cur\"))\n", strings.Join(commandNames(), " "))
+	fmt.Fprintln(w, "    else")
+	fmt.Fprintln(w, `        COMPREPLY=($(compgen -f -- "$cur"))`)
+	fmt.Fprintln(w, "    fi")
+	fmt.Fprintln(w, "}")
+	fmt.Fprintln(w, "complete -F _gridlock gridlock")
+}
+
+// zshEscape escapes text for use inside a single quoted zsh _arguments spec
+func zshEscape(text string) string {
+	text = strings.ReplaceAll(text, "'", `'\''`)
+	text = strings.ReplaceAll(text, "[", `\[`)
+	text = strings.ReplaceAll(text, "]", `\]`)
+	return strings.ReplaceAll(text, ":", `\:`)
+}
+
+// writeZshCompletion writes a zsh completion script
+func writeZshCompletion(w io.Writer) {
+	fmt.Fprintln(w, "#compdef gridlock")
+	fmt.Fprintln(w, "_gridlock() {")
+	fmt.Fprintln(w, "    local -a commands")
+	fmt.Fprintln(w, "    commands=(")
+	for _, cmd := range commandList() {
+		fmt.Fprintf(w, "        '%s:%s'\n", cmd.name, zshEscape(cmd.summary))
+	}
+	fmt.Fprintln(w, "    )")
+	fmt.Fprintln(w, "    _arguments \\")
+	for _, f := range globalFlags() {
+		_, usage := flag.UnquoteUsage(f)
+		value := ""
+		if flagType(f) != "" {
+			value = ":" + flagType(f) + ":"
+			if f.Name == "config" {
+				value += "_files"
+			}
+		}
+		fmt.Fprintf(w, "        '--%s[%s]%s' \\\n", f.Name, zshEscape(usage), value)
+		if short, ok := shorthands[f.Name]; ok {
+			fmt.Fprintf(w, "        '-%s[%s]%s' \\\n", short, zshEscape(usage), value)
+		}
+	}
+	fmt.Fprintln(w, "        '1:command:->command' \\")
+	fmt.Fprintln(w, "        '*::args:_files'")
+	fmt.Fprintln(w, `    [[ "$state" == command ]] && _describe 'command' commands`)
+	fmt.Fprintln(w, "}")
+	fmt.Fprintln(w, `_gridlock "$@"`)
+}
+
+// fishEscape escapes text for use inside a single quoted fish string
+func fishEscape(text string) string {
+	text = strings.ReplaceAll(text, `\`, `\\`)
+	return strings.ReplaceAll(text, "'", `\'`)
+}
+
+// writeFishCompletion writes a fish completion script
+func writeFishCompletion(w io.Writer) {
+	fmt.Fprintln(w, "# fish completion for gridlock")
+	fmt.Fprintf(w, "set -l gridlock_commands %s\n", strings.Join(commandNames(), " "))
+	for _, cmd := range commandList() {
+		fmt.Fprintf(w, "complete -c gridlock -f -n 'not __fish_seen_subcommand_from $gridlock_commands' -a %s -d '%s'\n", cmd.name, fishEscape(cmd.summary))
+	}
+	for _, f := range globalFlags() {
+		_, usage := flag.UnquoteUsage(f)
+		line := "complete -c gridlock -l " + f.Name
+		if short, ok := shorthands[f.Name]; ok {
+			line += " -s " + short
+		}
+		if flagType(f) != "" {
+			line += " -r"
+		}
+		fmt.Fprintf(w, "%s -d '%s'\n", line, fishEscape(usage))
+	}
+	for _, cmd := range commandList() {
+		if cmd.name == "up" {
+			continue
+		}
+		commandFlags(cmd).VisitAll(func(f *flag.Flag) {
+			_, usage := flag.UnquoteUsage(f)
+			option := "-l " + f.Name
+			if len(f.Name) == 1 {
+				option = "-s " + f.Name
+			}
+			line := fmt.Sprintf("complete -c gridlock -n '__fish_seen_subcommand_from %s' %s", cmd.name, option)
+			if flagType(f) != "" {
+				line += " -r"
+			}
+			fmt.Fprintf(w, "%s -d '%s'\n", line, fishEscape(usage))
+		})
+	}
+}
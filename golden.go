@@ -0,0 +1,121 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// fakeTMUX stands in for the tmux binary during gridlock test. It records every invocation
+// and answers queries as a server without sessions would, with a client of a fixed size.
+type fakeTMUX struct {
+	width    int
+	height   int
+	commands []string
+}
+
+func (f *fakeTMUX) exec(args []string) (string, error) {
+	recorded := append([]string{}, args...)
+	for i := range recorded {
+		// The hash changes with every edit of the configuration, the commands it causes matter
+		if recorded[i] == metadataConfigHash && i+1 < len(recorded) {
+			recorded[i+1] = "<hash>"
+		}
+	}
+	f.commands = append(f.commands, "tmux "+strings.Join(recorded, " "))
+
+	command := args[0]
+	if command == "-L" && len(args) > 2 {
+		command = args[2]
+	}
+	switch command {
+	case "has-session", "list-windows", "list-sessions", "show-options":
+		return "", errors.New("no server running")
+	case "display-message":
+		if strings.Contains(args[len(args)-1], "client_width") {
+			return fmt.Sprintf("%d %d", f.width, f.height), nil
+		}
+	}
+	return "", nil
+}
+
+// normalize makes recorded commands independent of the machine they were recorded on
+func (f *fakeTMUX) normalize() string {
+	var replacements []string
+	if root, err := gitRoot(); err == nil {
+		replacements = append(replacements, root, gitRootPrefix)
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		replacements = append(replacements, home, "~")
+	}
+	return strings.NewReplacer(replacements...).Replace(strings.Join(f.commands, "\n") + "\n")
+}
+
+// testCommand provisions the configuration against a fake tmux and compares the commands
+// it would run with a golden file
+func testCommand(fs *flag.FlagSet) func(args []string, opts upOptions) {
+	golden := fs.String("golden", "", "Golden file with the expected tmux commands (default <config>.golden)")
+	update := fs.Bool("update", false, "Write the golden file instead of comparing with it")
+	size := fs.String("size", "200x50", "Size of the simulated client, WIDTHxHEIGHT")
+	return func(args []string, opts upOptions) {
+		fake := &fakeTMUX{}
+		if _, err := fmt.Sscanf(*size, "%dx%d", &fake.width, &fake.height); err != nil {
+			log.Fatalf("Invalid size %q, expected WIDTHxHEIGHT", *size)
+		}
+		goldenFile := *golden
+		if goldenFile == "" {
+			goldenFile = opts.configFile + ".golden"
+		}
+
+		// Provision as if run from within tmux, so the simulated client size is used and
+		// nothing attaches
+		os.Setenv("TMUX", "gridlock-test")
+		opts.executor = fake.exec
+		opts.dryRun = false
+		opts.retries = 0
+		up(opts)
+		actual := fake.normalize()
+
+		if *update {
+			if err := os.MkdirAll(filepath.Dir(goldenFile), 0755); err != nil {
+				log.Fatalf("Failed to create %s: %v", filepath.Dir(goldenFile), err)
+			}
+			if err := os.WriteFile(goldenFile, []byte(actual), 0644); err != nil {
+				log.Fatalf("Failed to write golden file: %v", err)
+			}
+			fmt.Printf("Updated %s\n", goldenFile)
+			return
+		}
+
+		data, err := os.ReadFile(goldenFile)
+		if err != nil {
+			log.Fatalf("Failed to read golden file: %v (run with --update to create it)", err)
+		}
+		expected := string(data)
+		if expected == actual {
+			fmt.Printf("ok %s\n", goldenFile)
+			return
+		}
+
+		expectedLines := strings.Split(expected, "\n")
+		actualLines := strings.Split(actual, "\n")
+		for i := 0; i < len(expectedLines) || i < len(actualLines); i++ {
+			var e, a string
+			if i < len(expectedLines) {
+				e = expectedLines[i]
+			}
+			if i < len(actualLines) {
+				a = actualLines[i]
+			}
+			if e != a {
+				fmt.Printf("%s:%d: commands differ\n- %s\n+ %s\n", goldenFile, i+1, e, a)
+				break
+			}
+		}
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseGrid parses a "COLSxROWS" grid specification
+func parseGrid(spec string) (int, int, error) {
+	parts := strings.Split(strings.ToLower(strings.TrimSpace(spec)), "x")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid grid %q, expected COLSxROWS (e.g. 2x3)", spec)
+	}
+	cols, errC := strconv.Atoi(strings.TrimSpace(parts[0]))
+	rows, errR := strconv.Atoi(strings.TrimSpace(parts[1]))
+	if errC != nil || errR != nil || cols < 1 || rows < 1 {
+		return 0, 0, fmt.Errorf("invalid grid %q, expected COLSxROWS (e.g. 2x3)", spec)
+	}
+	return cols, rows, nil
+}
+
+// gridLayout builds a layout tree placing the panes left-to-right, top-to-bottom
+// in a grid of cols columns and rows rows. A last row with fewer panes is stretched.
+func gridLayout(spec string, panes []PaneConfig) (LayoutNode, error) {
+	cols, rows, err := parseGrid(spec)
+	if err != nil {
+		return LayoutNode{}, err
+	}
+	if len(panes) > cols*rows {
+		return LayoutNode{}, fmt.Errorf("grid %s has room for %d panes, but %d are defined", spec, cols*rows, len(panes))
+	}
+	if len(panes) == 0 {
+		return LayoutNode{}, fmt.Errorf("grid %s has no panes to place", spec)
+	}
+
+	var rowNodes []LayoutNode
+	for start := 0; start < len(panes); start += cols {
+		end := start + cols
+		if end > len(panes) {
+			end = len(panes)
+		}
+		var cells []LayoutNode
+		for _, pane := range panes[start:end] {
+			cells = append(cells, LayoutNode{PaneName: pane.Name})
+		}
+		if len(cells) == 1 {
+			rowNodes = append(rowNodes, cells[0])
+		} else {
+			rowNodes = append(rowNodes, LayoutNode{Columns: cells})
+		}
+	}
+	if len(rowNodes) == 1 {
+		return rowNodes[0], nil
+	}
+	return LayoutNode{Rows: rowNodes}, nil
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/esaiaswestberg/gridlock/pkg/schema"
+)
+
+// Number of runs kept in the history file
+const historyLimit = 1000
+
+// How long a run waits for other gridlock processes, e.g. those of up --all, to record their
+// runs, and after how long a lock is taken to be left behind by a process that died
+const (
+	historyLockTimeout = 5 * time.Second
+	historyLockStale   = 30 * time.Second
+)
+
+// historyPath returns the file in which provisioning runs are recorded, one JSON document per line
+func historyPath() (string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "history.jsonl"), nil
+}
+
+// provisioningRun is a run of gridlock up that is recorded in the history when it ends
+type provisioningRun struct {
+	run   schema.Run
+	start time.Time
+	// Dry runs and runs against the simulated server of gridlock test are not recorded
+	skip bool
+	// Called with the error of a failed run before gridlock exits
+	onFailure func(error)
+}
+
+func startRun(opts upOptions, config *Config, sessionName string) *provisioningRun {
+	configFile := opts.configFile
+	if abs, err := filepath.Abs(configFile); err == nil {
+		configFile = abs
+	}
+	return &provisioningRun{
+		run: schema.Run{
+			Session:    sessionName,
+			ConfigFile: configFile,
+			ConfigHash: configHash(config),
+			Action:     "attach",
+		},
+		start: time.Now(),
+		skip:  opts.dryRun || opts.executor != nil,
+	}
+}
+
+// finish records the run with its outcome
+func (r *provisioningRun) finish(err error) {
+	if r.skip {
+		return
+	}
+	r.run.Time = r.start
+	r.run.DurationMS = time.Since(r.start).Milliseconds()
+	r.run.Result = schema.RunOK
+	if err != nil {
+		r.run.Result = schema.RunFailed
+		r.run.Error = err.Error()
+	}
+	if err := appendHistory(r.run); err != nil {
+		log.Printf("Warning: failed to record run in history: %v", err)
+	}
+}
+
+// fatalf records the run as failed and exits like log.Fatalf
+func (r *provisioningRun) fatalf(format string, v ...interface{}) {
+	err := fmt.Errorf(format, v...)
+	r.finish(err)
+	if r.onFailure != nil {
+		r.onFailure(err)
+	}
+	log.Fatalf(format, v...)
+}
+
+// appendHistory adds a run to the history file, dropping the oldest runs beyond historyLimit.
+// The file is rewritten under a lock, so runs recorded at the same time are all kept.
+func appendHistory(run schema.Run) error {
+	path, err := historyPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	unlock, err := lockHistory(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	runs, err := readHistory(path)
+	if err != nil {
+		return err
+	}
+	runs = append(runs, run)
+	if len(runs) > historyLimit {
+		runs = runs[len(runs)-historyLimit:]
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	enc := json.NewEncoder(f)
+	for _, run := range runs {
+		if err := enc.Encode(run); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// lockHistory takes the lock of the history file at path, a file next to it that only one
+// process can create, waiting for the process holding it. It returns the function releasing
+// the lock.
+func lockHistory(path string) (func(), error) {
+	lock := path + ".lock"
+	deadline := time.Now().Add(historyLockTimeout)
+	for {
+		f, err := os.OpenFile(lock, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(lock) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		if info, err := os.Stat(lock); err == nil && time.Since(info.ModTime()) > historyLockStale {
+			os.Remove(lock)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("history is locked by %s", lock)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// readHistory reads the recorded runs, skipping lines that cannot be parsed
+func readHistory(path string) ([]schema.Run, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var runs []schema.Run
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var run schema.Run
+		if err := json.Unmarshal(scanner.Bytes(), &run); err == nil {
+			runs = append(runs, run)
+		}
+	}
+	return runs, scanner.Err()
+}
+
+// historyCommand lists the recorded provisioning runs of the configured session
+func historyCommand(fs *flag.FlagSet) func(args []string, opts upOptions) {
+	jsonOutput := fs.Bool("json", false, "Print the runs as JSON")
+	allSessions := fs.Bool("all-sessions", false, "List the runs of all sessions instead of the configured one")
+	limit := fs.Int("n", 20, "Number of most recent runs to list, 0 for all")
+	return func(args []string, opts upOptions) {
+		sessionName := ""
+		if !*allSessions {
+			config, err := loadConfig(opts.configFile)
+			if err != nil {
+				log.Fatalf("%v (use --all-sessions to list the runs of all sessions)", err)
+			}
+			sessionName = config.Session.Name
+		}
+		path, err := historyPath()
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		recorded, err := readHistory(path)
+		if err != nil {
+			log.Fatalf("failed to read history: %v", err)
+		}
+
+		history := schema.History{SchemaVersion: schema.Version, Runs: []schema.Run{}}
+		for _, run := range recorded {
+			if sessionName == "" || run.Session == sessionName {
+				history.Runs = append(history.Runs, run)
+			}
+		}
+		if *limit > 0 && len(history.Runs) > *limit {
+			history.Runs = history.Runs[len(history.Runs)-*limit:]
+		}
+
+		if *jsonOutput {
+			if err := schema.Write(os.Stdout, history); err != nil {
+				log.Fatalf("failed to write json: %v", err)
+			}
+			return
+		}
+		if len(history.Runs) == 0 {
+			fmt.Println("No runs recorded")
+			return
+		}
+		out := newOutput()
+		t := out.table("")
+		for _, run := range history.Runs {
+			result := run.Result
+			if run.Error != "" {
+				result = out.style(styleRed, result+": "+lastLine(run.Error))
+			}
+			hash := run.ConfigHash
+			if len(hash) > 12 {
+				hash = hash[:12]
+			}
+			duration := (time.Duration(run.DurationMS) * time.Millisecond).String()
+			t.row(run.Time.Local().Format("2006-01-02 15:04:05"), out.style(styleBold, run.Session), run.Action, duration, out.style(styleDim, hash), result)
+		}
+		t.flush()
+	}
+}
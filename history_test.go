@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/esaiaswestberg/gridlock/pkg/schema"
+)
+
+func TestAppendHistoryKeepsConcurrentRuns(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	const runs = 20
+	var wg sync.WaitGroup
+	for i := 0; i < runs; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := appendHistory(schema.Run{Session: fmt.Sprintf("s%d", i)}); err != nil {
+				t.Errorf("appendHistory failed: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	path, _ := historyPath()
+	recorded, err := readHistory(path)
+	if err != nil {
+		t.Fatalf("readHistory failed: %v", err)
+	}
+	if len(recorded) != runs {
+		t.Errorf("%d runs recorded, want %d", len(recorded), runs)
+	}
+	// Neither the lock nor temporary files are left behind
+	if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 1 {
+		t.Errorf("state directory has %d entries, want only the history", len(entries))
+	}
+}
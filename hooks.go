@@ -0,0 +1,155 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+)
+
+// Events hooks run on, as named in the configuration
+const (
+	hookBefore   = "before"
+	hookAfter    = "after"
+	hookOnAttach = "on-attach"
+	hookOnKill   = "on-kill"
+)
+
+// hookCommands returns the hooks configured for an event
+func hookCommands(h Hooks, event string) []string {
+	switch event {
+	case hookBefore:
+		return h.Before
+	case hookAfter:
+		return h.After
+	case hookOnAttach:
+		return h.OnAttach
+	case hookOnKill:
+		return h.OnKill
+	}
+	return nil
+}
+
+// overlayHooks replaces the hooks of every event the overlay has hooks for
+func overlayHooks(hooks *Hooks, overlay Hooks) {
+	if len(overlay.Before) > 0 {
+		hooks.Before = overlay.Before
+	}
+	if len(overlay.After) > 0 {
+		hooks.After = overlay.After
+	}
+	if len(overlay.OnAttach) > 0 {
+		hooks.OnAttach = overlay.OnAttach
+	}
+	if len(overlay.OnKill) > 0 {
+		hooks.OnKill = overlay.OnKill
+	}
+}
+
+// hasHooks reports whether any event has hooks
+func hasHooks(hooks Hooks) bool {
+	return len(hooks.Before) > 0 || len(hooks.After) > 0 || len(hooks.OnAttach) > 0 || len(hooks.OnKill) > 0
+}
+
+// runHooks runs the hooks of an event with sh in dir, one after another, stopping at the
+// first that fails. env names the session and window they run for. Hooks do not run
+// against a simulated server and are only printed in dry-run mode.
+func (t *TMUX) runHooks(event string, hooks Hooks, dir string, env ...string) error {
+	if t.Executor != nil {
+		return nil
+	}
+	for _, command := range hookCommands(hooks, event) {
+		if t.DryRun {
+			fmt.Printf("sh -c %s\n", shellQuote(command))
+			continue
+		}
+		if t.Verbose {
+			log.Printf("Running %s hook: %s", event, command)
+		}
+		cmd := exec.Command("sh", "-c", command)
+		cmd.Dir = expandPath(dir)
+		cmd.Env = append(os.Environ(), env...)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("%s hook %q failed: %v", event, command, err)
+		}
+	}
+	return nil
+}
+
+// hookEnv returns an env map as KEY=VALUE entries for a hook
+func hookEnv(env map[string]string) []string {
+	var entries []string
+	for _, k := range envKeys(env) {
+		entries = append(entries, k+"="+env[k])
+	}
+	return entries
+}
+
+// runSessionHook runs the hooks of an event configured for the session, with its env
+func (t *TMUX) runSessionHook(event string, session *SessionConfig, sessionName string) error {
+	env := append(hookEnv(session.Env), "GRIDLOCK_SESSION_NAME="+sessionName)
+	return t.runHooks(event, session.Hooks, session.WorkingDirectory, env...)
+}
+
+// runWindowHook runs the hooks of an event configured for a window, with the env of the
+// session and window
+func (t *TMUX) runWindowHook(event string, session *SessionConfig, window *WindowConfig, sessionName string) error {
+	dir := firstNonEmpty(window.WorkingDirectory, session.WorkingDirectory)
+	env := append(hookEnv(mergeEnv(session.Env, window.Env)), "GRIDLOCK_SESSION_NAME="+sessionName, "GRIDLOCK_WINDOW_NAME="+window.Name)
+	return t.runHooks(event, window.Hooks, dir, env...)
+}
+
+// runAllHooks runs the hooks of an event for the session and all of its windows. The
+// session's run first, except for on-kill, where the windows are torn down before it.
+// Failures are reported and do not stop the remaining hooks.
+func (t *TMUX) runAllHooks(event string, session *SessionConfig, sessionName string) {
+	if event != hookOnKill {
+		if err := t.runSessionHook(event, session, sessionName); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
+	for i := range session.Windows {
+		if err := t.runWindowHook(event, session, &session.Windows[i], sessionName); err != nil {
+			log.Printf("Warning: window %s: %v", session.Windows[i].Name, err)
+		}
+	}
+	if event == hookOnKill {
+		if err := t.runSessionHook(event, session, sessionName); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
+}
+
+// killCommand runs the on-kill hooks of the session and its windows and kills it
+func killCommand(fs *flag.FlagSet) func(args []string, opts upOptions) {
+	return func(args []string, opts upOptions) {
+		config, err := loadConfig(opts.configFile)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		if err := prepareConfig(config); err != nil {
+			log.Fatalf("invalid config: %v", err)
+		}
+		sessionName := config.Session.Name
+
+		t := newTMUX(opts, config)
+		query := newTMUX(opts, config)
+		query.DryRun = false
+		if !query.sessionExists(sessionName) {
+			log.Fatalf("Session %s is not running", sessionName)
+		}
+
+		t.runAllHooks(hookOnKill, &config.Session, sessionName)
+		fmt.Printf("Killing session: %s\n", sessionName)
+		if config.Session.Scratchpad != nil {
+			t.run("kill-session", "-t", "="+scratchpadName(sessionName))
+		}
+		// Killed last, as it may be the session we are running in
+		if _, err := t.run("kill-session", "-t", "="+sessionName); err != nil {
+			log.Fatalf("Failed to kill session %s: %v", sessionName, err)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Key tables every session shares. Bindings in them are made conditional on the session,
+// while a table of the session's own can be bound as it is.
+var sharedKeyTables = map[string]bool{
+	"prefix":       true,
+	"root":         true,
+	"copy-mode":    true,
+	"copy-mode-vi": true,
+}
+
+// validateKeys checks the prefix, key table and key bindings of the session
+func validateKeys(session *SessionConfig) error {
+	if strings.ContainsAny(session.Prefix, " \t") {
+		return fmt.Errorf("invalid prefix %q, expected a single key such as C-a", session.Prefix)
+	}
+	if strings.ContainsAny(session.KeyTable, " \t") {
+		return fmt.Errorf("invalid key-table %q, table names cannot contain spaces", session.KeyTable)
+	}
+	for _, binding := range session.Keys {
+		if binding.Key == "" {
+			return fmt.Errorf("key binding %q has no key", binding.Command)
+		}
+		if binding.Command == "" {
+			return fmt.Errorf("key binding %s has no command", binding.Key)
+		}
+		if strings.ContainsAny(binding.Table, " \t") {
+			return fmt.Errorf("key binding %s: invalid table %q", binding.Key, binding.Table)
+		}
+	}
+	return nil
+}
+
+// bindKeys binds the keys of the session. Key bindings are server-wide in tmux, so keys of
+// the shared tables only run their command while the session is the current one, and run
+// whatever the key was bound to before in other sessions, and once the session is gone.
+func (t *TMUX) bindKeys(sessionName string, keys []KeyBinding) {
+	condition := sessionCondition(sessionName)
+	for _, binding := range keys {
+		table := firstNonEmpty(binding.Table, "prefix")
+		args := []string{"bind-key", "-T", table, binding.Key}
+		if sharedKeyTables[table] {
+			args = append(args, "if-shell", "-F", condition, binding.Command)
+			if previous := t.previousBinding(table, binding.Key, condition); previous != "" {
+				args = append(args, previous)
+			}
+		} else {
+			args = append(args, binding.Command)
+		}
+		if _, err := t.run(args...); err != nil {
+			log.Printf("Warning: failed to bind %s in %s: %v", binding.Key, table, err)
+		}
+	}
+}
+
+// sessionCondition returns the format that is true in the session, with the characters
+// formats give a meaning escaped in its name
+func sessionCondition(sessionName string) string {
+	escaped := strings.NewReplacer("#", "##", ",", "#,", "}", "#}").Replace(sessionName)
+	return "#{==:#{session_name}," + escaped + "}"
+}
+
+// previousBinding returns the command a key is bound to in a table, as tmux lists it, or ""
+// when it is not bound. A binding made for the same session before, which condition tells
+// apart, is replaced by the one it fell back to, so binding the keys again does not stack
+// them. Nothing is bound in dry-run mode, so there is nothing to look up.
+func (t *TMUX) previousBinding(table string, key string, condition string) string {
+	if t.DryRun {
+		return ""
+	}
+	out, err := t.run("list-keys", "-T", table, key)
+	if err != nil {
+		return ""
+	}
+	// bind-key [-r] -T <table> <key> <command>
+	word, rest := nextTMUXWord(strings.TrimSpace(out))
+	if word != "bind-key" {
+		return ""
+	}
+	for word != "-T" && rest != "" {
+		word, rest = nextTMUXWord(rest)
+	}
+	_, rest = nextTMUXWord(rest)
+	_, command := nextTMUXWord(rest)
+	return unwrapBinding(command, condition)
+}
+
+// unwrapBinding returns the command a binding made by gridlock for condition falls back to,
+// and any other command as it is
+func unwrapBinding(command string, condition string) string {
+	words := make([]string, 5)
+	rest := command
+	for i := range words {
+		words[i], rest = nextTMUXWord(rest)
+	}
+	if words[0] == "if-shell" && words[1] == "-F" && words[2] == condition {
+		return words[4]
+	}
+	return command
+}
+
+// nextTMUXWord splits the first word off a tmux command line, as tmux prints it: a word is
+// either bare or quoted in double quotes, with backslash escapes, or in single quotes. It
+// returns the word without its quotes and the rest of the line after it.
+func nextTMUXWord(line string) (string, string) {
+	line = strings.TrimLeft(line, " ")
+	var word strings.Builder
+	quote := byte(0)
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote == 0 && c == ' ':
+			return word.String(), strings.TrimLeft(line[i:], " ")
+		case quote != '\'' && c == '\\' && i+1 < len(line):
+			i++
+			word.WriteByte(line[i])
+		case quote == 0 && (c == '"' || c == '\''):
+			quote = c
+		case c == quote:
+			quote = 0
+		default:
+			word.WriteByte(c)
+		}
+	}
+	return word.String(), ""
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBindKeysFallsBackToPreviousBinding(t *testing.T) {
+	bound := map[string]string{
+		"r":  `bind-key -T prefix r refresh-client`,
+		"\"": `bind-key -r -T prefix \" split-window -c "#{pane_current_path}"`,
+		"s":  `bind-key -T prefix s if-shell -F "#{==:#{session_name},a#,b}" "display-message 'old'" "choose-tree -Zs"`,
+		"o":  `bind-key -T prefix o if-shell -F "#{==:#{session_name},other}" "display-message 'other'" refresh-client`,
+	}
+	var bindings []string
+	exec := func(args []string) (string, error) {
+		switch args[0] {
+		case "list-keys":
+			if line, ok := bound[args[3]]; ok {
+				return line + "\n", nil
+			}
+			return "", fmt.Errorf("unknown key: %s", args[3])
+		case "bind-key":
+			bindings = append(bindings, strings.Join(args[4:], " | "))
+		}
+		return "", nil
+	}
+	tmux := newTMUX(upOptions{executor: exec}, nil)
+
+	tmux.bindKeys("a,b", []KeyBinding{
+		{Key: "r", Command: "display-message r"},
+		{Key: "\"", Command: "split-window -h"},
+		{Key: "s", Command: "display-message 'new'"},
+		{Key: "o", Command: "display-message o"},
+		{Key: "F5", Command: "next-window"},
+		{Key: "F6", Table: "a-root", Command: "next-window"},
+	})
+
+	condition := "#{==:#{session_name},a#,b}"
+	want := []string{
+		"if-shell | -F | " + condition + " | display-message r | refresh-client",
+		"if-shell | -F | " + condition + ` | split-window -h | split-window -c "#{pane_current_path}"`,
+		// The binding made for the session before is replaced rather than stacked
+		"if-shell | -F | " + condition + " | display-message 'new' | choose-tree -Zs",
+		"if-shell | -F | " + condition + ` | display-message o | if-shell -F "#{==:#{session_name},other}" "display-message 'other'" refresh-client`,
+		"if-shell | -F | " + condition + " | next-window",
+		"next-window",
+	}
+	if !reflect.DeepEqual(bindings, want) {
+		t.Errorf("bindings:\n%s\nwant:\n%s", strings.Join(bindings, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestSessionCondition(t *testing.T) {
+	for name, want := range map[string]string{
+		"dev":      "#{==:#{session_name},dev}",
+		"a,b}c#1":  "#{==:#{session_name},a#,b#}c##1}",
+		"my space": "#{==:#{session_name},my space}",
+	} {
+		if got := sessionCondition(name); got != want {
+			t.Errorf("sessionCondition(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestBindMenusFallsBackToPreviousBinding(t *testing.T) {
+	var bindings []string
+	exec := func(args []string) (string, error) {
+		switch args[0] {
+		case "list-keys":
+			return "bind-key -T prefix m select-pane -m\n", nil
+		case "bind-key":
+			bindings = append(bindings, strings.Join(args[1:], " | "))
+		}
+		return "", nil
+	}
+	tmux := newTMUX(upOptions{executor: exec}, nil)
+	menu := MenuConfig{Key: "m", Items: []MenuItem{{Name: "Logs", Key: "l", Window: "logs"}}}
+	if err := tmux.bindMenus("a}b", []MenuConfig{menu}); err != nil {
+		t.Fatalf("bindMenus failed: %v", err)
+	}
+	command, _ := menuCommand("a}b", menu)
+	want := []string{"m | if-shell | -F | #{==:#{session_name},a#}b} | " + command + " | select-pane -m"}
+	if !reflect.DeepEqual(bindings, want) {
+		t.Errorf("bindings = %q, want %q", bindings, want)
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import "fmt"
+
+// Kinds of panes that hold no commands and only give a layout its structure
+const (
+	// A plain sh, without the shell of pane-defaults
+	paneKindBlank = "blank"
+	// tmux's clock-mode
+	paneKindClock = "clock"
+	// An idle process keeping the space for a pane started later with respawn-pane
+	paneKindPlaceholder = "placeholder"
+)
+
+// Processes the panes of a kind are started with, unless they set their own shell
+var paneKindShells = map[string]string{
+	paneKindBlank:       "sh",
+	paneKindPlaceholder: "tail -f /dev/null",
+}
+
+// validatePaneKinds checks the kind of every pane. Panes of a kind have nothing to run, so
+// they cannot have commands.
+func validatePaneKinds(config *Config) error {
+	for _, window := range config.Session.Windows {
+		for _, pane := range window.Panes {
+			switch pane.Kind {
+			case "":
+				continue
+			case paneKindBlank, paneKindClock, paneKindPlaceholder:
+			default:
+				return fmt.Errorf("window %s: pane %s: unknown kind %q, expected blank, clock or placeholder", window.Name, pane.Name, pane.Kind)
+			}
+			if pane.Command != "" || len(pane.Commands) > 0 || len(pane.Send) > 0 || pane.AdoptPID > 0 {
+				return fmt.Errorf("window %s: pane %s: a %s pane cannot have commands", window.Name, pane.Name, pane.Kind)
+			}
+		}
+	}
+	return nil
+}
+
+// applyPaneKinds gives the panes of a kind their process. It runs before the pane-defaults
+// are applied, so the shell of pane-defaults does not replace it.
+func applyPaneKinds(config *Config) {
+	for i := range config.Session.Windows {
+		window := &config.Session.Windows[i]
+		for j := range window.Panes {
+			pane := &window.Panes[j]
+			if pane.Shell == "" {
+				pane.Shell = paneKindShells[pane.Kind]
+			}
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/esaiaswestberg/gridlock/pkg/schema"
+)
+
+// runningSessions lists the sessions of the tmux server with the metadata gridlock recorded
+func (t *TMUX) runningSessions() ([]schema.RunningSession, error) {
+	// Both the config path and the session name may contain spaces, so the path is preceded by its length
+	format := "#{session_windows} #{session_attached} #{n:" + metadataConfigFile + "} #{" + metadataConfigFile + "}#{session_name}"
+	out, err := t.run("list-sessions", "-F", format)
+	if err != nil {
+		// tmux fails when no server is running, which means no sessions
+		if strings.Contains(out, "no server running") || strings.Contains(out, "error connecting") {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var sessions []schema.RunningSession
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		parts := strings.SplitN(line, " ", 4)
+		if len(parts) < 4 {
+			continue
+		}
+		windows, _ := strconv.Atoi(parts[0])
+		attached, _ := strconv.Atoi(parts[1])
+		n, err := strconv.Atoi(parts[2])
+		if err != nil || n > len(parts[3]) {
+			continue
+		}
+		session := schema.RunningSession{
+			Name:       parts[3][n:],
+			Windows:    windows,
+			Attached:   attached,
+			ConfigFile: parts[3][:n],
+		}
+		session.BaseSession = t.sessionMetadata(session.Name, metadataBaseSession)
+		session.ScratchpadOf = t.sessionMetadata(session.Name, metadataScratchpadOf)
+		session.Gridlock = session.ConfigFile != "" || session.ScratchpadOf != ""
+		sessions = append(sessions, session)
+	}
+	return sessions, nil
+}
+
+// listCommand lists all sessions of the tmux server, marking those created by gridlock
+func listCommand(fs *flag.FlagSet) func(args []string, opts upOptions) {
+	jsonOutput := fs.Bool("json", false, "Print the sessions as JSON")
+	return func(args []string, opts upOptions) {
+		query := newTMUX(opts, nil)
+		query.DryRun = false
+		running, err := query.runningSessions()
+		if err != nil {
+			log.Fatalf("failed to list sessions: %v", err)
+		}
+		sessions := schema.Sessions{SchemaVersion: schema.Version, Sessions: []schema.RunningSession{}}
+		sessions.Sessions = append(sessions.Sessions, running...)
+
+		if *jsonOutput {
+			if err := schema.Write(os.Stdout, sessions); err != nil {
+				log.Fatalf("failed to write json: %v", err)
+			}
+			return
+		}
+
+		out := newOutput()
+		t := out.table("")
+		for _, s := range sessions.Sessions {
+			state := ""
+			if s.Attached > 0 {
+				state = out.style(styleGreen, "attached")
+			}
+			origin := "-"
+			switch {
+			case s.ScratchpadOf != "":
+				origin = "scratchpad of " + s.ScratchpadOf
+			case s.ConfigFile != "":
+				origin = s.ConfigFile
+				if s.BaseSession != "" {
+					origin += " (copy of " + s.BaseSession + ")"
+				}
+			}
+			t.row(out.style(styleBold, s.Name), fmt.Sprintf("%d windows", s.Windows), state, origin)
+		}
+		t.flush()
+	}
+}
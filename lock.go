@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// lockFile records the versions of the tools a project's session was last brought up with
+// by gridlock --lock. It is meant to be committed, so a team notices environment drift.
+type lockFile struct {
+	TMUX     string `yaml:"tmux"`
+	Shell    string `yaml:"shell"`
+	Gridlock string `yaml:"gridlock"`
+}
+
+// lockPath returns the lockfile of a configuration file, e.g. .gridlock.lock next to
+// .gridlock.yaml
+func lockPath(configFile string) string {
+	return strings.TrimSuffix(configFile, filepath.Ext(configFile)) + ".lock"
+}
+
+// gridlockVersion returns the module version gridlock was built as, (devel) for builds
+// from a checkout
+func gridlockVersion() string {
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		return info.Main.Version
+	}
+	return "(devel)"
+}
+
+// toolVersion returns the first line a program prints for --version, or "" when it does
+// not tell
+func toolVersion(name string, args ...string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, name, args...).Output()
+	if err != nil {
+		return ""
+	}
+	line, _, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+	return line
+}
+
+// currentLock returns the versions of the tools in use. The shell is the one panes start
+// with by default, as the versions of shells configured for single panes rarely matter.
+func currentLock(session *SessionConfig) lockFile {
+	shell := firstNonEmpty(session.PaneDefaults.Shell, os.Getenv("SHELL"), "sh")
+	if version := toolVersion(shell, "--version"); version != "" {
+		shell += " (" + version + ")"
+	}
+	return lockFile{
+		TMUX:     firstNonEmpty(toolVersion("tmux", "-V"), "unknown"),
+		Shell:    shell,
+		Gridlock: gridlockVersion(),
+	}
+}
+
+// checkLock warns about the tools whose versions differ from the configuration's lockfile,
+// or records the current versions in it when write is set. Without a lockfile there is
+// nothing to check, and the versions are not looked up, as that runs the tools.
+func checkLock(configFile string, session *SessionConfig, write bool) {
+	path := lockPath(configFile)
+	if write {
+		data, err := yaml.Marshal(currentLock(session))
+		if err == nil {
+			err = os.WriteFile(path, data, 0644)
+		}
+		if err != nil {
+			log.Printf("Warning: failed to write lockfile: %v", err)
+			return
+		}
+		fmt.Printf("Recorded tool versions in %s\n", path)
+		return
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	var locked lockFile
+	if err := yaml.Unmarshal(data, &locked); err != nil {
+		log.Printf("Warning: invalid lockfile %s: %v", path, err)
+		return
+	}
+	current := currentLock(session)
+	drift := false
+	for _, tool := range []struct{ name, locked, current string }{
+		{"tmux", locked.TMUX, current.TMUX},
+		{"shell", locked.Shell, current.Shell},
+		{"gridlock", locked.Gridlock, current.Gridlock},
+	} {
+		if tool.locked != "" && tool.locked != tool.current {
+			log.Printf("Warning: %s is %s, but %s was locked with %s", tool.name, tool.current, path, tool.locked)
+			drift = true
+		}
+	}
+	if drift {
+		log.Printf("Update the lockfile with gridlock --lock if the new versions are intended")
+	}
+}
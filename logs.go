@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// paneLogPath returns the file the output of a pane with log: true is appended to
+func paneLogPath(sessionName string, windowName string, paneName string) (string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "logs", slugify(sessionName), slugify(windowName)+"-"+slugify(paneName)+".log"), nil
+}
+
+// startPaneLog appends everything a pane prints to its log file with pipe-pane, so its
+// output can be read with gridlock logs without attaching. It is started before the pane's
+// commands so their first lines are kept.
+func (t *TMUX) startPaneLog(paneTarget string, window *WindowConfig, pane *PaneConfig) error {
+	sessionName := "#{session_name}"
+	if !t.DryRun && t.Executor == nil {
+		out, err := t.run("display-message", "-p", "-t", paneTarget, "#{session_name}")
+		if err != nil {
+			return err
+		}
+		sessionName = strings.TrimSpace(out)
+	}
+	path, err := paneLogPath(sessionName, window.Name, pane.Name)
+	if err != nil {
+		return err
+	}
+	if !t.DryRun && t.Executor == nil {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+	}
+	_, err = t.run("pipe-pane", "-o", "-t", paneTarget, "cat >> "+shellQuote(path))
+	return err
+}
+
+// findConfigPane finds a pane of the configuration by its name, or by window/pane when
+// windows share pane names
+func findConfigPane(config *Config, name string) (*WindowConfig, *PaneConfig, error) {
+	windowName, paneName, qualified := strings.Cut(name, "/")
+	if !qualified {
+		paneName, windowName = windowName, ""
+	}
+	var foundWindow *WindowConfig
+	var foundPane *PaneConfig
+	for i := range config.Session.Windows {
+		window := &config.Session.Windows[i]
+		if windowName != "" && window.Name != windowName {
+			continue
+		}
+		for j := range window.Panes {
+			if window.Panes[j].Name != paneName {
+				continue
+			}
+			if foundPane != nil {
+				return nil, nil, fmt.Errorf("pane %s is in windows %s and %s, name it as window/pane", paneName, foundWindow.Name, window.Name)
+			}
+			foundWindow, foundPane = window, &window.Panes[j]
+		}
+	}
+	if foundPane == nil {
+		return nil, nil, fmt.Errorf("no pane %s in the configuration", name)
+	}
+	return foundWindow, foundPane, nil
+}
+
+// tailFile prints the last lines of a file, and with follow keeps printing what is appended
+// to it until interrupted
+func tailFile(path string, lines int, follow bool) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var last []string
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		last = append(last, scanner.Text())
+		if len(last) > lines {
+			last = last[1:]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	for _, line := range last {
+		fmt.Println(line)
+	}
+	if !follow {
+		return nil
+	}
+
+	offset, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	for {
+		time.Sleep(500 * time.Millisecond)
+		info, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		if info.Size() < offset {
+			// Truncated, start over from its beginning
+			offset, _ = f.Seek(0, io.SeekStart)
+		}
+		n, err := io.Copy(os.Stdout, f)
+		if err != nil {
+			return err
+		}
+		offset += n
+	}
+}
+
+// logsCommand prints the output of a pane from outside tmux: its log file for panes with
+// log: true, or else what the pane shows, including its scrollback
+func logsCommand(fs *flag.FlagSet) func(args []string, opts upOptions) {
+	follow := fs.Bool("follow", false, "Keep printing the output appended to the pane's log")
+	fs.BoolVar(follow, "F", false, "Keep printing the output appended to the pane's log (shorthand)")
+	lines := fs.Int("n", 50, "Number of lines to print")
+	return func(args []string, opts upOptions) {
+		if len(args) > 0 {
+			fs.Parse(args[1:])
+			args = append(args[:1], fs.Args()...)
+		}
+		if len(args) != 1 {
+			log.Fatalf("Usage: gridlock logs [window/]pane [--follow] [-n lines]")
+		}
+		config, err := loadConfig(opts.configFile)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		if err := prepareConfig(config); err != nil {
+			log.Fatalf("invalid config: %v", err)
+		}
+		window, pane, err := findConfigPane(config, args[0])
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		sessionName := config.Session.Name
+
+		path, err := paneLogPath(sessionName, window.Name, pane.Name)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		if _, err := os.Stat(path); err == nil {
+			if err := tailFile(path, *lines, *follow); err != nil {
+				log.Fatalf("Failed to read %s: %v", path, err)
+			}
+			return
+		}
+		if *follow {
+			log.Fatalf("Pane %s has no log to follow, set log: true on it", pane.Name)
+		}
+
+		t := newTMUX(opts, config)
+		t.DryRun = false
+		out, err := t.run("list-panes", "-s", "-t", "="+sessionName, "-F", "#{pane_id}\t#{"+metadataWindowName+"}\t#{"+metadataPaneName+"}")
+		if err != nil {
+			log.Fatalf("Session %s is not running", sessionName)
+		}
+		for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+			fields := strings.Split(line, "\t")
+			if len(fields) != 3 || fields[1] != window.Name || fields[2] != pane.Name {
+				continue
+			}
+			contents, err := t.run("capture-pane", "-p", "-J", "-S", "-", "-t", fields[0])
+			if err != nil {
+				log.Fatalf("Failed to capture pane %s: %v", pane.Name, err)
+			}
+			// The empty lines below the prompt are not output
+			captured := strings.Split(strings.TrimRight(contents, "\n"), "\n")
+			for _, line := range captured[max(len(captured)-*lines, 0):] {
+				fmt.Println(line)
+			}
+			return
+		}
+		log.Fatalf("Pane %s is not in the running session %s", pane.Name, sessionName)
+	}
+}
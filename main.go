@@ -1,106 +1,79 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
-	"regexp"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/esaiaswestberg/gridlock/pkg/builder"
+	"github.com/esaiaswestberg/gridlock/pkg/capture"
+	"github.com/esaiaswestberg/gridlock/pkg/config"
+	"github.com/esaiaswestberg/gridlock/pkg/tmux"
 	"gopkg.in/yaml.v3"
 )
 
-type Config struct {
-	Session SessionConfig `yaml:"session"`
-}
-
-type SessionConfig struct {
-	Name             string         `yaml:"name"`
-	WorkingDirectory string         `yaml:"working-directory,omitempty"`
-	Windows          []WindowConfig `yaml:"windows,omitempty"`
-}
-
-type WindowConfig struct {
-	Name             string       `yaml:"name"`
-	WorkingDirectory string       `yaml:"working-directory,omitempty"`
-	Panes            []PaneConfig `yaml:"panes,omitempty"`
-	Layout           LayoutNode   `yaml:"layout,omitempty"`
-}
-
-type PaneConfig struct {
-	Name             string   `yaml:"name"`
-	WorkingDirectory string   `yaml:"working-directory,omitempty"`
-	Command          string   `yaml:"command,omitempty"`
-	Commands         []string `yaml:"commands,omitempty"`
-}
-
-type LayoutNode struct {
-	PaneName string       `yaml:"pane,omitempty"`
-	Columns  []LayoutNode `yaml:"columns,omitempty"`
-	Rows     []LayoutNode `yaml:"rows,omitempty"`
-}
+// The configuration types live in pkg/config, so other Go tools can read and write
+// gridlock configurations
+type (
+	Config           = config.Config
+	SessionConfig    = config.SessionConfig
+	WindowConfig     = config.WindowConfig
+	PaneConfig       = config.PaneConfig
+	Commands         = config.Commands
+	CommandEntry     = config.CommandEntry
+	SendKeys         = config.SendKeys
+	ScheduleEntry    = config.ScheduleEntry
+	CopyModeConfig   = config.CopyModeConfig
+	PaneDefaults     = config.PaneDefaults
+	LayoutNode       = config.LayoutNode
+	MenuConfig       = config.MenuConfig
+	MenuItem         = config.MenuItem
+	KeyBinding       = config.KeyBinding
+	ScratchpadConfig = config.ScratchpadConfig
+	Component        = config.Component
+	Hooks            = config.Hooks
+)
 
-func (n *LayoutNode) UnmarshalYAML(value *yaml.Node) error {
-	if value.Kind == yaml.ScalarNode {
-		return value.Decode(&n.PaneName)
-	}
-	var m map[string][]LayoutNode
-	if err := value.Decode(&m); err != nil {
-		return err
-	}
-	if cols, ok := m["columns"]; ok {
-		n.Columns = cols
-	}
-	if rows, ok := m["rows"]; ok {
-		n.Rows = rows
-	}
-	return nil
+// TMUX runs the tmux commands of a gridlock run, see pkg/tmux
+type TMUX struct {
+	*tmux.Client
+	// Index of the first pane of a window, see paneBaseIndex
+	paneBase int
+	// Commands longer than this are sent as scripts, see sendCommand
+	scriptThreshold int
+	// Options gridlock runs with, which create provisions the session with
+	opts upOptions
 }
 
-func (n LayoutNode) MarshalYAML() (interface{}, error) {
-	if n.PaneName != "" {
-		return n.PaneName, nil
+// newTMUX returns a TMUX for the server selected by the --socket flag or the configuration
+func newTMUX(opts upOptions, config *Config) *TMUX {
+	socket := opts.socket
+	if socket == "" && config != nil {
+		socket = config.Session.Socket
 	}
-	m := make(map[string][]LayoutNode)
-	if len(n.Columns) > 0 {
-		m["columns"] = n.Columns
+	client := &tmux.Client{DryRun: opts.dryRun, Verbose: opts.verbose, Timeout: opts.timeout, Retries: opts.retries, Socket: socket, Executor: opts.executor}
+	t := &TMUX{Client: client, scriptThreshold: defaultScriptThreshold, opts: opts}
+	if config != nil && config.Session.ScriptThreshold != 0 {
+		t.scriptThreshold = config.Session.ScriptThreshold
 	}
-	if len(n.Rows) > 0 {
-		m["rows"] = n.Rows
-	}
-	return m, nil
-}
-
-type TMUX struct {
-	dryRun bool
+	return t
 }
 
 func (t *TMUX) run(args ...string) (string, error) {
-	if t.dryRun {
-		fmt.Printf("tmux %s\n", strings.Join(args, " "))
-		return "", nil
-	}
-	cmd := exec.Command("tmux", args...)
-	out, err := cmd.CombinedOutput()
-	if err != nil {
-		return string(out), fmt.Errorf("tmux %s failed: %v\nOutput: %s", strings.Join(args, " "), err, string(out))
-	}
-	return string(out), nil
+	return t.Run(context.Background(), args...)
 }
 
 func main() {
-	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, "Usage of %s:\n", os.Args[0])
-		fmt.Fprintf(os.Stderr, "  --config, -f string\n        Path to the configuration file (default \".gridlock.yaml\")\n")
-		fmt.Fprintf(os.Stderr, "  --detached, -d\n        Do not attach to the session\n")
-		fmt.Fprintf(os.Stderr, "  --current, -c\n        Create windows from the configuration in the current TMUX session instead of a new one\n")
-		fmt.Fprintf(os.Stderr, "  --recreate\n        Recreate the session. If run from within the target session, it cleans and rebuilds it without exiting\n")
-		fmt.Fprintf(os.Stderr, "  --dry-run\n        Print commands without executing them\n")
-	}
+	flag.Usage = usage
 	configFile := flag.String("config", ".gridlock.yaml", "Path to the configuration file")
 	flag.String("f", ".gridlock.yaml", "Path to the configuration file (shorthand)")
 	detached := flag.Bool("detached", false, "Do not attach to the session")
@@ -108,109 +81,439 @@ func main() {
 	current := flag.Bool("current", false, "Create windows from the configuration in the current TMUX session instead of a new one")
 	flag.Bool("c", false, "Create windows in the current TMUX session (shorthand)")
 	recreate := flag.Bool("recreate", false, "Recreate the session. If run from within the target session, it cleans and rebuilds it without exiting")
-	dryRun := flag.Bool("dry-run", false, "Print commands without executing them")
+	inPlace := flag.Bool("in-place", false, "With --recreate, rebuild the windows inside the running session instead of killing it, so attached clients stay attached")
+	recreateIfChanged := flag.Bool("recreate-if-changed", false, "Recreate the session only if the configuration changed since it was created")
+	appendWindows := flag.Bool("append", false, "Add the configured windows that are missing from an existing session, leaving the windows it has alone")
+	forceNew := flag.Bool("force-new", false, "Create a new session with a numbered name if one with the configured name exists")
+	renameExisting := flag.String("rename-existing", "", "Rename an existing session with the configured name to <name>-<pattern> (%Y, %m, %d, %H, %M and %S are expanded) and create the session anew")
+	detachOthers := flag.Bool("detach-others", false, "Detach other clients from the session when attaching")
+	socket := flag.String("socket", "", "Socket name of the tmux server to use (tmux -L)")
+	flag.String("L", "", "Socket name of the tmux server to use (shorthand)")
+	root := flag.String("root", "", "Directory the session's working directories are resolved against, for a configuration stored elsewhere")
+	flag.StringVar(root, "from-dir", "", "Same as --root")
+	backend := flag.String("backend", "", "Terminal multiplexer to bring the session up in, tmux (default), zellij or screen")
+	var dryRun dryRunFlag
+	flag.Var(&dryRun, "dry-run", "Print commands without executing them, or with --dry-run=script a shell script running them")
+	all := flag.Bool("all", false, "Bring up the sessions of all registered projects in parallel, detached")
+	noCommands := flag.Bool("no-commands", false, "Create windows and panes without running their commands (see gridlock run-commands)")
+	wait := flag.Bool("wait", false, "Wait until the wait-for and verify checks of all panes pass, exiting with an error if they do not within --wait-timeout")
+	waitTimeout := flag.Duration("wait-timeout", 2*time.Minute, "How long --wait waits for the panes to be ready")
+	timeout := flag.Duration("timeout", tmux.DefaultTimeout, "Timeout for each tmux command")
+	retries := flag.Int("retries", 2, "Number of retries for transient tmux failures")
+	verbose := flag.Bool("verbose", false, "Report retries and slow tmux commands")
+	flag.Bool("v", false, "Report retries and slow tmux commands (shorthand)")
+	profileCPU := flag.String("profile-cpu", "", "Write a CPU profile of gridlock itself to the file")
+	traceFile := flag.String("trace", "", "Write an execution trace of gridlock itself to the file")
+	fastAttach := flag.Bool("fast-attach", false, "Attach as soon as the first pane exists and provision the rest of a new session in the background")
+	printAttachCommand := flag.Bool("print-attach-command", false, "Create the session detached and print only the command that attaches to it, for terminal emulator launchers")
+	lock := flag.Bool("lock", false, "Record the tmux, shell and gridlock versions in the configuration's lockfile")
+	controlMode := flag.Bool("control-mode", false, "Build the session through one tmux client in control mode instead of a tmux process per command")
+	resumeWindow := flag.String("resume-window", "", "Continue provisioning the session of the window, created with --fast-attach (started by gridlock)")
+	flag.Var(templateVars, "var", "Set a variable for ${NAME} expansion in the configuration, KEY=VALUE (repeatable)")
+	if err := applyEnv(flag.CommandLine); err != nil {
+		log.Fatalf("%v", err)
+	}
 	flag.Parse()
+	// "gridlock up [flags]" is the same as "gridlock [flags]"
+	if flag.Arg(0) == "up" {
+		flag.CommandLine.Parse(flag.Args()[1:])
+	}
+
+	stopProfiling := startProfiling(*profileCPU, *traceFile)
+	defer stopProfiling()
 
-	if flag.Arg(0) == "init" {
-		initCmd := flag.NewFlagSet("init", flag.ExitOnError)
-		saveCurrent := initCmd.Bool("save-current", false, "Save the current TMUX session to the config file")
-		initCmd.Parse(flag.Args()[1:])
+	// Handle shorthands manually because flag package is limited
+	for i, arg := range os.Args {
+		if arg == "-f" && i+1 < len(os.Args) {
+			*configFile = os.Args[i+1]
+		}
+		if arg == "-d" {
+			*detached = true
+		}
+		if arg == "-c" {
+			*current = true
+		}
+		if arg == "-v" {
+			*verbose = true
+		}
+		if arg == "-L" && i+1 < len(os.Args) {
+			*socket = os.Args[i+1]
+		}
+	}
+
+	opts := upOptions{
+		configFile:        *configFile,
+		detached:          *detached,
+		current:           *current,
+		recreate:          *recreate,
+		recreateIfChanged: *recreateIfChanged,
+		inPlace:           *inPlace,
+		forceNew:          *forceNew,
+		appendWindows:     *appendWindows,
+		renameExisting:    *renameExisting,
+		detachOthers:      *detachOthers,
+		dryRun:            dryRun != "",
+		dryRunScript:      dryRun == dryRunScript,
+		noCommands:        *noCommands,
+		wait:              *wait,
+		waitTimeout:       *waitTimeout,
+		all:               *all,
+		timeout:           *timeout,
+		retries:           *retries,
+		verbose:           *verbose,
+		socket:            *socket,
+		backend:           *backend,
+		root:              *root,
+		fastAttach:        *fastAttach,
+		resumeWindow:      *resumeWindow,
+		controlMode:       *controlMode,
+		lock:              *lock,
+	}
+	if *printAttachCommand {
+		// Progress messages go to standard error, leaving only the command on standard output
+		opts.attachCommandTo = os.Stdout
+		os.Stdout = os.Stderr
+	}
+
+	cmd, ok := findCommand(flag.Arg(0))
+	if !ok || cmd.name == "up" {
+		if shouldPick(opts, flag.Args(), ".gridlock.yaml") {
+			runPicker(opts)
+			return
+		}
+		up(opts)
+		return
+	}
+	fs := flag.NewFlagSet(cmd.name, flag.ExitOnError)
+	run := cmd.setup(fs)
+	if err := applyEnv(fs); err != nil {
+		log.Fatalf("%v", err)
+	}
+	fs.Parse(flag.Args()[1:])
+	run(fs.Args(), opts)
+}
 
+func initCommand(fs *flag.FlagSet) func(args []string, opts upOptions) {
+	saveCurrent := fs.Bool("save-current", false, "Save the current TMUX session to the config file")
+	paneNames := fs.String("pane-names", capture.PaneNamesCommand, "With --save-current, how to name panes: command (<window>-<command or directory>) or index (<window>-pane-<n>)")
+	wrappers := fs.String("wrappers", "", "With --save-current, comma separated wrapper processes whose child is captured as the pane command (default $GRIDLOCK_WRAPPERS or reattach-to-user-namespace)")
+	copyMode := fs.Bool("copy-mode", false, "With --save-current, record panes in copy-mode and their scroll position")
+	fullCommand := fs.Bool("full-command", false, "With --save-current, record the command line panes run (e.g. npm run dev) instead of only the program")
+	session := fs.String("session", "", "Save the named TMUX session instead of the current one (implies --save-current)")
+	splitConfigs := fs.Bool("split-configs", false, "With --save-current, write one config per project root plus a workspace config referencing them")
+	return func(args []string, opts upOptions) {
 		wd, err := os.Getwd()
 		if err != nil {
 			log.Fatalf("failed to get working directory: %v", err)
 		}
-		
+
 		var config *Config
 		var sessionName string
 
+		if *session != "" {
+			*saveCurrent = true
+		}
+
 		if *saveCurrent {
-			// Check if we are in tmux or have a session attached
-			// We can try to guess the session name from TMUX env var if set, or just capture the attached session.
-			// Actually, if we run `tmux display-message -p '#S'`, it returns the current session if attached/inside.
-			
-			t := &TMUX{dryRun: false}
-			out, err := t.run("display-message", "-p", "#S")
-			if err != nil {
-				log.Fatalf("Failed to get current session: %v. Are you inside or attached to a TMUX session?", err)
+			t := newTMUX(opts, nil)
+			t.DryRun = false
+			currentSession := *session
+			if currentSession == "" {
+				// Check if we are in tmux or have a session attached
+				// We can try to guess the session name from TMUX env var if set, or just capture the attached session.
+				// Actually, if we run `tmux display-message -p '#S'`, it returns the current session if attached/inside.
+				out, err := t.run("display-message", "-p", "#S")
+				if err != nil {
+					log.Fatalf("Failed to get current session: %v. Are you inside or attached to a TMUX session? Use --session to name one", err)
+				}
+				currentSession = strings.TrimSpace(out)
 			}
-			currentSession := strings.TrimSpace(out)
-			
+
 			fmt.Printf("Capturing session: %s\n", currentSession)
-			config, err = captureCurrentSession(currentSession)
+			config, err = capture.SessionWith(currentSession, capture.Options{CopyMode: *copyMode, FullCommand: *fullCommand, PaneNames: *paneNames, Wrappers: captureWrappers(*wrappers), Run: t.run})
 			if err != nil {
 				log.Fatalf("Failed to capture session: %v", err)
 			}
 			sessionName = currentSession
 		} else {
 			sessionName = filepath.Base(wd)
-			config = &Config{
-				Session: SessionConfig{
-					Name: sessionName,
-					Windows: []WindowConfig{
-						{
-							Name: "main",
-							Panes: []PaneConfig{
-								{
-									Name:    "bash",
-									Command: "echo Gridlock",
-								},
-							},
-							Layout: LayoutNode{
-								Columns: []LayoutNode{
-									{PaneName: "bash"},
-								},
-							},
-						},
-					},
-				},
-			}
-		}
-
-		var buf strings.Builder
-		enc := yaml.NewEncoder(&buf)
-		enc.SetIndent(2)
-		if err := enc.Encode(config); err != nil {
-			log.Fatalf("failed to marshal yaml: %v", err)
+			config = defaultConfig(sessionName)
 		}
-		data := []byte(buf.String())
 
 		if _, err := os.Stat(".gridlock.yaml"); err == nil {
 			log.Fatalf(".gridlock.yaml already exists")
 		}
 
-		if err := os.WriteFile(".gridlock.yaml", data, 0644); err != nil {
-			log.Fatalf("failed to write config: %v", err)
+		if *saveCurrent && *splitConfigs {
+			if err := writeSplitConfigs(config, ".gridlock.yaml"); err != nil {
+				log.Fatalf("%v", err)
+			}
+			fmt.Printf("Initialized workspace .gridlock.yaml for session: %s\n", sessionName)
+			return
+		}
+
+		if err := writeConfig(".gridlock.yaml", config); err != nil {
+			log.Fatalf("%v", err)
 		}
 
 		fmt.Printf("Initialized .gridlock.yaml with session name: %s\n", sessionName)
-		return
 	}
+}
 
-	// Handle shorthands manually because flag package is limited
-	for i, arg := range os.Args {
-		if arg == "-f" && i+1 < len(os.Args) {
-			*configFile = os.Args[i+1]
+func defaultConfig(sessionName string) *Config {
+	return &Config{
+		Session: SessionConfig{
+			Name: sessionName,
+			Windows: []WindowConfig{
+				{
+					Name: "main",
+					Panes: []PaneConfig{
+						{
+							Name:    "bash",
+							Command: "echo Gridlock",
+						},
+					},
+					Layout: LayoutNode{
+						Columns: []LayoutNode{
+							{PaneName: "bash"},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func writeConfig(path string, config *Config) error {
+	var buf strings.Builder
+	if err := encodeConfig(&buf, config); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, []byte(buf.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write config: %v", err)
+	}
+	return nil
+}
+
+// encodeConfig writes a configuration as YAML in the style of gridlock init
+func encodeConfig(w io.Writer, config *Config) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(config); err != nil {
+		return fmt.Errorf("failed to marshal yaml: %v", err)
+	}
+	return nil
+}
+
+func loadConfig(path string) (*Config, error) {
+	data, err := readConfigFile(resolveConfigPath(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %v", err)
+	}
+
+	if err := preflightConfig(data); err != nil {
+		return nil, fmt.Errorf("invalid config:\n%v", err)
+	}
+	var config Config
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse yaml: %v", err)
+	}
+	if err := loadLocalOverlay(&config, path); err != nil {
+		return nil, err
+	}
+	if err := loadComponentFiles(&config, path); err != nil {
+		return nil, err
+	}
+	if err := expandVars(&config); err != nil {
+		return nil, err
+	}
+	if err := resolveRoot(&config, resolveConfigPath(path)); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// prepareConfig resolves shorthands and inherited settings so the rest of gridlock only
+// deals with fully specified windows and panes
+func prepareConfig(config *Config) error {
+	if err := expandComponents(config); err != nil {
+		return err
+	}
+	if err := validateTuning(&config.Session); err != nil {
+		return err
+	}
+	if err := validateKeys(&config.Session); err != nil {
+		return err
+	}
+	if err := validateEnvScope(&config.Session); err != nil {
+		return err
+	}
+	if err := validateFocus(config); err != nil {
+		return err
+	}
+	if err := validateSend(config); err != nil {
+		return err
+	}
+	if err := validateCommands(config); err != nil {
+		return err
+	}
+	if err := validatePaneKinds(config); err != nil {
+		return err
+	}
+	if err := validateSchedules(config); err != nil {
+		return err
+	}
+	if err := validateTools(config); err != nil {
+		return err
+	}
+	if err := validateOnExit(config); err != nil {
+		return err
+	}
+	if err := compileReadinessChecks(config); err != nil {
+		return err
+	}
+	for i := range config.Session.Windows {
+		window := &config.Session.Windows[i]
+		if err := expandPanesFromCommand(window, config.Session.WorkingDirectory); err != nil {
+			return fmt.Errorf("window %s: %v", window.Name, err)
 		}
-		if arg == "-d" {
-			*detached = true
+		// Generated panes cannot be referenced by a handwritten layout, so lay them out in a grid
+		if window.PanesFromCommand != "" && window.Grid == "" && window.Layout.IsZero() && len(window.Panes) > 0 {
+			window.Grid = autoGrid(len(window.Panes))
 		}
-		if arg == "-c" {
-			*current = true
+	}
+	applyPaneKinds(config)
+	applyPaneDefaults(config)
+	applyTools(config)
+	if err := validateEphemeral(config); err != nil {
+		return err
+	}
+	if err := validateAdopt(config); err != nil {
+		return err
+	}
+	if err := resolveWorkingDirectoryCmds(config); err != nil {
+		return err
+	}
+	for i := range config.Session.Windows {
+		window := &config.Session.Windows[i]
+		if err := resolveLayoutPresets(window); err != nil {
+			return fmt.Errorf("window %s: %v", window.Name, err)
+		}
+		if window.Grid != "" && window.Layout.IsZero() {
+			layout, err := gridLayout(window.Grid, window.Panes)
+			if err != nil {
+				return fmt.Errorf("window %s: %v", window.Name, err)
+			}
+			window.Layout = layout
+		}
+		// Session transforms, e.g. from a local overlay, apply before the window's own
+		transforms := strings.Trim(config.Session.Transform+","+window.Transform, ",")
+		if transforms != "" {
+			layout, err := transformLayout(window.Layout, transforms)
+			if err != nil {
+				return fmt.Errorf("window %s: %v", window.Name, err)
+			}
+			window.Layout = layout
+			if window.LayoutSmall, err = transformLayout(window.LayoutSmall, transforms); err != nil {
+				return fmt.Errorf("window %s: %v", window.Name, err)
+			}
+		}
+		for _, layout := range []*LayoutNode{&window.Layout, &window.LayoutSmall} {
+			if err := normalizeLayoutSizes(layout, config.Session.StrictSizes); err != nil {
+				return fmt.Errorf("window %s: %v", window.Name, err)
+			}
 		}
 	}
+	return nil
+}
+
+type upOptions struct {
+	configFile        string
+	detached          bool
+	current           bool
+	recreate          bool
+	recreateIfChanged bool
+	inPlace           bool
+	forceNew          bool
+	renameExisting    string
+	detachOthers      bool
+	dryRun            bool
+	// Write the commands as a script instead of printing them, see writeDryRunScript
+	dryRunScript  bool
+	noCommands    bool
+	wait          bool
+	waitTimeout   time.Duration
+	all           bool
+	timeout       time.Duration
+	retries       int
+	verbose       bool
+	socket        string
+	backend       string
+	root          string
+	fastAttach    bool
+	resumeWindow  string
+	sync          bool
+	appendWindows bool
+	prune         bool
+	rebuild       bool
+	restartWindow string
+	controlMode   bool
+	lock          bool
+	// Receives the command that attaches to the session instead of attaching, see --print-attach-command
+	attachCommandTo io.Writer
+	// Replaces the tmux binary, see gridlock test
+	executor func(args []string) (string, error)
+}
 
-	data, err := os.ReadFile(*configFile)
+// up creates (or attaches to) the session described by the configuration file
+func up(opts upOptions) {
+	if opts.dryRunScript {
+		if opts.all {
+			log.Fatalf("--dry-run=script writes the script of a single session and cannot be used with --all")
+		}
+		writeDryRunScript(opts)
+		return
+	}
+	if opts.all {
+		upAll(opts)
+		return
+	}
+
+	config, err := loadConfig(opts.configFile)
 	if err != nil {
-		log.Fatalf("failed to read config: %v", err)
+		log.Fatalf("%v", err)
 	}
 
-	var config Config
-	if err := yaml.Unmarshal(data, &config); err != nil {
-		log.Fatalf("failed to parse yaml: %v", err)
+	if len(config.Projects) > 0 {
+		upWorkspace(config, opts)
+		return
+	}
+	// Windows and panes taken from components are rooted as well, so they are expanded first.
+	// --root takes precedence over the session's root
+	if err := expandComponents(config); err != nil {
+		log.Fatalf("invalid config: %v", err)
+	}
+	if root := firstNonEmpty(opts.root, config.Session.Root); root != "" {
+		if err := applyRoot(config, root); err != nil {
+			log.Fatalf("%v", err)
+		}
+	}
+	if err := prepareConfig(config); err != nil {
+		log.Fatalf("invalid config: %v", err)
+	}
+	backend, err := backendName(opts, config)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	if backend != backendTMUX && opts.executor == nil {
+		upBackend(newMultiplexer(backend, opts, config), config, opts)
+		return
+	}
+	if opts.resumeWindow == "" && opts.executor == nil {
+		checkLock(opts.configFile, &config.Session, opts.lock && !opts.dryRun)
 	}
 
-	t := &TMUX{dryRun: *dryRun}
+	t := newTMUX(opts, config)
 	sessionName := config.Session.Name
 
 	inTMUX := os.Getenv("TMUX") != ""
@@ -222,7 +525,7 @@ func main() {
 		}
 	}
 
-	useCurrent := *current
+	useCurrent := opts.current
 	if useCurrent {
 		if !inTMUX {
 			log.Fatalf("Not inside a TMUX session. Cannot use --current")
@@ -230,18 +533,108 @@ func main() {
 		sessionName = currentSession
 	}
 
+	// Layouts are chosen for, and checked against, the terminal that will attach. Without one,
+	// the session gets tmux's default size.
+	width, height, sizeKnown := t.clientSize(inTMUX)
+	if !sizeKnown {
+		width, height = defaultWindowWidth, defaultWindowHeight
+	}
+	smallClient := sizeKnown && isSmallClient(&config.Session, width, height)
+	layouts, layoutErr := chooseLayouts(&config.Session, width, height, smallClient)
+
+	baseSessionName := sessionName
+	if opts.resumeWindow != "" {
+		sessionName = t.resumedSession(opts.resumeWindow)
+	} else if !useCurrent && opts.forceNew {
+		sessionName = t.getUniqueSessionName(sessionName)
+	}
+	history := startRun(opts, config, sessionName)
+	if opts.resumeWindow != "" {
+		history.onFailure = func(err error) {
+			t.notifyClients(sessionName, fmt.Sprintf("gridlock: provisioning %s failed: %v", sessionName, err))
+		}
+	}
+
 	sessionExists := false
 	survivorWindowID := ""
-	if !useCurrent {
-		_, err = t.run("has-session", "-t", sessionName)
-		if err == nil && !*dryRun {
-			if *recreate {
+	// Pane focused last in a session being recreated, see restoreFocus
+	lastFocused := ""
+	// Changes gridlock apply makes to a running session
+	var sync *syncPlan
+	// A resumed session was created by the --fast-attach run and is provisioned as a new one
+	if !useCurrent && opts.resumeWindow == "" {
+		exists := t.sessionExists(sessionName)
+		if exists && !opts.dryRun && opts.renameExisting != "" {
+			newName, err := t.renameExisting(sessionName, opts.renameExisting)
+			if err != nil {
+				history.fatalf("%v", err)
+			}
+			fmt.Printf("Renamed existing session: %s -> %s\n", sessionName, newName)
+			if currentSession == sessionName {
+				currentSession = newName
+			}
+		} else if exists && opts.sync {
+			if layoutErr != nil {
+				history.fatalf("Not applying configuration: %v", layoutErr)
+			}
+			query := newTMUX(opts, config)
+			query.DryRun = false
+			sync, err = t.planSync(query, sessionName, config, layouts, opts.prune, opts.rebuild, opts.restartWindow)
+			if err != nil {
+				history.fatalf("%v", err)
+			}
+			t.syncOptions(query, sessionName, config, sync)
+			history.run.Action = "apply"
+			// The missing windows are added to the session like --current adds them
+			useCurrent = true
+		} else if exists && opts.appendWindows {
+			if layoutErr != nil {
+				history.fatalf("Not appending windows: %v", layoutErr)
+			}
+			query := newTMUX(opts, config)
+			query.DryRun = false
+			sync, err = planAppend(query, sessionName, config)
+			if err != nil {
+				history.fatalf("%v", err)
+			}
+			history.run.Action = "append"
+			useCurrent = true
+		} else if exists && !opts.dryRun {
+			recreate := opts.recreate
+			if opts.recreateIfChanged && !recreate {
+				if t.sessionMetadata(sessionName, metadataConfigHash) != configHash(config) {
+					fmt.Printf("Configuration changed since session was created: %s\n", sessionName)
+					recreate = true
+				} else {
+					fmt.Printf("Session is up to date: %s\n", sessionName)
+				}
+			}
+			if recreate {
+				if layoutErr != nil {
+					history.fatalf("Not recreating session: %v", layoutErr)
+				}
+				history.run.Action = "recreate"
+				if config.Session.RestoreFocus {
+					lastFocused = t.lastFocused(sessionName)
+				}
+				t.runAllHooks(hookOnKill, &config.Session, sessionName)
 				if inTMUX && currentSession == sessionName {
 					fmt.Printf("Inside target session, cleaning instead of killing: %s\n", sessionName)
-					survivorWindowID = cleanSession(t)
+					survivorWindowID = cleanSession(t, sessionName)
+				} else if opts.inPlace {
+					fmt.Printf("Cleaning existing session in place: %s\n", sessionName)
+					survivorWindowID = cleanSession(t, sessionName)
+				} else if opts.detached && t.hasAttachedClients(sessionName) {
+					// Detached, gridlock leaves the clients alone: killing the session would
+					// disconnect those attached to it without attaching them again
+					fmt.Printf("Cleaning existing session in place, it has attached clients: %s\n", sessionName)
+					survivorWindowID = cleanSession(t, sessionName)
 				} else {
 					fmt.Printf("Killing existing session: %s\n", sessionName)
 					t.run("kill-session", "-t", sessionName)
+					if config.Session.Scratchpad != nil {
+						t.run("kill-session", "-t", "="+scratchpadName(sessionName))
+					}
 				}
 			} else {
 				sessionExists = true
@@ -250,94 +643,379 @@ func main() {
 	}
 
 	if !sessionExists || useCurrent {
-		if !useCurrent && survivorWindowID == "" {
-			// 1. We always create the session in the background.
-			fmt.Printf("Creating session: %s\n", sessionName)
-			newSessionArgs := []string{"new-session", "-d", "-s", sessionName}
-			if config.Session.WorkingDirectory != "" {
-				newSessionArgs = append(newSessionArgs, "-c", expandPath(config.Session.WorkingDirectory))
-			}
-			if len(config.Session.Windows) > 0 {
-				newSessionArgs = append(newSessionArgs, "-n", config.Session.Windows[0].Name)
+		if layoutErr != nil {
+			history.fatalf("%v", layoutErr)
+		}
+		if sync != nil && opts.appendWindows {
+			fmt.Printf("Appending windows to session: %s\n", sessionName)
+		} else if sync != nil {
+			fmt.Printf("Applying configuration to session: %s\n", sessionName)
+		} else if useCurrent {
+			history.run.Action = "add-windows"
+		} else if history.run.Action != "recreate" {
+			history.run.Action = "create"
+		}
+		if !useCurrent && opts.resumeWindow == "" {
+			if err := t.runSessionHook(hookBefore, &config.Session, sessionName); err != nil {
+				history.fatalf("%v", err)
 			}
-			if _, err := t.run(newSessionArgs...); err != nil {
-				log.Fatalf("Failed to create session: %v", err)
+		}
+		p := &provisioning{
+			sessionName:      sessionName,
+			baseSessionName:  baseSessionName,
+			width:            width,
+			height:           height,
+			sizeKnown:        sizeKnown,
+			useCurrent:       useCurrent,
+			sync:             sync,
+			survivorWindowID: survivorWindowID,
+			lastFocused:      lastFocused,
+			firstWindowID:    opts.resumeWindow,
+			fastAttach:       opts.fastAttach && !opts.detached && !opts.dryRun && t.Executor == nil,
+		}
+		if err := t.provision(config, layouts, p); err != nil {
+			history.fatalf("%v", err)
+		}
+		if p.inBackground {
+			// The background run records the provisioning
+			history.skip = true
+			t.attachSession(sessionName, currentSession, inTMUX, config, opts)
+			return
+		}
+	}
+
+	if opts.wait {
+		if err := t.waitForPanes(sessionName, config, opts.waitTimeout); err != nil {
+			history.fatalf("%v", err)
+		}
+	}
+	if !sessionExists && !useCurrent {
+		if err := t.runSessionHook(hookAfter, &config.Session, sessionName); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
+	history.finish(nil)
+	if opts.resumeWindow != "" {
+		t.notifyClients(sessionName, "gridlock: "+sessionName+" is ready")
+	}
+
+	t.attachSession(sessionName, currentSession, inTMUX, config, opts)
+}
+
+// provisioning is how up provisions a session: which session, at which size, and whether it
+// is created or windows are added to a running one
+type provisioning struct {
+	sessionName string
+	// Name of the configured session, which --force-new numbered sessionName after
+	baseSessionName string
+	width, height   int
+	sizeKnown       bool
+	// Adds the windows to the running session instead of creating it
+	useCurrent bool
+	// Changes gridlock apply makes to a running session
+	sync *syncPlan
+	// Window of a session being recreated in place, killed once the new windows exist
+	survivorWindowID string
+	// Pane focused last in a session being recreated, see restoreFocus
+	lastFocused string
+	// Window created by the --fast-attach run, which the session is provisioned around
+	firstWindowID string
+	// Hands the session to a background run once its first window exists, see --fast-attach
+	fastAttach bool
+	// Set when a background run provisions the rest of the session
+	inBackground bool
+}
+
+// provision creates the session of the configuration, or adds its windows to a running one,
+// with the chosen layout of every window. The session's own hooks are left to the caller.
+func (t *TMUX) provision(config *Config, layouts []LayoutNode, p *provisioning) error {
+	opts := t.opts
+	sessionName, useCurrent, sync, survivorWindowID := p.sessionName, p.useCurrent, p.sync, p.survivorWindowID
+	firstWindowID := p.firstWindowID
+	if !useCurrent && survivorWindowID == "" && opts.resumeWindow == "" {
+		// The first window is created with the session
+		if len(config.Session.Windows) > 0 {
+			if err := t.runWindowHook(hookBefore, &config.Session, &config.Session.Windows[0], sessionName); err != nil {
+				return fmt.Errorf("window %s: %v", config.Session.Windows[0].Name, err)
 			}
 		}
+		// 1. We always create the session in the background.
+		fmt.Printf("Creating session: %s\n", sessionName)
+		newSessionArgs := []string{"new-session", "-d", "-s", sessionName}
+		if config.Session.WorkingDirectory != "" {
+			newSessionArgs = append(newSessionArgs, "-c", expandPath(config.Session.WorkingDirectory))
+		}
+		if len(config.Session.Windows) > 0 {
+			newSessionArgs = append(newSessionArgs, "-n", config.Session.Windows[0].Name)
+		}
+		if p.sizeKnown {
+			// Split at the size the session will be attached with
+			newSessionArgs = append(newSessionArgs, "-x", strconv.Itoa(p.width), "-y", strconv.Itoa(p.height))
+		}
+		// Windows are targeted by ID, as users with renumber-windows on see indices change
+		newSessionArgs = append(newSessionArgs, "-P", "-F", "#{window_id}")
+		if config.Session.HistoryLimit > 0 {
+			newSessionArgs = t.withHistoryLimit(config.Session.HistoryLimit, newSessionArgs)
+		}
+		out, err := t.run(newSessionArgs...)
+		if err != nil {
+			return fmt.Errorf("failed to create session: %v", err)
+		}
+		firstWindowID = strings.TrimSpace(out)
 
-		if !useCurrent && survivorWindowID != "" {
-			// Inside target session and recreating: session already exists but is empty (except for survivor window)
-			fmt.Printf("Recreating windows in current session: %s\n", sessionName)
-		} else if useCurrent {
-			fmt.Printf("Adding windows to current session: %s\n", sessionName)
-		}
-
-		var firstWindowName string
-		for i := range config.Session.Windows {
-			window := &config.Session.Windows[i]
-			uniqueName := window.Name
-			if i > 0 || useCurrent || survivorWindowID != "" {
-				uniqueName = t.getUniqueWindowName(sessionName, window.Name)
-				fmt.Printf("Creating window: %s\n", uniqueName)
-				windowArgs := []string{"new-window", "-d", "-t", sessionName + ":", "-n", uniqueName}
-				if window.WorkingDirectory != "" {
-					windowArgs = append(windowArgs, "-c", expandPath(window.WorkingDirectory))
-				} else if config.Session.WorkingDirectory != "" {
-					windowArgs = append(windowArgs, "-c", expandPath(config.Session.WorkingDirectory))
-				}
-				if _, err := t.run(windowArgs...); err != nil {
-					log.Printf("Warning: failed to create window %s: %v", uniqueName, err)
-					continue
-				}
+		if p.fastAttach {
+			if err := t.provisionInBackground(firstWindowID, opts); err != nil {
+				log.Printf("Warning: provisioning in the foreground: %v", err)
+			} else {
+				p.inBackground = true
+				return nil
 			}
-			if i == 0 {
-				firstWindowName = uniqueName
+		}
+	}
+	if !useCurrent {
+		t.recordSessionMetadata(sessionName, opts.configFile, config)
+		if sessionName != p.baseSessionName {
+			t.setSessionMetadata(sessionName, metadataBaseSession, p.baseSessionName)
+		}
+		t.applySessionTuning(sessionName, &config.Session)
+		t.applySessionOptions(sessionName, &config.Session)
+		t.setSessionEnvironment(sessionName, &config.Session)
+		if config.Session.TMUXConfig != "" {
+			if err := t.sourceSessionConfig(sessionName, opts.configFile, config.Session.TMUXConfig); err != nil {
+				log.Printf("Warning: %v", err)
 			}
+		}
+	}
+	// The control client attaches to the session, which exists from here on
+	if opts.controlMode {
+		t.StartControl(sessionName)
+	}
+	// Stripped only after the metadata, so the hash still matches the configuration
+	if opts.noCommands {
+		stripCommands(config)
+	}
 
-			windowTarget := fmt.Sprintf("%s:%s", sessionName, uniqueName)
-			// Apply layout recursively
-			t.applyLayout(windowTarget, 0, window.Layout, window, config.Session.WorkingDirectory)
+	if !useCurrent && survivorWindowID != "" {
+		// Inside target session and recreating: session already exists but is empty (except for survivor window)
+		fmt.Printf("Recreating windows in current session: %s\n", sessionName)
+	} else if useCurrent && sync == nil {
+		fmt.Printf("Adding windows to current session: %s\n", sessionName)
+	}
+
+	statsFile := ""
+	if config.Session.Stats {
+		path, err := prepareUsageStats(config.Session.Name)
+		if err != nil {
+			log.Printf("Warning: usage stats disabled: %v", err)
+		} else {
+			statsFile = path
 		}
+		if statsFile != "" && !useCurrent {
+			t.trackSessionUsage(sessionName, statsFile)
+		}
+	}
 
-		// Switch to the first window if not detached
-		if !*detached && firstWindowName != "" {
-			fmt.Printf("Switching to window: %s\n", firstWindowName)
-			t.run("select-window", "-t", fmt.Sprintf("%s:%s", sessionName, firstWindowName))
+	t.paneBase = t.paneBaseIndex(&config.Session)
+	var firstWindowName, firstWindowTarget string
+	// Window with focus: true, selected even in a detached session
+	var focusWindowName, focusWindowTarget string
+	for i := range config.Session.Windows {
+		window := &config.Session.Windows[i]
+		if sync != nil && !sync.create[i] {
+			continue
+		}
+		uniqueName := window.Name
+		windowID := firstWindowID
+		// The first window is created with the session's directory
+		startDir := expandPath(config.Session.WorkingDirectory)
+		if i > 0 || useCurrent || survivorWindowID != "" {
+			startDir = expandPath(firstNonEmpty(window.WorkingDirectory, config.Session.WorkingDirectory))
+			uniqueName = t.getUniqueWindowName(sessionName, window.Name)
+			if err := t.runWindowHook(hookBefore, &config.Session, window, sessionName); err != nil {
+				return fmt.Errorf("window %s: %v", window.Name, err)
+			}
+			fmt.Printf("Creating window: %s\n", uniqueName)
+			windowArgs := []string{"new-window", "-d", "-P", "-F", "#{window_id}", "-t", sessionName + ":", "-n", uniqueName}
+			if sync != nil && sync.after[i] != "" {
+				windowArgs = []string{"new-window", "-d", "-a", "-P", "-F", "#{window_id}", "-t", sync.after[i], "-n", uniqueName}
+			}
+			if window.WorkingDirectory != "" {
+				windowArgs = append(windowArgs, "-c", expandPath(window.WorkingDirectory))
+			} else if config.Session.WorkingDirectory != "" {
+				windowArgs = append(windowArgs, "-c", expandPath(config.Session.WorkingDirectory))
+			}
+			out, err := t.run(windowArgs...)
+			if err != nil {
+				log.Printf("Warning: failed to create window %s: %v", uniqueName, err)
+				continue
+			}
+			windowID = strings.TrimSpace(out)
 		}
 
-		if survivorWindowID != "" {
-			t.run("kill-window", "-t", survivorWindowID)
+		windowTarget := fmt.Sprintf("%s:%s", sessionName, uniqueName)
+		if windowID != "" {
+			windowTarget = windowID
+		}
+		// Lets later runs recognize the window when it was renamed or renumbered
+		t.run("set-option", "-w", "-t", windowTarget, metadataWindowName, window.Name)
+		t.applyWindowTuning(windowTarget, &config.Session)
+		if i == 0 {
+			firstWindowName = uniqueName
+			firstWindowTarget = windowTarget
+		}
+		if window.Focus {
+			focusWindowName = uniqueName
+			focusWindowTarget = windowTarget
+		}
+		// Apply layout recursively
+		t.applyLayout(windowTarget, 0, layouts[i], window, config.Session.WorkingDirectory, startDir)
+		if layouts[i].Preset != "" {
+			t.applyLayoutPreset(windowTarget, window, layouts[i])
+		}
+		if hasPaneTitles(window) {
+			t.showPaneTitles(windowTarget)
+		}
+		t.applyWindowOptions(windowTarget, window)
+		if statsFile != "" {
+			t.trackWindowUsage(windowTarget, statsFile)
+		}
+		if focused := focusedPane(layouts[i], window); focused >= 0 {
+			t.run("select-pane", "-t", t.paneTarget(windowTarget, focused))
+		} else if config.Session.SelectLastPane {
+			t.run("select-pane", "-t", t.paneTarget(windowTarget, countLayoutPanes(layouts[i])-1))
+		}
+		if config.Session.RestoreFocus {
+			t.trackWindowFocus(windowTarget)
+		}
+		if err := t.runWindowHook(hookAfter, &config.Session, window, sessionName); err != nil {
+			log.Printf("Warning: window %s: %v", window.Name, err)
 		}
 	}
 
+	t.setupClipboard(config.Session.Clipboard)
+	if config.Session.Title != "" && !useCurrent {
+		t.setupTerminalTitle(sessionName, config.Session.Title)
+	}
+	if err := t.bindMenus(sessionName, config.Session.Menus); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	t.bindKeys(sessionName, config.Session.Keys)
+	if config.Session.Scratchpad != nil && !useCurrent {
+		t.setupScratchpad(sessionName, config.Session.WorkingDirectory, config.Session.Scratchpad)
+	}
+	if len(collapseAxes(config)) > 0 && !useCurrent && t.Executor == nil {
+		t.startBackground(sessionName, opts.configFile, "monitor")
+	}
+	if hasSchedules(config) && !useCurrent && t.Executor == nil {
+		t.startBackground(sessionName, opts.configFile, "schedule")
+	}
+
+	// Switch to the window with focus, or else the first window if not detached
+	if focusWindowName != "" && sync == nil {
+		fmt.Printf("Switching to window: %s\n", focusWindowName)
+		t.run("select-window", "-t", focusWindowTarget)
+	} else if !opts.detached && firstWindowName != "" && sync == nil {
+		fmt.Printf("Switching to window: %s\n", firstWindowName)
+		t.run("select-window", "-t", firstWindowTarget)
+	}
+	// Tracked only now, as selecting the first window is not the user's focus
+	if config.Session.RestoreFocus && !useCurrent {
+		t.trackSessionFocus(sessionName)
+		if p.lastFocused != "" {
+			t.setSessionMetadata(sessionName, metadataLastFocused, p.lastFocused)
+		}
+	}
+
+	// The rest looks at the client gridlock runs in, which the control client is not
+	t.StopControl()
+	if survivorWindowID != "" {
+		t.run("kill-window", "-t", survivorWindowID)
+	}
+	if sync != nil {
+		t.finishSync(sync)
+	}
+	return nil
+}
+
+// create creates the session of the configuration in the background, with the chosen layout
+// of every window, as up creates a new session
+func (t *TMUX) create(config *Config, layouts []LayoutNode) error {
+	width, height, sizeKnown := t.clientSize(false)
+	return t.provision(config, layouts, &provisioning{
+		sessionName:     config.Session.Name,
+		baseSessionName: config.Session.Name,
+		width:           width,
+		height:          height,
+		sizeKnown:       sizeKnown,
+	})
+}
+
+// attach attaches the terminal to a running session, or switches the client gridlock runs
+// in to it inside tmux
+func (t *TMUX) attach(name string) error {
+	if os.Getenv("TMUX") != "" {
+		return t.switchClient(name, name)
+	}
+	if t.DryRun {
+		_, err := t.run("attach-session", "-t", name)
+		return err
+	}
+	return t.attachClient(name, "attach-session", "-t", name)
+}
+
+// attachSession switches or attaches to the session, unless it was created detached
+func (t *TMUX) attachSession(sessionName string, currentSession string, inTMUX bool, config *Config, opts upOptions) {
+	if opts.attachCommandTo != nil && !opts.detached {
+		// Matching the name exactly, as the command may run when other sessions exist
+		attachArgs := []string{"attach-session", "-t", "=" + sessionName}
+		if opts.detachOthers || config.Session.DetachOthers {
+			attachArgs = []string{"attach-session", "-d", "-t", "=" + sessionName}
+		}
+		fmt.Fprintln(opts.attachCommandTo, strings.Join(quoteArgs(append([]string{"tmux"}, t.Args(attachArgs...)...)), " "))
+		return
+	}
 	// 4. If we are currently in a TMUX session, we detach from the current one and attach to the new one, unless created detached.
-	if !*detached {
+	if !opts.detached {
+		t.runAllHooks(hookOnAttach, &config.Session, sessionName)
+		if config.Session.RestoreFocus {
+			t.restoreFocus(sessionName)
+		}
+		// Other clients (e.g. a forgotten one on another machine) clamp the window size to the smallest client
+		detachOtherClients := opts.detachOthers || config.Session.DetachOthers
 		if inTMUX {
 			if currentSession != sessionName {
 				fmt.Printf("Switching to session: %s\n", sessionName)
-				t.run("switch-client", "-t", sessionName)
+				if err := t.switchClient(sessionName, sessionName); err != nil {
+					log.Fatalf("%v", err)
+				}
+			}
+			if detachOtherClients {
+				t.detachOtherClients(sessionName)
 			}
 		} else {
 			fmt.Printf("Attaching to session: %s\n", sessionName)
+			attachArgs := []string{"attach-session", "-t", sessionName}
+			if detachOtherClients {
+				attachArgs = []string{"attach-session", "-d", "-t", sessionName}
+			}
 			// attach-session usually takes over the terminal, so we use exec.Command to replace the process if not dryRun
-			if !*dryRun {
-				cmd := exec.Command("tmux", "attach-session", "-t", sessionName)
-				cmd.Stdin = os.Stdin
-				cmd.Stdout = os.Stdout
-				cmd.Stderr = os.Stderr
-				if err := cmd.Run(); err != nil {
-					log.Fatalf("failed to attach to session: %v", err)
+			if !opts.dryRun {
+				if err := t.attachClient(sessionName, attachArgs...); err != nil {
+					log.Fatalf("%v", err)
 				}
 			} else {
-				t.run("attach-session", "-t", sessionName)
+				t.run(attachArgs...)
 			}
 		}
 	}
 }
 
-func cleanSession(t *TMUX) string {
+// cleanSession kills all windows of the session but its current one, which is renamed out
+// of the way and killed once the new windows exist, so the session and the clients attached
+// to it survive being recreated
+func cleanSession(t *TMUX, sessionName string) string {
 	// Returns the ID of the window that survived
-	out, err := t.run("display-message", "-p", "#{window_id}")
+	out, err := t.run("display-message", "-p", "-t", sessionName, "#{window_id}")
 	if err != nil {
 		return ""
 	}
@@ -352,59 +1030,146 @@ func cleanSession(t *TMUX) string {
 	return currentWindowID
 }
 
+// hasAttachedClients reports whether any client is attached to the session
+func (t *TMUX) hasAttachedClients(sessionName string) bool {
+	out, err := t.run("display-message", "-p", "-t", "="+sessionName+":", "#{session_attached}")
+	if err != nil {
+		return false
+	}
+	n, _ := strconv.Atoi(strings.TrimSpace(out))
+	return n > 0
+}
 
-func (t *TMUX) applyLayout(windowTarget string, paneTarget int, node LayoutNode, window *WindowConfig, sessionWorkDir string) int {
-	if node.PaneName != "" {
-		paneConfig := findPane(window, node.PaneName)
-		if paneConfig != nil {
-			if paneConfig.Command != "" {
-				t.run("send-keys", "-t", fmt.Sprintf("%s.%d", windowTarget, paneTarget), paneConfig.Command, "C-m")
-			}
-			if len(paneConfig.Commands) > 0 {
-				for _, cmd := range paneConfig.Commands {
-					t.run("send-keys", "-t", fmt.Sprintf("%s.%d", windowTarget, paneTarget), cmd, "C-m")
-				}
-			}
+// detachOtherClients detaches every client attached to the session except the one we are running in
+func (t *TMUX) detachOtherClients(sessionName string) {
+	out, err := t.run("display-message", "-p", "#{client_name}")
+	if err != nil {
+		return
+	}
+	self := strings.TrimSpace(out)
+
+	out, err = t.run("list-clients", "-t", sessionName, "-F", "#{client_name}")
+	if err != nil {
+		return
+	}
+	for _, client := range strings.Split(strings.TrimSpace(out), "\n") {
+		client = strings.TrimSpace(client)
+		if client == "" || client == self {
+			continue
 		}
-		return paneTarget + 1
+		fmt.Printf("Detaching client: %s\n", client)
+		t.run("detach-client", "-t", client)
 	}
+}
 
-	if len(node.Columns) > 0 {
-		n := len(node.Columns)
-		for i := 0; i < n-1; i++ {
-			percentage := 100 * (n - 1 - i) / (n - i)
-			splitArgs := []string{"split-window", "-h", "-p", fmt.Sprintf("%d", percentage), "-t", fmt.Sprintf("%s.%d", windowTarget, paneTarget+i)}
-			workDir := getWorkDirForNode(&node.Columns[i+1], window, sessionWorkDir)
-			if workDir != "" {
-				splitArgs = append(splitArgs, "-c", workDir)
-			}
-			t.run(splitArgs...)
-		}
-
-		currentPane := paneTarget
-		for _, col := range node.Columns {
-			currentPane = t.applyLayout(windowTarget, currentPane, col, window, sessionWorkDir)
-		}
-		return currentPane
-	} else if len(node.Rows) > 0 {
-		n := len(node.Rows)
-		for i := 0; i < n-1; i++ {
-			percentage := 100 * (n - 1 - i) / (n - i)
-			splitArgs := []string{"split-window", "-v", "-p", fmt.Sprintf("%d", percentage), "-t", fmt.Sprintf("%s.%d", windowTarget, paneTarget+i)}
-			workDir := getWorkDirForNode(&node.Rows[i+1], window, sessionWorkDir)
-			if workDir != "" {
-				splitArgs = append(splitArgs, "-c", workDir)
-			}
-			t.run(splitArgs...)
+// applyLayout splits the pane at paneTarget according to node and sets up the resulting panes.
+// startDir is the directory the pane at paneTarget was started in.
+func (t *TMUX) applyLayout(windowTarget string, paneTarget int, node LayoutNode, window *WindowConfig, sessionWorkDir string, startDir string) int {
+	layout := &builder.Layout{
+		Run:      t.run,
+		PaneBase: t.paneBase,
+		Dir: func(node LayoutNode) string {
+			return getWorkDirForNode(&node, window, sessionWorkDir)
+		},
+		Pane: func(target string, node LayoutNode, startDir string) {
+			t.setupLayoutPane(target, node, window, sessionWorkDir, startDir)
+		},
+		Failed: t.reportPaneFailure,
+	}
+	return layout.Build(windowTarget, paneTarget, node, startDir)
+}
+
+// setupLayoutPane sets up the pane at target that applyLayout created for a leaf node of the
+// layout, which was started in startDir
+func (t *TMUX) setupLayoutPane(target string, node LayoutNode, window *WindowConfig, sessionWorkDir string, startDir string) {
+	paneConfig := findPane(window, node.PaneName)
+	if paneConfig == nil {
+		return
+	}
+	t.run("set-option", "-p", "-t", target, metadataPaneName, paneConfig.Name)
+	workDir := getWorkDirForNode(&node, window, sessionWorkDir)
+	if paneConfig.Shell != "" || len(paneConfig.Env) > 0 {
+		if err := t.respawnPane(target, paneConfig, workDir); err != nil {
+			t.reportPaneFailure(target, "respawn-pane", err)
+		}
+	} else if workDir != "" && workDir != startDir {
+		// The pane was split off for a sibling's directory, restart its shell in its own
+		if _, err := t.run("respawn-pane", "-k", "-t", target, "-c", workDir); err != nil {
+			t.reportPaneFailure(target, "respawn-pane", err)
+		}
+	}
+	if paneConfig.Style != "" {
+		t.run("select-pane", "-t", target, "-P", paneConfig.Style)
+	}
+	if paneConfig.Title != "" {
+		t.setPaneTitle(target, paneConfig.Title)
+	}
+	if len(paneConfig.OSC) > 0 {
+		t.emitOSC(target, paneConfig.OSC)
+	}
+	if paneConfig.OnExit != "" {
+		t.applyOnExit(target, paneConfig)
+	} else if paneConfig.KeepOpen != nil && *paneConfig.KeepOpen {
+		t.keepPaneOpen(target)
+	}
+	if window.Ephemeral {
+		t.closeOnExit(target)
+	}
+	if paneConfig.Log != nil && *paneConfig.Log {
+		if err := t.startPaneLog(target, window, paneConfig); err != nil {
+			log.Printf("Warning: pane %s: failed to start logging: %v", paneConfig.Name, err)
 		}
+	}
+	if err := t.sendCommands(target, paneConfig, window.Ephemeral); err != nil {
+		t.reportPaneFailure(target, "send-keys", err)
+	}
+	if paneConfig.AdoptPID > 0 {
+		if err := t.adoptProcess(target, paneConfig.AdoptPID); err != nil {
+			log.Printf("Warning: pane %s: %v", paneConfig.Name, err)
+		}
+	}
+	if paneConfig.CopyMode != nil {
+		t.restoreCopyMode(target, paneConfig.CopyMode)
+	}
+	if paneConfig.Kind == paneKindClock {
+		t.run("clock-mode", "-t", target)
+	}
+}
 
-		currentPane := paneTarget
-		for _, row := range node.Rows {
-			currentPane = t.applyLayout(windowTarget, currentPane, row, window, sessionWorkDir)
+// sendCommands types the command and commands of a pane into it. With exit set, the shell
+// exits after the last command if it succeeds.
+func (t *TMUX) sendCommands(target string, pane *PaneConfig, exit bool) error {
+	commands := append(Commands{}, pane.Commands...)
+	if pane.Command != "" {
+		commands = append(Commands{{Run: pane.Command}}, commands...)
+	}
+	for i, cmd := range commands {
+		if err := t.sendCommand(target, pane, cmd, exit && i == len(commands)-1); err != nil {
+			return err
+		}
+	}
+	for _, send := range pane.Send {
+		if err := t.sendKeys(target, send); err != nil {
+			return err
 		}
-		return currentPane
 	}
-	return paneTarget + 1
+	return nil
+}
+
+// keepPaneOpen makes a pane fall back to an interactive shell when its process exits,
+// instead of closing and collapsing the layout
+func (t *TMUX) keepPaneOpen(paneTarget string) {
+	t.run("set-option", "-p", "-t", paneTarget, "remain-on-exit", "on")
+	t.run("set-hook", "-p", "-t", paneTarget, "pane-died", "respawn-pane")
+}
+
+// restoreCopyMode enters copy-mode and scrolls back towards the recorded position.
+// A fresh pane has little history, so this only gets near the original position.
+func (t *TMUX) restoreCopyMode(paneTarget string, copyMode *CopyModeConfig) {
+	t.run("copy-mode", "-t", paneTarget)
+	if copyMode.ScrollPosition > 0 {
+		t.run("send-keys", "-t", paneTarget, "-X", "-N", strconv.Itoa(copyMode.ScrollPosition), "scroll-up")
+	}
 }
 
 func getWorkDirForNode(node *LayoutNode, window *WindowConfig, sessionWorkDir string) string {
@@ -427,7 +1192,36 @@ func getWorkDirForNode(node *LayoutNode, window *WindowConfig, sessionWorkDir st
 	return expandPath(sessionWorkDir)
 }
 
+// Working directory prefix resolving to the git repository root of the invocation directory
+const gitRootPrefix = "!git-root"
+
+var (
+	gitRootOnce sync.Once
+	gitRootDir  string
+	gitRootErr  error
+)
+
+func gitRoot() (string, error) {
+	gitRootOnce.Do(func() {
+		out, err := exec.Command("git", "rev-parse", "--show-toplevel").Output()
+		if err != nil {
+			gitRootErr = fmt.Errorf("not inside a git repository: %v", err)
+			return
+		}
+		gitRootDir = strings.TrimSpace(string(out))
+	})
+	return gitRootDir, gitRootErr
+}
+
 func expandPath(path string) string {
+	if path == gitRootPrefix || strings.HasPrefix(path, gitRootPrefix+"/") {
+		root, err := gitRoot()
+		if err != nil {
+			log.Printf("Warning: cannot resolve %s: %v", path, err)
+			return path
+		}
+		return filepath.Join(root, strings.TrimPrefix(path, gitRootPrefix))
+	}
 	if strings.HasPrefix(path, "~/") || path == "~" {
 		home, err := os.UserHomeDir()
 		if err != nil {
@@ -488,248 +1282,22 @@ func (t *TMUX) getUniqueWindowName(sessionName string, baseName string) string {
 	return baseName
 }
 
-func captureCurrentSession(sessionName string) (*Config, error) {
-	t := &TMUX{dryRun: false}
-
-	// Verify session exists
-	_, err := t.run("has-session", "-t", sessionName)
-	if err != nil {
-		return nil, fmt.Errorf("session %s not found", sessionName)
-	}
-
-	// Get Windows
-	out, err := t.run("list-windows", "-t", sessionName, "-F", "#{window_id} #{window_name} #{window_layout}")
+// getUniqueSessionName returns baseName, or baseName-2, baseName-3, ... if a session with that name exists
+func (t *TMUX) getUniqueSessionName(baseName string) string {
+	out, err := t.run("list-sessions", "-F", "#{session_name}")
 	if err != nil {
-		return nil, fmt.Errorf("failed to list windows: %v", err)
-	}
-
-	lines := strings.Split(strings.TrimSpace(out), "\n")
-	var windows []WindowConfig
-
-	// Get Session CWD (from first pane of first window usually, or just assume user home for now, 
-	// but let's try to infer from common prefix later? No, let's just leave it empty and set per-window/pane)
-	// Actually, tmux has a session working directory but it's not easily exposed unless we look at the session creation time or just ignore it.
-	// We will rely on window/pane working directories.
-
-	for _, line := range lines {
-		parts := strings.SplitN(line, " ", 3)
-		if len(parts) < 3 {
-			continue
-		}
-		winID := parts[0]
-		winName := parts[1]
-		layoutStr := parts[2]
-
-		// Get Panes for this window
-		paneOut, err := t.run("list-panes", "-t", winID, "-F", "#{pane_id} #{pane_current_path} #{pane_current_command}")
-		if err != nil {
-			return nil, fmt.Errorf("failed to list panes for window %s: %v", winName, err)
-		}
-
-		paneLines := strings.Split(strings.TrimSpace(paneOut), "\n")
-		var panes []PaneConfig
-		paneIDMap := make(map[int]string)
-
-		for i, pLine := range paneLines {
-			pParts := strings.SplitN(pLine, " ", 3)
-			if len(pParts) < 3 {
-				continue
-			}
-			pIDStr := pParts[0]
-			pPath := pParts[1]
-			pCmd := pParts[2]
-
-			// Generate a name
-			pName := fmt.Sprintf("%s-pane-%d", winName, i)
-			
-			// Try to simplify path
-			home, _ := os.UserHomeDir()
-			if strings.HasPrefix(pPath, home) {
-				pPath = "~" + strings.TrimPrefix(pPath, home)
-			}
-
-			// Clean up command (if it's just a shell, maybe ignore it? No, keep it.)
-			// If it's bash/zsh/sh, it might be the default shell, but explicit is okay.
-
-			panes = append(panes, PaneConfig{
-				Name:             pName,
-				WorkingDirectory: pPath,
-				Command:          pCmd,
-			})
-
-			// Map ID (remove %) to name
-			idVal, _ := strconv.Atoi(strings.TrimPrefix(pIDStr, "%"))
-			paneIDMap[idVal] = pName
-		}
-
-		// Parse Layout
-		layoutNode, err := parseTmuxLayout(layoutStr, paneIDMap)
-		if err != nil {
-			// Fallback: just columns
-			log.Printf("Warning: failed to parse layout for window %s: %v. Using simple column layout.", winName, err)
-			var cols []LayoutNode
-			for _, p := range panes {
-				cols = append(cols, LayoutNode{PaneName: p.Name})
-			}
-			layoutNode = LayoutNode{Columns: cols}
-		}
-
-		windows = append(windows, WindowConfig{
-			Name:    winName,
-			Panes:   panes,
-			Layout:  layoutNode,
-		})
+		// No server running, so no sessions
+		return baseName
 	}
 
-	return &Config{
-		Session: SessionConfig{
-			Name:    sessionName,
-			Windows: windows,
-		},
-	}, nil
-}
-
-func parseTmuxLayout(layout string, paneMap map[int]string) (LayoutNode, error) {
-	// Format: checksum,WxH,X,Y{...} or ...[...] or ...,ID
-	// 1. Remove checksum if present (hex followed by comma) at start
-	if idx := strings.Index(layout, ","); idx != -1 {
-		// Check if prefix is hex checksum (approx check)
-		prefix := layout[:idx]
-		if matched, _ := regexp.MatchString(`^[0-9a-f]{4}$`, prefix); matched {
-			layout = layout[idx+1:]
-		}
-	}
-
-	// Regex to match WxH,X,Y
-	// We just need to find where the geometry ends.
-	// It ends at `{`, `[`, or `,`.
-	// Actually, leaf node format: WxH,X,Y,ID
-	// Container: WxH,X,Y{...} or WxH,X,Y[...]
-	
-	re := regexp.MustCompile(`^\d+x\d+,\d+,\d+`)
-	loc := re.FindStringIndex(layout)
-	if loc == nil {
-		return LayoutNode{}, fmt.Errorf("invalid layout format: %s", layout)
-	}
-	
-	rest := layout[loc[1]:]
-	if len(rest) == 0 {
-		return LayoutNode{}, fmt.Errorf("unexpected end of layout string")
-	}
-
-	firstChar := rest[0]
-	content := rest[1:] // remove first char
-
-	if firstChar == ',' {
-		// Leaf node: ,ID
-		idStr := content
-		id, err := strconv.Atoi(idStr)
-		if err != nil {
-			return LayoutNode{}, fmt.Errorf("invalid pane ID: %s", idStr)
-		}
-		name, ok := paneMap[id]
-		if !ok {
-			// Maybe pane is not in the list? (e.g. dead pane?)
-			// Or we parsed ID wrong.
-			return LayoutNode{PaneName: fmt.Sprintf("unknown-pane-%d", id)}, nil
-		}
-		return LayoutNode{PaneName: name}, nil
-	} else if firstChar == '{' {
-		// Horizontal split (Columns)
-		// Remove trailing }
-		if content[len(content)-1] != '}' {
-			return LayoutNode{}, fmt.Errorf("mismatched braces in layout")
-		}
-		content = content[:len(content)-1]
-		childrenStr := splitLayoutChildren(content)
-		var columns []LayoutNode
-		for _, child := range childrenStr {
-			node, err := parseTmuxLayout(child, paneMap)
-			if err != nil {
-				return LayoutNode{}, err
-			}
-			columns = append(columns, node)
-		}
-		return LayoutNode{Columns: columns}, nil
-
-	} else if firstChar == '[' {
-		// Vertical split (Rows)
-		// Remove trailing ]
-		if content[len(content)-1] != ']' {
-			return LayoutNode{}, fmt.Errorf("mismatched brackets in layout")
-		}
-		content = content[:len(content)-1]
-		childrenStr := splitLayoutChildren(content)
-		var rows []LayoutNode
-		for _, child := range childrenStr {
-			node, err := parseTmuxLayout(child, paneMap)
-			if err != nil {
-				return LayoutNode{}, err
-			}
-			rows = append(rows, node)
-		}
-		return LayoutNode{Rows: rows}, nil
+	existing := make(map[string]bool)
+	for _, name := range strings.Split(strings.TrimSpace(out), "\n") {
+		existing[strings.TrimSpace(name)] = true
 	}
 
-	return LayoutNode{}, fmt.Errorf("unexpected character after geometry: %c", firstChar)
-}
-
-func splitLayoutChildren(s string) []string {
-	var children []string
-	re := regexp.MustCompile(`^\d+x\d+,\d+,\d+`)
-
-	for len(s) > 0 {
-		// Find end of current node
-		// A node starts with WxH,X,Y
-		loc := re.FindStringIndex(s)
-		if loc == nil {
-			// Should not happen if valid layout
-			break
-		}
-
-		cursor := loc[1]
-		if cursor >= len(s) {
-			children = append(children, s)
-			break
-		}
-
-		char := s[cursor]
-		if char == ',' {
-			// Leaf: ,ID
-			cursor++
-			// Consume digits
-			for cursor < len(s) && s[cursor] >= '0' && s[cursor] <= '9' {
-				cursor++
-			}
-		} else if char == '{' || char == '[' {
-			// Container
-			openChar := char
-			closeChar := '}'
-			if openChar == '[' {
-				closeChar = ']'
-			}
-			cursor++
-			depth := 1
-			for cursor < len(s) && depth > 0 {
-				if s[cursor] == openChar {
-					depth++
-				}
-				if s[cursor] == byte(closeChar) {
-					depth--
-				}
-				cursor++
-			}
-		}
-
-		// Now cursor is at end of node
-		children = append(children, s[:cursor])
-
-		// If there is a comma separator, skip it for the next iteration
-		if cursor < len(s) && s[cursor] == ',' {
-			cursor++
-		}
-		s = s[cursor:]
+	name := baseName
+	for i := 2; existing[name]; i++ {
+		name = fmt.Sprintf("%s-%d", baseName, i)
 	}
-	return children
+	return name
 }
-
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/esaiaswestberg/gridlock/pkg/tmux"
+)
+
+// menuItemCommand returns the tmux command an item runs when selected
+func menuItemCommand(sessionName string, item MenuItem) (string, error) {
+	switch {
+	case item.Command != "":
+		return item.Command, nil
+	case item.Run != "":
+		return "send-keys " + tmux.Quote(item.Run) + " Enter", nil
+	case item.Window != "":
+		return "select-window -t " + tmux.Quote(sessionName+":"+item.Window), nil
+	}
+	return "", fmt.Errorf("menu item %s has no command, run or window", item.Name)
+}
+
+// menuCommand returns the display-menu command showing a menu
+func menuCommand(sessionName string, menu MenuConfig) (string, error) {
+	args := []string{"display-menu", "-x", "C", "-y", "C"}
+	if menu.Title != "" {
+		args = append(args, "-T", tmux.Quote(menu.Title))
+	}
+	for _, item := range menu.Items {
+		if item.Name == "" {
+			args = append(args, `""`)
+			continue
+		}
+		command, err := menuItemCommand(sessionName, item)
+		if err != nil {
+			return "", err
+		}
+		args = append(args, tmux.Quote(item.Name), tmux.Quote(item.Key), tmux.Quote(command))
+	}
+	return strings.Join(args, " "), nil
+}
+
+// bindMenus binds the menus of the session. Key bindings are server-wide in tmux, so the
+// menus only open while the session is the current one, and the keys do what they were
+// bound to before in other sessions.
+func (t *TMUX) bindMenus(sessionName string, menus []MenuConfig) error {
+	for _, menu := range menus {
+		command, err := menuCommand(sessionName, menu)
+		if err != nil {
+			return fmt.Errorf("menu %s: %v", menu.Title, err)
+		}
+		condition := sessionCondition(sessionName)
+		args := []string{"bind-key", menu.Key, "if-shell", "-F", condition, command}
+		if previous := t.previousBinding("prefix", menu.Key, condition); previous != "" {
+			args = append(args, previous)
+		}
+		if _, err := t.run(args...); err != nil {
+			return fmt.Errorf("menu %s: %v", menu.Title, err)
+		}
+	}
+	return nil
+}
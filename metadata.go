@@ -0,0 +1,55 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"path/filepath"
+	"strings"
+
+	"github.com/esaiaswestberg/gridlock/pkg/builder"
+	"gopkg.in/yaml.v3"
+)
+
+// Session user options in which gridlock records how a session was created
+const (
+	metadataConfigFile = "@gridlock-config"
+	metadataConfigHash = "@gridlock-config-hash"
+	// Set on sessions created with --force-new to the configured session name
+	metadataBaseSession = "@gridlock-base-session"
+	// Pane user option holding the name of the configured pane
+	metadataPaneName = builder.PaneOption
+	// Window user option holding the name of the configured window
+	metadataWindowName = builder.WindowOption
+)
+
+// configHash returns a hash of the resolved configuration
+func configHash(config *Config) string {
+	data, err := yaml.Marshal(config)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func (t *TMUX) setSessionMetadata(sessionName string, key string, value string) {
+	t.run("set-option", "-t", sessionName, key, value)
+}
+
+// sessionMetadata returns a gridlock user option of the session, or "" when unset
+func (t *TMUX) sessionMetadata(sessionName string, key string) string {
+	out, err := t.run("show-options", "-v", "-t", sessionName, key)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(out)
+}
+
+// recordSessionMetadata tags a session created by gridlock with its configuration
+func (t *TMUX) recordSessionMetadata(sessionName string, configFile string, config *Config) {
+	if abs, err := filepath.Abs(configFile); err == nil {
+		configFile = abs
+	}
+	t.setSessionMetadata(sessionName, metadataConfigFile, configFile)
+	t.setSessionMetadata(sessionName, metadataConfigHash, configHash(config))
+}
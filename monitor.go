@@ -0,0 +1,195 @@
+package main
+
+import (
+	"flag"
+	"hash/fnv"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// Idle time after which collapsible panes are collapsed, see collapse-after
+	defaultCollapseAfter = 5 * time.Minute
+	// Columns or lines a collapsed pane keeps
+	collapsedPaneSize = 2
+	// Interval in which the monitor checks the panes for activity
+	monitorPollInterval = 5 * time.Second
+)
+
+// collapseAfter returns the idle time after which the collapsible panes of the session collapse
+func collapseAfter(session *SessionConfig) (time.Duration, error) {
+	if session.CollapseAfter == "" {
+		return defaultCollapseAfter, nil
+	}
+	return time.ParseDuration(session.CollapseAfter)
+}
+
+// collapseAxes returns, per window and pane name, the resize-pane flag that collapses the
+// collapsible panes: -x for a pane among columns, -y for one among rows
+func collapseAxes(config *Config) map[string]map[string]string {
+	axes := make(map[string]map[string]string)
+	var walk func(window *WindowConfig, node LayoutNode)
+	walk = func(window *WindowConfig, node LayoutNode) {
+		for _, children := range []struct {
+			nodes []LayoutNode
+			flag  string
+		}{{node.Columns, "-x"}, {node.Rows, "-y"}} {
+			for _, child := range children.nodes {
+				if pane := findPane(window, child.PaneName); pane != nil && pane.Collapsible {
+					if axes[window.Name] == nil {
+						axes[window.Name] = make(map[string]string)
+					}
+					axes[window.Name][pane.Name] = children.flag
+				}
+				walk(window, child)
+			}
+		}
+	}
+	for i := range config.Session.Windows {
+		walk(&config.Session.Windows[i], config.Session.Windows[i].Layout)
+	}
+	return axes
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// startBackground runs a gridlock subcommand for the session, monitor or schedule, in the
+// background of the tmux server, so it lives as long as the server rather than the gridlock
+// process that started it
+func (t *TMUX) startBackground(sessionName string, configFile string, subcommand string) {
+	executable, err := os.Executable()
+	if err != nil {
+		log.Printf("Warning: cannot start gridlock %s: %v", subcommand, err)
+		return
+	}
+	if abs, err := filepath.Abs(configFile); err == nil {
+		configFile = abs
+	}
+	// The ID tells a recreated session from the one the monitor was started for
+	sessionID := sessionName
+	if out, err := t.run("display-message", "-p", "-t", sessionName, "#{session_id}"); err == nil && strings.TrimSpace(out) != "" {
+		sessionID = strings.TrimSpace(out)
+	}
+	command := []string{shellQuote(executable), "-f", shellQuote(configFile)}
+	if t.Socket != "" {
+		command = append(command, "-L", shellQuote(t.Socket))
+	}
+	for _, arg := range templateVars.args() {
+		command = append(command, shellQuote(arg))
+	}
+	command = append(command, subcommand, "--session", shellQuote(sessionID))
+	t.run("run-shell", "-b", strings.Join(command, " ")+" >/dev/null 2>&1")
+}
+
+// paneActivity is what the monitor knows about a collapsible pane
+type paneActivity struct {
+	contents   uint64
+	lastActive time.Time
+	collapsed  bool
+	// Size of the pane before it was collapsed
+	size int
+}
+
+func hashContents(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	return h.Sum64()
+}
+
+// monitorCommand collapses the collapsible panes of a session while they are idle. A pane
+// is active while its output changes or while it is the selected pane of the current window
+// of an attached session.
+func monitorCommand(fs *flag.FlagSet) func(args []string, opts upOptions) {
+	sessionID := fs.String("session", "", "ID or name of the session to monitor (started by gridlock up)")
+	return func(args []string, opts upOptions) {
+		config, err := loadConfig(opts.configFile)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		if err := prepareConfig(config); err != nil {
+			log.Fatalf("invalid config: %v", err)
+		}
+		idle, err := collapseAfter(&config.Session)
+		if err != nil {
+			log.Fatalf("invalid collapse-after: %v", err)
+		}
+		target := firstNonEmpty(*sessionID, config.Session.Name)
+		axes := collapseAxes(config)
+		t := newTMUX(opts, config)
+		t.DryRun = false
+
+		panes := make(map[string]*paneActivity)
+		for t.sessionExists(target) {
+			t.checkActivity(target, axes, idle, panes)
+			time.Sleep(monitorPollInterval)
+		}
+	}
+}
+
+// checkActivity collapses the panes that became idle and restores those that became active
+func (t *TMUX) checkActivity(target string, axes map[string]map[string]string, idle time.Duration, panes map[string]*paneActivity) {
+	// The window name may contain spaces, so it is preceded by its length
+	out, err := t.run("list-panes", "-s", "-t", target, "-F", "#{pane_id} #{pane_active}#{window_active}#{session_attached} #{pane_width} #{pane_height} #{n:"+metadataWindowName+"} #{"+metadataWindowName+"}#{"+metadataPaneName+"}")
+	if err != nil {
+		return
+	}
+	now := time.Now()
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		parts := strings.SplitN(line, " ", 6)
+		if len(parts) < 6 {
+			continue
+		}
+		n, err := strconv.Atoi(parts[4])
+		if err != nil || n > len(parts[5]) {
+			continue
+		}
+		axis, ok := axes[parts[5][:n]][parts[5][n:]]
+		if !ok {
+			continue
+		}
+		paneID := parts[0]
+		size, _ := strconv.Atoi(parts[2])
+		if axis == "-y" {
+			size, _ = strconv.Atoi(parts[3])
+		}
+		contents, err := t.run("capture-pane", "-p", "-t", paneID)
+		if err != nil {
+			continue
+		}
+
+		pane, ok := panes[paneID]
+		if !ok {
+			pane = &paneActivity{contents: hashContents(contents), lastActive: now}
+			panes[paneID] = pane
+		}
+		hash := hashContents(contents)
+		// Selected in the current window of a session someone is looking at
+		focused := strings.HasPrefix(parts[1], "11") && parts[1] != "110"
+		if focused || hash != pane.contents {
+			pane.lastActive = now
+		}
+		pane.contents = hash
+
+		switch {
+		case pane.collapsed && now.Sub(pane.lastActive) < idle:
+			t.run("resize-pane", "-t", paneID, axis, strconv.Itoa(pane.size))
+			pane.collapsed = false
+		case !pane.collapsed && now.Sub(pane.lastActive) >= idle && size > collapsedPaneSize:
+			pane.size = size
+			t.run("resize-pane", "-t", paneID, axis, strconv.Itoa(collapsedPaneSize))
+			pane.collapsed = true
+		default:
+			continue
+		}
+		// The contents reflow to the new size, which is not activity
+		if contents, err := t.run("capture-pane", "-p", "-t", paneID); err == nil {
+			pane.contents = hashContents(contents)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// configDir returns the directory of the user's gridlock configuration
+func configDir() (string, error) {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "gridlock"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "gridlock"), nil
+}
+
+// namedProjectsDir returns the directory of the projects started by name
+func namedProjectsDir() (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "projects"), nil
+}
+
+// namedProjectPath returns the configuration file of a named project, which may be encrypted
+func namedProjectPath(name string) (string, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		return "", fmt.Errorf("invalid project name %q", name)
+	}
+	dir, err := namedProjectsDir()
+	if err != nil {
+		return "", err
+	}
+	return resolveConfigPath(filepath.Join(dir, name+".yaml")), nil
+}
+
+// namedProjects returns the names of the projects in the projects directory, sorted
+func namedProjects() []string {
+	dir, err := namedProjectsDir()
+	if err != nil {
+		return nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, entry := range entries {
+		name := entry.Name()
+		for _, ext := range encryptedConfigExtensions {
+			name = strings.TrimSuffix(name, ext)
+		}
+		if entry.IsDir() || !strings.HasSuffix(name, ".yaml") || strings.HasSuffix(name, ".local.yaml") {
+			continue
+		}
+		name = strings.TrimSuffix(name, ".yaml")
+		if len(names) == 0 || names[len(names)-1] != name {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// startCommand brings up the session of a named project from any directory, or lists the
+// named projects when none is given
+func startCommand(fs *flag.FlagSet) func(args []string, opts upOptions) {
+	return func(args []string, opts upOptions) {
+		if len(args) == 0 {
+			for _, name := range namedProjects() {
+				fmt.Println(name)
+			}
+			return
+		}
+		path, err := namedProjectPath(args[0])
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		if _, err := os.Stat(path); err != nil {
+			log.Fatalf("No project named %s, create it with gridlock new %s", args[0], args[0])
+		}
+		opts.configFile = path
+		up(opts)
+	}
+}
+
+// newCommand scaffolds the configuration of a named project in the projects directory,
+// rooted at the current directory unless --dir is given
+func newCommand(fs *flag.FlagSet) func(args []string, opts upOptions) {
+	template := fs.String("template", "", "Configuration file to copy instead of the example configuration")
+	root := fs.String("dir", "", "Working directory of the project's session (default the current directory)")
+	return func(args []string, opts upOptions) {
+		if len(args) != 1 {
+			log.Fatalf("Usage: gridlock new <project>")
+		}
+		path, err := namedProjectPath(args[0])
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		if _, err := os.Stat(path); err == nil {
+			log.Fatalf("%s already exists", path)
+		}
+
+		dir := expandPath(*root)
+		if dir == "" {
+			dir = "."
+		}
+		// The project is started from anywhere, so its directory must not be relative
+		if dir, err = filepath.Abs(dir); err != nil {
+			log.Fatalf("failed to resolve path: %v", err)
+		}
+		config, err := newProjectConfig(dir, *template)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		config.Session.Name = args[0]
+		config.Session.WorkingDirectory = dir
+
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			log.Fatalf("failed to create projects directory: %v", err)
+		}
+		if err := writeConfig(path, config); err != nil {
+			log.Fatalf("%v", err)
+		}
+		fmt.Printf("Created %s, start it with: gridlock start %s\n", path, args[0])
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/esaiaswestberg/gridlock/pkg/tmux"
+)
+
+// What happens to a pane when its process exits, see on-exit
+const (
+	// Start the pane's process again and type its commands into it
+	onExitRespawn = "respawn"
+	// Keep the dead pane with its output on screen until it is respawned or killed
+	onExitKeep = "keep"
+	// Close the pane, whatever remain-on-exit is set to globally
+	onExitDestroy = "destroy"
+)
+
+// validateOnExit checks the on-exit policy of every pane
+func validateOnExit(config *Config) error {
+	for _, window := range config.Session.Windows {
+		for _, pane := range window.Panes {
+			switch pane.OnExit {
+			case "", onExitRespawn, onExitKeep, onExitDestroy:
+			default:
+				return fmt.Errorf("window %s: pane %s: unknown on-exit %q, expected respawn, keep or destroy", window.Name, pane.Name, pane.OnExit)
+			}
+		}
+	}
+	return nil
+}
+
+// applyOnExit sets up what happens to a pane when its process exits. A respawned pane
+// gets its commands typed into it again by the pane-died hook, so a server started with
+// exec, or as the pane's shell, comes back when it crashes.
+func (t *TMUX) applyOnExit(paneTarget string, pane *PaneConfig) {
+	switch pane.OnExit {
+	case onExitRespawn:
+		t.run("set-option", "-p", "-t", paneTarget, "remain-on-exit", "on")
+		t.run("set-hook", "-p", "-t", paneTarget, "pane-died", respawnCommand(pane))
+	case onExitKeep:
+		t.run("set-option", "-p", "-t", paneTarget, "remain-on-exit", "on")
+	case onExitDestroy:
+		t.run("set-option", "-p", "-t", paneTarget, "remain-on-exit", "off")
+	}
+}
+
+// respawnCommand returns the tmux commands restarting a dead pane with its commands
+func respawnCommand(pane *PaneConfig) string {
+	commands := []string{"respawn-pane -k"}
+	if pane.Command != "" {
+		commands = append(commands, "send-keys "+tmux.Quote(pane.Command)+" Enter")
+	}
+	for _, command := range pane.Commands {
+		keys := "send-keys " + tmux.Quote(command.Line())
+		if command.Literal {
+			keys = "send-keys -l " + tmux.Quote(command.Line())
+		}
+		commands = append(commands, keys)
+		if command.Enter == nil || *command.Enter {
+			commands = append(commands, "send-keys Enter")
+		}
+	}
+	return strings.Join(commands, " ; ")
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// openCommand finds or initializes the configuration of a project directory and brings its session up
+func openCommand(fs *flag.FlagSet) func(args []string, opts upOptions) {
+	initTemplate := fs.String("init-template", "", "Configuration file to copy when the project has no configuration yet")
+	provider := fs.String("provider", os.Getenv("GRIDLOCK_PROVIDER"), "Command listing candidate directories (default \"zoxide query --list\")")
+	return func(args []string, opts upOptions) {
+		var dir string
+		if len(args) == 1 && isDir(expandPath(args[0])) {
+			abs, err := filepath.Abs(expandPath(args[0]))
+			if err != nil {
+				log.Fatalf("failed to resolve path: %v", err)
+			}
+			dir = abs
+		} else {
+			// Not a path: fuzzy select among recently visited directories containing a config
+			selected, err := selectProject(args, *provider)
+			if err != nil {
+				log.Fatalf("%v", err)
+			}
+			dir = selected
+		}
+
+		configPath := findProjectConfig(dir)
+		if configPath == "" {
+			configPath = filepath.Join(dir, ".gridlock.yaml")
+			config, err := newProjectConfig(dir, *initTemplate)
+			if err != nil {
+				log.Fatalf("%v", err)
+			}
+			if err := writeConfig(configPath, config); err != nil {
+				log.Fatalf("%v", err)
+			}
+			fmt.Printf("Initialized %s with session name: %s\n", configPath, config.Session.Name)
+		}
+
+		if !opts.dryRun {
+			if err := recordProject(filepath.Dir(configPath)); err != nil {
+				log.Printf("Warning: failed to record project: %v", err)
+			}
+		}
+
+		// Relative working directories in the config are resolved against the project
+		if err := os.Chdir(filepath.Dir(configPath)); err != nil {
+			log.Fatalf("failed to change directory: %v", err)
+		}
+
+		opts.configFile = configPath
+		up(opts)
+	}
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// findProjectConfig looks for .gridlock.yaml (or an encrypted variant) in dir and its parents, stopping at the repository root
+func findProjectConfig(dir string) string {
+	for {
+		candidate := resolveConfigPath(filepath.Join(dir, ".gridlock.yaml"))
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate
+		}
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return ""
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// newProjectConfig builds the initial configuration for a project, optionally from a template
+func newProjectConfig(dir string, template string) (*Config, error) {
+	sessionName := filepath.Base(dir)
+	if template == "" {
+		return defaultConfig(sessionName), nil
+	}
+
+	config, err := loadConfig(expandPath(template))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load template: %v", err)
+	}
+	config.Session.Name = sessionName
+	return config, nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+// setupTerminalTitle makes the outer terminal title follow the session, e.g. "#S" for its name
+func (t *TMUX) setupTerminalTitle(sessionName string, title string) {
+	t.run("set-option", "-t", sessionName, "set-titles", "on")
+	t.run("set-option", "-t", sessionName, "set-titles-string", title)
+}
+
+// setPaneTitle sets the title of a pane and keeps the programs in the pane from replacing
+// it, which shells commonly do from their prompt. allow-set-title only exists since tmux
+// 3.4, so older versions keep the title until a program sets one.
+func (t *TMUX) setPaneTitle(paneTarget string, title string) {
+	t.run("select-pane", "-t", paneTarget, "-T", title)
+	t.run("set-option", "-p", "-t", paneTarget, "allow-set-title", "off")
+}
+
+// hasPaneTitles reports whether any pane of the window has a title
+func hasPaneTitles(window *WindowConfig) bool {
+	for _, pane := range window.Panes {
+		if pane.Title != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// showPaneTitles shows the title of every pane of the window in its top border. Panes
+// without a title show the hostname, tmux's default title. The window's options are set
+// afterwards, so pane-border-status or pane-border-format there take precedence.
+func (t *TMUX) showPaneTitles(windowTarget string) {
+	t.run("set-option", "-w", "-t", windowTarget, "pane-border-status", "top")
+	t.run("set-option", "-w", "-t", windowTarget, "pane-border-format", " #{pane_title} ")
+}
+
+// passthroughOSC wraps an OSC sequence so tmux forwards it to the outer terminal
+func passthroughOSC(osc string) string {
+	sequence := "\x1b]" + osc + "\x07"
+	return "\x1bPtmux;" + strings.ReplaceAll(sequence, "\x1b", "\x1b\x1b") + "\x1b\\"
+}
+
+// emitOSC writes OSC sequences to a pane's terminal, as if the program in the pane had
+// printed them, so they reach the outer terminal without being typed into the shell
+func (t *TMUX) emitOSC(paneTarget string, sequences []string) {
+	// Passthrough is off by default since tmux 3.3
+	t.run("set-option", "-p", "-t", paneTarget, "allow-passthrough", "on")
+	if t.DryRun {
+		for _, osc := range sequences {
+			fmt.Printf("# emit OSC %s to %s\n", osc, paneTarget)
+		}
+		return
+	}
+
+	out, err := t.run("display-message", "-p", "-t", paneTarget, "#{pane_tty}")
+	if err != nil {
+		log.Printf("Warning: cannot emit OSC sequences to %s: %v", paneTarget, err)
+		return
+	}
+	tty, err := os.OpenFile(strings.TrimSpace(out), os.O_WRONLY, 0)
+	if err != nil {
+		log.Printf("Warning: cannot emit OSC sequences to %s: %v", paneTarget, err)
+		return
+	}
+	defer tty.Close()
+	for _, osc := range sequences {
+		tty.WriteString(passthroughOSC(osc))
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// SGR sequences of the styles gridlock prints with
+const (
+	styleBold   = "1"
+	styleDim    = "2"
+	styleRed    = "31"
+	styleGreen  = "32"
+	styleYellow = "33"
+)
+
+// output is where the human readable output of a subcommand goes. Colors are only used on
+// a terminal, and not with NO_COLOR set or TERM=dumb. Tables are fitted to the width of the
+// terminal, and not cut off when the output is piped.
+type output struct {
+	w     io.Writer
+	color bool
+	// Width of the terminal in columns, 0 when the output is not a terminal
+	width int
+}
+
+// newOutput returns the output of a subcommand writing to standard output
+func newOutput() *output {
+	out := &output{w: os.Stdout}
+	if !isTerminal(os.Stdout) {
+		return out
+	}
+	_, noColor := os.LookupEnv("NO_COLOR")
+	out.color = !noColor && os.Getenv("TERM") != "dumb"
+	if width, _, ok := terminalSize(); ok {
+		out.width = width
+	}
+	return out
+}
+
+// isTerminal reports whether f is a terminal rather than a file or pipe
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// style returns text in the style when colors are used, and text as it is otherwise
+func (o *output) style(style string, text string) string {
+	if !o.color || text == "" {
+		return text
+	}
+	return "\x1b[" + style + "m" + text + "\x1b[0m"
+}
+
+func (o *output) printf(format string, args ...any) {
+	fmt.Fprintf(o.w, format, args...)
+}
+
+// table collects rows whose columns are aligned when it is printed
+type table struct {
+	out    *output
+	indent string
+	rows   [][]string
+}
+
+func (o *output) table(indent string) *table {
+	return &table{out: o, indent: indent}
+}
+
+// row adds a row to the table. Cells may be styled, which does not count towards their width.
+func (t *table) row(cells ...string) {
+	t.rows = append(t.rows, cells)
+}
+
+// flush prints the rows with every column as wide as its widest cell and two spaces between
+// columns. On a terminal, the last column is cut off at its width.
+func (t *table) flush() {
+	var widths []int
+	for _, row := range t.rows {
+		for i, cell := range row {
+			if i == len(widths) {
+				widths = append(widths, 0)
+			}
+			widths[i] = max(widths[i], displayWidth(cell))
+		}
+	}
+	for _, row := range t.rows {
+		var b strings.Builder
+		b.WriteString(t.indent)
+		used := displayWidth(t.indent)
+		for i, cell := range row {
+			if i == len(row)-1 {
+				if t.out.width > 0 && used+displayWidth(cell) > t.out.width {
+					cell = truncate(cell, max(t.out.width-used, 1))
+				}
+				b.WriteString(cell)
+				break
+			}
+			b.WriteString(cell)
+			pad := widths[i] - displayWidth(cell) + 2
+			b.WriteString(strings.Repeat(" ", pad))
+			used += widths[i] + 2
+		}
+		fmt.Fprintln(t.out.w, strings.TrimRight(b.String(), " "))
+	}
+	t.rows = nil
+}
+
+// displayWidth returns the number of terminal columns text takes up: escape sequences take
+// none, combining marks none and wide East Asian characters two
+func displayWidth(text string) int {
+	width := 0
+	for i := 0; i < len(text); {
+		if n := escapeLength(text[i:]); n > 0 {
+			i += n
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(text[i:])
+		width += runeWidth(r)
+		i += size
+	}
+	return width
+}
+
+// truncate cuts text off at width columns, ending it with an ellipsis. Escape sequences
+// are kept, so a style that was started is still reset.
+func truncate(text string, width int) string {
+	var b strings.Builder
+	used := 0
+	cut := false
+	for i := 0; i < len(text); {
+		if n := escapeLength(text[i:]); n > 0 {
+			b.WriteString(text[i : i+n])
+			i += n
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(text[i:])
+		i += size
+		if cut {
+			continue
+		}
+		if used+runeWidth(r) > width-1 {
+			b.WriteString("…")
+			cut = true
+			continue
+		}
+		b.WriteRune(r)
+		used += runeWidth(r)
+	}
+	return b.String()
+}
+
+// escapeLength returns the length of the SGR sequence text starts with, or 0
+func escapeLength(text string) int {
+	if !strings.HasPrefix(text, "\x1b[") {
+		return 0
+	}
+	if end := strings.IndexByte(text, 'm'); end > 0 {
+		return end + 1
+	}
+	return 0
+}
+
+func runeWidth(r rune) int {
+	switch {
+	case unicode.Is(unicode.Mn, r) || unicode.Is(unicode.Me, r) || r == '\u200b':
+		return 0
+	case unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hangul, r) || unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) || r >= 0xFF00 && r <= 0xFF60 || r >= 0x1F300 && r <= 0x1FAFF:
+		return 2
+	}
+	return 1
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// localOverlayPath returns the path of the uncommitted local overlay of a configuration
+// file, e.g. .gridlock.local.yaml for .gridlock.yaml
+func localOverlayPath(path string) string {
+	for _, ext := range encryptedConfigExtensions {
+		path = strings.TrimSuffix(path, ext)
+	}
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + ".local" + ext
+}
+
+// loadLocalOverlay applies the local overlay of the configuration file at path, if there is one
+func loadLocalOverlay(config *Config, path string) error {
+	overlayPath := localOverlayPath(path)
+	data, err := os.ReadFile(overlayPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read overlay: %v", err)
+	}
+
+	var overlay Config
+	if err := yaml.Unmarshal(data, &overlay); err != nil {
+		return fmt.Errorf("failed to parse overlay %s: %v", overlayPath, err)
+	}
+	if err := applyOverlay(config, &overlay); err != nil {
+		return fmt.Errorf("overlay %s: %v", overlayPath, err)
+	}
+	return nil
+}
+
+// applyOverlay merges a local overlay into the configuration. Windows and panes are matched
+// by name, set fields of the overlay replace those of the configuration and unmatched windows
+// and panes are added. A window marked locked cannot be changed at all. Panes marked locked
+// cannot have what they run, or how, changed, nor the env, tools or layout of their window
+// in ways that reach them, nor the vars they refer to. The hooks of a locked session cannot
+// be changed either.
+func applyOverlay(config *Config, overlay *Config) error {
+	locked := lockedVars(config)
+	for _, name := range envKeys(overlay.Vars) {
+		if locked[name] {
+			return fmt.Errorf("var %s is used by a locked window or pane and cannot be changed", name)
+		}
+	}
+	config.Vars = mergeEnv(config.Vars, overlay.Vars)
+	session := &config.Session
+	if overlay.Session.Name != "" {
+		session.Name = overlay.Session.Name
+	}
+	if overlay.Session.WorkingDirectory != "" {
+		session.WorkingDirectory = overlay.Session.WorkingDirectory
+	}
+	if overlay.Session.Socket != "" {
+		session.Socket = overlay.Session.Socket
+	}
+	if overlay.Session.Backend != "" {
+		session.Backend = overlay.Session.Backend
+	}
+	if overlay.Session.ToolManager != "" {
+		session.ToolManager = overlay.Session.ToolManager
+	}
+	if overlay.Session.Clipboard != "" {
+		session.Clipboard = overlay.Session.Clipboard
+	}
+	if overlay.Session.Transform != "" {
+		session.Transform = overlay.Session.Transform
+	}
+	if overlay.Session.Title != "" {
+		session.Title = overlay.Session.Title
+	}
+	if overlay.Session.TMUXConfig != "" {
+		session.TMUXConfig = overlay.Session.TMUXConfig
+	}
+	if overlay.Session.Prefix != "" {
+		session.Prefix = overlay.Session.Prefix
+	}
+	if overlay.Session.KeyTable != "" {
+		session.KeyTable = overlay.Session.KeyTable
+	}
+	// Bound after the configured keys, so an overlay binding of the same key wins
+	session.Keys = append(session.Keys, overlay.Session.Keys...)
+	if overlay.Session.HistoryLimit != 0 {
+		session.HistoryLimit = overlay.Session.HistoryLimit
+	}
+	if overlay.Session.AggressiveResize != nil {
+		session.AggressiveResize = overlay.Session.AggressiveResize
+	}
+	if overlay.Session.EscapeTime != nil {
+		session.EscapeTime = overlay.Session.EscapeTime
+	}
+	if overlay.Session.ScriptThreshold != 0 {
+		session.ScriptThreshold = overlay.Session.ScriptThreshold
+	}
+	if overlay.Session.CollapseAfter != "" {
+		session.CollapseAfter = overlay.Session.CollapseAfter
+	}
+	if session.Locked && hasHooks(overlay.Session.Hooks) {
+		return fmt.Errorf("session %s is locked, its hooks cannot be changed", session.Name)
+	}
+	overlayHooks(&session.Hooks, overlay.Session.Hooks)
+	session.Env = mergeEnv(session.Env, overlay.Session.Env)
+	session.EnvScope = mergeEnv(session.EnvScope, overlay.Session.EnvScope)
+	session.Options = mergeEnv(session.Options, overlay.Session.Options)
+	session.PaneDefaults.Env = mergeEnv(session.PaneDefaults.Env, overlay.Session.PaneDefaults.Env)
+
+	for _, overlayWindow := range overlay.Session.Windows {
+		window := findWindow(session.Windows, overlayWindow.Name)
+		if window == nil {
+			session.Windows = append(session.Windows, overlayWindow)
+			continue
+		}
+		if window.Locked && !reflect.DeepEqual(overlayWindow, WindowConfig{Name: overlayWindow.Name}) {
+			return fmt.Errorf("window %s is locked", window.Name)
+		}
+		if err := checkLockedPanes(window, &overlayWindow); err != nil {
+			return fmt.Errorf("window %s: %v", window.Name, err)
+		}
+		if overlayWindow.WorkingDirectory != "" {
+			window.WorkingDirectory = overlayWindow.WorkingDirectory
+		}
+		if overlayWindow.Grid != "" {
+			window.Grid = overlayWindow.Grid
+		}
+		if !overlayWindow.Layout.IsZero() {
+			window.Layout = overlayWindow.Layout
+		}
+		if !overlayWindow.LayoutSmall.IsZero() {
+			window.LayoutSmall = overlayWindow.LayoutSmall
+		}
+		if overlayWindow.Transform != "" {
+			window.Transform = overlayWindow.Transform
+		}
+		overlayHooks(&window.Hooks, overlayWindow.Hooks)
+		window.Env = mergeEnv(window.Env, overlayWindow.Env)
+		window.Tools = mergeEnv(window.Tools, overlayWindow.Tools)
+		window.Options = mergeEnv(window.Options, overlayWindow.Options)
+		window.PaneDefaults.Env = mergeEnv(window.PaneDefaults.Env, overlayWindow.PaneDefaults.Env)
+
+		for _, overlayPane := range overlayWindow.Panes {
+			pane := findPaneConfig(window.Panes, overlayPane.Name)
+			if pane == nil {
+				window.Panes = append(window.Panes, overlayPane)
+				continue
+			}
+			if err := overlayPaneConfig(pane, &overlayPane); err != nil {
+				return fmt.Errorf("window %s: %v", window.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// checkLockedPanes rejects the changes of an overlay window that would reach the locked panes
+// of the window: the tools and env of a window are those of its panes, and a layout decides
+// which panes the window gets
+func checkLockedPanes(window *WindowConfig, overlay *WindowConfig) error {
+	for _, pane := range window.Panes {
+		if !pane.Locked {
+			continue
+		}
+		if len(overlay.Tools) > 0 || len(overlay.Env) > 0 || len(overlay.PaneDefaults.Env) > 0 {
+			return fmt.Errorf("pane %s is locked, the tools and env of its window cannot be changed", pane.Name)
+		}
+		for _, layout := range []LayoutNode{overlay.Layout, overlay.LayoutSmall} {
+			if !layout.IsZero() && layout.Preset == "" && !slices.Contains(layoutPaneNames(layout), pane.Name) {
+				return fmt.Errorf("pane %s is locked, a layout of its window cannot leave it out", pane.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// lockedVars returns the vars the locked session hooks, windows and panes of a configuration
+// refer to, directly or through other vars
+func lockedVars(config *Config) map[string]bool {
+	var locked []any
+	if config.Session.Locked {
+		locked = append(locked, config.Session.Hooks)
+	}
+	for _, window := range config.Session.Windows {
+		if window.Locked {
+			locked = append(locked, window)
+			continue
+		}
+		for _, pane := range window.Panes {
+			if pane.Locked {
+				locked = append(locked, pane)
+			}
+		}
+	}
+	refs := make(map[string]bool)
+	var pending []string
+	for _, value := range locked {
+		data, err := yaml.Marshal(value)
+		if err != nil {
+			continue
+		}
+		for _, match := range templateVar.FindAllStringSubmatch(string(data), -1) {
+			pending = append(pending, match[1])
+		}
+	}
+	for len(pending) > 0 {
+		name := pending[0]
+		pending = pending[1:]
+		if refs[name] {
+			continue
+		}
+		refs[name] = true
+		for _, match := range templateVar.FindAllStringSubmatch(config.Vars[name], -1) {
+			pending = append(pending, match[1])
+		}
+	}
+	return refs
+}
+
+// overlayPaneConfig merges an overlay pane into a configured pane. A locked pane only takes
+// the settings that do not change what runs in it or how.
+func overlayPaneConfig(pane *PaneConfig, overlay *PaneConfig) error {
+	if pane.Locked && (overlay.Command != "" || len(overlay.Commands) > 0 || len(overlay.Send) > 0 || len(overlay.Schedule) > 0 ||
+		overlay.Shell != "" || len(overlay.Env) > 0 || len(overlay.Tools) > 0 || overlay.Kind != "" || overlay.OnExit != "" ||
+		overlay.AsScript != nil || overlay.AdoptPID != 0 || overlay.WorkingDirectoryCmd != "") {
+		return fmt.Errorf("pane %s is locked", pane.Name)
+	}
+	if overlay.Command != "" {
+		pane.Command = overlay.Command
+	}
+	if len(overlay.Commands) > 0 {
+		pane.Commands = overlay.Commands
+	}
+	if len(overlay.Send) > 0 {
+		pane.Send = overlay.Send
+	}
+	if len(overlay.Schedule) > 0 {
+		pane.Schedule = overlay.Schedule
+	}
+	if overlay.Kind != "" {
+		pane.Kind = overlay.Kind
+	}
+	if overlay.Shell != "" {
+		pane.Shell = overlay.Shell
+	}
+	if overlay.OnExit != "" {
+		pane.OnExit = overlay.OnExit
+	}
+	if overlay.WorkingDirectory != "" {
+		pane.WorkingDirectory = overlay.WorkingDirectory
+	}
+	if overlay.WorkingDirectoryCmd != "" {
+		pane.WorkingDirectoryCmd = overlay.WorkingDirectoryCmd
+	}
+	if overlay.Style != "" {
+		pane.Style = overlay.Style
+	}
+	if overlay.Title != "" {
+		pane.Title = overlay.Title
+	}
+	if overlay.KeepOpen != nil {
+		pane.KeepOpen = overlay.KeepOpen
+	}
+	if overlay.AsScript != nil {
+		pane.AsScript = overlay.AsScript
+	}
+	if overlay.Log != nil {
+		pane.Log = overlay.Log
+	}
+	if overlay.AdoptPID != 0 {
+		pane.AdoptPID = overlay.AdoptPID
+	}
+	if overlay.WaitFor != "" {
+		pane.WaitFor = overlay.WaitFor
+	}
+	if overlay.Verify != "" {
+		pane.Verify = overlay.Verify
+	}
+	pane.Env = mergeEnv(pane.Env, overlay.Env)
+	pane.Tools = mergeEnv(pane.Tools, overlay.Tools)
+	return nil
+}
+
+func findWindow(windows []WindowConfig, name string) *WindowConfig {
+	for i := range windows {
+		if windows[i].Name == name {
+			return &windows[i]
+		}
+	}
+	return nil
+}
+
+func findPaneConfig(panes []PaneConfig, name string) *PaneConfig {
+	for i := range panes {
+		if panes[i].Name == name {
+			return &panes[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestApplyOverlayLocks(t *testing.T) {
+	no := false
+	base := func() *Config {
+		return &Config{
+			Vars: map[string]string{"TARGET": "${STAGE}-api", "STAGE": "prod", "DEPLOY": "make deploy", "EDITOR": "vim"},
+			Session: SessionConfig{Name: "dev", Windows: []WindowConfig{
+				{Name: "app", Panes: []PaneConfig{{Name: "server", Command: "make run ${TARGET}", Locked: true}, {Name: "shell", Command: "${EDITOR}"}}},
+				{Name: "ops", Locked: true, Panes: []PaneConfig{{Name: "deploy", Command: "${DEPLOY}"}}},
+			}},
+		}
+	}
+	tests := []struct {
+		name    string
+		overlay WindowConfig
+		vars    map[string]string
+		want    string
+	}{
+		{"command of a locked pane", WindowConfig{Name: "app", Panes: []PaneConfig{{Name: "server", Command: "rm -rf /"}}}, nil, "pane server is locked"},
+		{"schedule of a locked pane", WindowConfig{Name: "app", Panes: []PaneConfig{{Name: "server", Schedule: []ScheduleEntry{{Cron: "* * * * *", Command: "curl evil"}}}}}, nil, "pane server is locked"},
+		{"tools of a locked pane", WindowConfig{Name: "app", Panes: []PaneConfig{{Name: "server", Tools: map[string]string{"node": "20"}}}}, nil, "pane server is locked"},
+		{"tools of a window with a locked pane", WindowConfig{Name: "app", Tools: map[string]string{"node": "20"}}, nil, "pane server is locked"},
+		{"tools of a locked window", WindowConfig{Name: "ops", Tools: map[string]string{"node": "20"}}, nil, "window ops is locked"},
+		{"panes of a locked window", WindowConfig{Name: "ops", Panes: []PaneConfig{{Name: "deploy", Command: "make"}}}, nil, "window ops is locked"},
+		{"env of a locked pane", WindowConfig{Name: "app", Panes: []PaneConfig{{Name: "server", Env: map[string]string{"LD_PRELOAD": "x.so"}}}}, nil, "pane server is locked"},
+		{"adopted process of a locked pane", WindowConfig{Name: "app", Panes: []PaneConfig{{Name: "server", AdoptPID: 42}}}, nil, "pane server is locked"},
+		{"kind of a locked pane", WindowConfig{Name: "app", Panes: []PaneConfig{{Name: "server", Kind: "blank"}}}, nil, "pane server is locked"},
+		{"on-exit of a locked pane", WindowConfig{Name: "app", Panes: []PaneConfig{{Name: "server", OnExit: "restart"}}}, nil, "pane server is locked"},
+		{"as-script of a locked pane", WindowConfig{Name: "app", Panes: []PaneConfig{{Name: "server", AsScript: &no}}}, nil, "pane server is locked"},
+		{"env of a window with a locked pane", WindowConfig{Name: "app", Env: map[string]string{"PATH": "/evil"}}, nil, "pane server is locked"},
+		{"pane default env of a window with a locked pane", WindowConfig{Name: "app", PaneDefaults: PaneDefaults{Env: map[string]string{"PATH": "/evil"}}}, nil, "pane server is locked"},
+		{"layout leaving out a locked pane", WindowConfig{Name: "app", Layout: LayoutNode{PaneName: "shell"}}, nil, "a layout of its window cannot leave it out"},
+		{"layout keeping a locked pane", WindowConfig{Name: "app", Layout: LayoutNode{Rows: []LayoutNode{{PaneName: "server"}, {PaneName: "shell"}}}}, nil, ""},
+		{"layout of a locked window", WindowConfig{Name: "ops", Layout: LayoutNode{PaneName: "deploy"}}, nil, "window ops is locked"},
+		{"grid of a locked window", WindowConfig{Name: "ops", Grid: "2x1"}, nil, "window ops is locked"},
+		{"env of a locked window", WindowConfig{Name: "ops", Env: map[string]string{"PATH": "/evil"}}, nil, "window ops is locked"},
+		{"options of a locked window", WindowConfig{Name: "ops", Options: map[string]string{"remain-on-exit": "on"}}, nil, "window ops is locked"},
+		{"var of a locked pane", WindowConfig{}, map[string]string{"TARGET": "x; rm -rf ~"}, "var TARGET is used by a locked window or pane"},
+		{"var a var of a locked pane refers to", WindowConfig{}, map[string]string{"STAGE": "$(curl evil)"}, "var STAGE is used by a locked window or pane"},
+		{"var of a locked window", WindowConfig{}, map[string]string{"DEPLOY": "curl evil"}, "var DEPLOY is used by a locked window or pane"},
+		{"var of an unlocked pane", WindowConfig{}, map[string]string{"EDITOR": "nano"}, ""},
+		{"command of an unlocked pane", WindowConfig{Name: "app", Panes: []PaneConfig{{Name: "shell", Command: "htop", Tools: map[string]string{"node": "20"}}}}, nil, ""},
+		{"style of a locked pane", WindowConfig{Name: "app", Panes: []PaneConfig{{Name: "server", Style: "bg=red"}}}, nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			overlay := &Config{Vars: tt.vars}
+			if tt.overlay.Name != "" {
+				overlay.Session.Windows = []WindowConfig{tt.overlay}
+			}
+			err := applyOverlay(base(), overlay)
+			if tt.want == "" && err != nil {
+				t.Errorf("applyOverlay() failed: %v", err)
+			}
+			if tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)) {
+				t.Errorf("applyOverlay() error = %v, want %s", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// tmux commands whose -t target defaults to the managed session in the passthrough
+var sessionTargetCommands = map[string]bool{
+	"attach-session":   true,
+	"capture-pane":     true,
+	"display-message":  true,
+	"has-session":      true,
+	"kill-session":     true,
+	"kill-window":      true,
+	"list-clients":     true,
+	"list-panes":       true,
+	"list-windows":     true,
+	"new-window":       true,
+	"pipe-pane":        true,
+	"rename-session":   true,
+	"rename-window":    true,
+	"resize-pane":      true,
+	"respawn-pane":     true,
+	"respawn-window":   true,
+	"select-layout":    true,
+	"select-pane":      true,
+	"select-window":    true,
+	"send-keys":        true,
+	"set-environment":  true,
+	"set-hook":         true,
+	"set-option":       true,
+	"show-environment": true,
+	"show-options":     true,
+	"split-window":     true,
+	"switch-client":    true,
+}
+
+// withSessionContext targets a raw tmux command at the session: a missing -t is added for
+// commands that accept one, and relative targets like ":logs" or ".1" are prefixed with the session
+func withSessionContext(args []string, sessionName string) []string {
+	if len(args) == 0 || sessionName == "" {
+		return args
+	}
+
+	result := []string{args[0]}
+	hasTarget := false
+	for i := 1; i < len(args); i++ {
+		result = append(result, args[i])
+		if args[i] == "-t" && i+1 < len(args) {
+			hasTarget = true
+			target := args[i+1]
+			if strings.HasPrefix(target, ":") || strings.HasPrefix(target, ".") {
+				target = sessionName + target
+			}
+			result = append(result, target)
+			i++
+		}
+	}
+
+	if !hasTarget && sessionTargetCommands[args[0]] {
+		result = append([]string{args[0], "-t", sessionName}, result[1:]...)
+	}
+	return result
+}
+
+// tmuxCommand runs a raw tmux command against the server and session of the configuration
+func tmuxCommand(fs *flag.FlagSet) func(args []string, opts upOptions) {
+	return func(args []string, opts upOptions) {
+		if len(args) == 0 {
+			log.Fatalf("Usage: gridlock tmux -- <tmux command> [args...]")
+		}
+
+		// The configuration is optional; without one only the socket flag applies
+		var sessionName string
+		config, err := loadConfig(opts.configFile)
+		if err == nil {
+			sessionName = config.Session.Name
+		} else {
+			config = nil
+		}
+		t := newTMUX(opts, config)
+
+		cmd := exec.Command("tmux", t.Args(withSessionContext(args, sessionName)...)...)
+		cmd.Stdin = os.Stdin
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) {
+				os.Exit(exitErr.ExitCode())
+			}
+			log.Fatalf("failed to run tmux: %v", err)
+		}
+	}
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Most entries the picker shows at once
+const pickerHeight = 15
+
+// pickerEntry is something the picker can bring up: a running session, a project directory
+// or a named project
+type pickerEntry struct {
+	kind  string
+	label string
+	// Configuration to bring up, empty for running sessions not created by gridlock
+	configFile string
+	session    string
+}
+
+// pickerEntries lists the running sessions first, then the known project directories and
+// the named projects
+func pickerEntries(opts upOptions) []pickerEntry {
+	var entries []pickerEntry
+	query := newTMUX(opts, nil)
+	query.DryRun = false
+	if sessions, err := query.runningSessions(); err == nil {
+		for _, s := range sessions {
+			if s.ScratchpadOf != "" {
+				continue
+			}
+			entries = append(entries, pickerEntry{kind: "session", label: s.Name, configFile: s.ConfigFile, session: s.Name})
+		}
+	}
+	home, _ := os.UserHomeDir()
+	for _, dir := range projectCandidates(os.Getenv("GRIDLOCK_PROVIDER")) {
+		label := dir
+		if home != "" && strings.HasPrefix(dir, home+"/") {
+			label = "~" + strings.TrimPrefix(dir, home)
+		}
+		entries = append(entries, pickerEntry{kind: "project", label: label, configFile: findProjectConfig(dir)})
+	}
+	for _, name := range namedProjects() {
+		if path, err := namedProjectPath(name); err == nil {
+			entries = append(entries, pickerEntry{kind: "named", label: name, configFile: path})
+		}
+	}
+	return entries
+}
+
+// fuzzyScore matches the characters of query in order within text, ignoring case. A lower
+// score is a better match: gaps between matched characters and a late first match cost.
+func fuzzyScore(text, query string) (int, bool) {
+	text, query = strings.ToLower(text), strings.ToLower(query)
+	score, pos, last := 0, 0, -1
+	for _, r := range query {
+		idx := strings.IndexRune(text[pos:], r)
+		if idx == -1 {
+			return 0, false
+		}
+		if last == -1 {
+			score += idx
+		} else {
+			score += pos + idx - last - 1
+		}
+		last = pos + idx
+		pos = last + utf8.RuneLen(r)
+	}
+	return score, true
+}
+
+// filterEntries returns the entries matching query, best first
+func filterEntries(entries []pickerEntry, query string) []pickerEntry {
+	type scored struct {
+		entry pickerEntry
+		score int
+	}
+	var matches []scored
+	for _, entry := range entries {
+		if score, ok := fuzzyScore(entry.label, query); ok {
+			matches = append(matches, scored{entry, score})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].score < matches[j].score })
+	filtered := make([]pickerEntry, len(matches))
+	for i, m := range matches {
+		filtered[i] = m.entry
+	}
+	return filtered
+}
+
+// pick lets the user choose an entry by typing to filter and moving with the arrow keys
+// or Ctrl-N and Ctrl-P, on the terminal rather than standard input and output. It returns
+// false when the user cancelled with Escape or Ctrl-C.
+func pick(entries []pickerEntry) (pickerEntry, bool, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return pickerEntry{}, false, fmt.Errorf("no terminal to pick a project on: %v", err)
+	}
+	defer tty.Close()
+	saved, err := stty(tty, "-g")
+	if err != nil {
+		return pickerEntry{}, false, err
+	}
+	if _, err := stty(tty, "raw", "-echo"); err != nil {
+		return pickerEntry{}, false, err
+	}
+	defer stty(tty, strings.TrimSpace(saved))
+
+	_, noColor := os.LookupEnv("NO_COLOR")
+	out := &output{w: tty, color: !noColor && os.Getenv("TERM") != "dumb"}
+	if width, _, ok := terminalSize(); ok {
+		out.width = width
+	}
+	query := ""
+	selected := 0
+	drawn := 0
+	buf := make([]byte, 16)
+	for {
+		matches := filterEntries(entries, query)
+		selected = min(selected, max(len(matches)-1, 0))
+		drawn = drawPicker(out, matches, len(entries), query, selected, drawn)
+
+		n, err := tty.Read(buf)
+		if err != nil {
+			return pickerEntry{}, false, err
+		}
+		key := string(buf[:n])
+		switch key {
+		case "\r", "\n":
+			clearPicker(out, drawn)
+			if len(matches) == 0 {
+				return pickerEntry{}, false, nil
+			}
+			return matches[selected], true, nil
+		case "\x1b", "\x03", "\x04":
+			clearPicker(out, drawn)
+			return pickerEntry{}, false, nil
+		case "\x1b[A", "\x1bOA", "\x10":
+			selected = max(selected-1, 0)
+		case "\x1b[B", "\x1bOB", "\x0e":
+			selected++
+		case "\x7f", "\x08":
+			if query != "" {
+				_, size := utf8.DecodeLastRuneInString(query)
+				query = query[:len(query)-size]
+				selected = 0
+			}
+		case "\x15":
+			query, selected = "", 0
+		default:
+			if utf8.ValidString(key) && key[0] >= 0x20 {
+				query += key
+				selected = 0
+			}
+		}
+	}
+}
+
+// drawPicker draws the visible matches, a count and the prompt below them, replacing the
+// previous frame, and leaves the cursor at the end of the prompt. It returns the number of
+// lines above the prompt.
+func drawPicker(out *output, matches []pickerEntry, total int, query string, selected int, drawn int) int {
+	var b strings.Builder
+	if drawn > 0 {
+		fmt.Fprintf(&b, "\x1b[%dA", drawn)
+	}
+	b.WriteString("\r\x1b[J")
+	first := max(selected-pickerHeight+1, 0)
+	lines := 0
+	for i := first; i < len(matches) && i < first+pickerHeight; i++ {
+		entry := matches[i]
+		line := fmt.Sprintf("  %-8s %s", entry.kind, entry.label)
+		if out.width > 0 {
+			line = truncate(line, out.width-1)
+		}
+		if i == selected {
+			line = ">" + line[1:]
+			line = out.style("7", line)
+		}
+		b.WriteString(line + "\r\n")
+		lines++
+	}
+	fmt.Fprintf(&b, "%s\r\n", out.style(styleDim, fmt.Sprintf("  %d/%d", len(matches), total)))
+	lines++
+	b.WriteString("> " + query)
+	fmt.Fprint(out.w, b.String())
+	return lines
+}
+
+// clearPicker removes the drawn picker from the terminal
+func clearPicker(out *output, drawn int) {
+	if drawn > 0 {
+		fmt.Fprintf(out.w, "\x1b[%dA", drawn)
+	}
+	fmt.Fprint(out.w, "\r\x1b[J")
+}
+
+// stty runs stty with args on the terminal
+func stty(tty *os.File, args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = tty
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("stty %s: %v", strings.Join(args, " "), err)
+	}
+	return string(out), nil
+}
+
+// runPicker opens the picker and brings up the chosen session or project
+func runPicker(opts upOptions) {
+	entries := pickerEntries(opts)
+	if len(entries) == 0 {
+		log.Fatalf("No %s here and no projects or sessions to pick from, create one with gridlock init", opts.configFile)
+	}
+	entry, ok, err := pick(entries)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	if !ok {
+		return
+	}
+	if entry.configFile == "" {
+		attachSession(newTMUX(opts, nil), entry.session)
+		return
+	}
+	if entry.kind != "named" {
+		// Relative working directories in the config are resolved against the project
+		if err := os.Chdir(filepath.Dir(entry.configFile)); err != nil {
+			log.Fatalf("failed to change directory: %v", err)
+		}
+	}
+	opts.configFile = entry.configFile
+	up(opts)
+}
+
+// attachSession attaches to a session gridlock has no configuration of, switching to it
+// when running inside tmux
+func attachSession(t *TMUX, name string) {
+	if os.Getenv("TMUX") != "" {
+		if err := t.switchClient(name, "="+name); err != nil {
+			log.Fatalf("%v", err)
+		}
+		return
+	}
+	if err := t.attachClient(name, "attach-session", "-t", "="+name); err != nil {
+		log.Fatalf("%v", err)
+	}
+}
+
+// pickCommand opens the project picker even when the directory has a configuration
+func pickCommand(fs *flag.FlagSet) func(args []string, opts upOptions) {
+	return func(args []string, opts upOptions) {
+		runPicker(opts)
+	}
+}
+
+// shouldPick reports whether gridlock run without arguments opens the picker: on a terminal,
+// with the default configuration file missing and no flags that only make sense for it
+func shouldPick(opts upOptions, args []string, defaultConfig string) bool {
+	if len(args) > 0 || opts.configFile != defaultConfig || opts.detached || opts.dryRun || opts.current || opts.all || opts.attachCommandTo != nil {
+		return false
+	}
+	if _, err := os.Stat(resolveConfigPath(opts.configFile)); !os.IsNotExist(err) {
+		return false
+	}
+	return isTerminal(os.Stdin) && isTerminal(os.Stdout)
+}
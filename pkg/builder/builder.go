@@ -0,0 +1,334 @@
+// Package builder creates tmux sessions from gridlock configurations, so other Go tools can
+// embed session orchestration instead of running the gridlock binary.
+package builder
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/esaiaswestberg/gridlock/pkg/config"
+	"github.com/esaiaswestberg/gridlock/pkg/tmux"
+)
+
+// User options in which the names of the configured windows and panes are recorded, so
+// gridlock status, apply and run-commands recognize sessions created by a Builder
+const (
+	WindowOption = "@gridlock-window"
+	PaneOption   = "@gridlock-pane"
+)
+
+// LayoutPresets are tmux's built-in layouts, which a layout node can name as its Preset to
+// have its panes arranged by tmux, with the option sizing the main pane of those that have one
+var LayoutPresets = map[string]string{
+	"even-horizontal": "",
+	"even-vertical":   "",
+	"main-horizontal": "main-pane-height",
+	"main-vertical":   "main-pane-width",
+	"tiled":           "",
+}
+
+// Builder creates the windows and panes of a configuration in a tmux server
+type Builder struct {
+	// Client runs the tmux commands, against the default server if nil
+	Client *tmux.Client
+}
+
+// New returns a Builder running its tmux commands with client
+func New(client *tmux.Client) *Builder {
+	return &Builder{Client: client}
+}
+
+func (b *Builder) run(ctx context.Context, args ...string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return b.Client.Run(ctx, args...)
+}
+
+// Up creates the session of cfg in the background, leaving a session of the same name
+// alone when it is running. Windows are laid out with Layout, as the gridlock command lays
+// them out, and their panes get their directories, shells, environment, styles, titles and
+// commands, with their delays, after which the panes and window with focus are selected.
+// The configuration is used as is: the shorthands the gridlock command resolves first, such
+// as variables, components, grids, pane kinds, tools and pane defaults, and its extras, such
+// as hooks, keep-open, logging and readiness checks, are up to the caller.
+func (b *Builder) Up(ctx context.Context, cfg *config.Config) error {
+	if b.Client == nil {
+		b.Client = &tmux.Client{}
+	}
+	session := &cfg.Session
+	if session.Name == "" {
+		return fmt.Errorf("session has no name")
+	}
+	if len(session.Windows) == 0 {
+		return fmt.Errorf("session %s has no windows", session.Name)
+	}
+	if _, err := b.run(ctx, "has-session", "-t", "="+session.Name); err == nil && !b.Client.DryRun {
+		return nil
+	}
+
+	sessionDir := ExpandPath(session.WorkingDirectory)
+	// Windows are targeted by ID, as users with renumber-windows on see indices change
+	args := []string{"new-session", "-d", "-s", session.Name, "-n", session.Windows[0].Name, "-P", "-F", "#{window_id}"}
+	if sessionDir != "" {
+		args = append(args, "-c", sessionDir)
+	}
+	out, err := b.run(ctx, args...)
+	if err != nil {
+		return fmt.Errorf("failed to create session: %v", err)
+	}
+	windowID := strings.TrimSpace(out)
+	// The first window is selected unless another has focus
+	focusWindow := session.Name + ":^"
+	for _, k := range sortedKeys(session.Env) {
+		if _, err := b.run(ctx, "set-environment", "-t", session.Name, k, session.Env[k]); err != nil {
+			return err
+		}
+	}
+
+	for i := range session.Windows {
+		window := &session.Windows[i]
+		startDir := sessionDir
+		if i > 0 {
+			startDir = ExpandPath(firstNonEmpty(window.WorkingDirectory, session.WorkingDirectory))
+			args := []string{"new-window", "-d", "-P", "-F", "#{window_id}", "-t", session.Name + ":", "-n", window.Name}
+			if startDir != "" {
+				args = append(args, "-c", startDir)
+			}
+			out, err := b.run(ctx, args...)
+			if err != nil {
+				return fmt.Errorf("failed to create window %s: %v", window.Name, err)
+			}
+			windowID = strings.TrimSpace(out)
+		}
+		if windowID == "" {
+			// Nothing prints the window ID in dry-run mode
+			windowID = session.Name + ":" + window.Name
+		}
+		focus, err := b.buildWindow(ctx, windowID, window, session.WorkingDirectory, startDir)
+		if err != nil {
+			return fmt.Errorf("window %s: %v", window.Name, err)
+		}
+		if focus != "" {
+			if _, err := b.run(ctx, "select-pane", "-t", focus); err != nil {
+				return err
+			}
+		}
+		if window.Focus {
+			focusWindow = windowID
+		}
+	}
+	_, err = b.run(ctx, "select-window", "-t", focusWindow)
+	return err
+}
+
+// buildWindow lays out a freshly created window and sets up its panes. It returns the
+// target of the pane to focus, if the window has one.
+func (b *Builder) buildWindow(ctx context.Context, windowID string, window *config.WindowConfig, sessionDir string, startDir string) (string, error) {
+	if _, err := b.run(ctx, "set-option", "-w", "-t", windowID, WindowOption, window.Name); err != nil {
+		return "", err
+	}
+	layout := window.Layout
+	if layout.PaneName == "" && len(layout.Columns) == 0 && len(layout.Rows) == 0 {
+		// Without a layout the panes sit side by side, as they do before a preset arranges them
+		for _, pane := range window.Panes {
+			layout.Columns = append(layout.Columns, config.LayoutNode{PaneName: pane.Name})
+		}
+	}
+	if layout.Preset != "" {
+		if _, ok := LayoutPresets[layout.Preset]; !ok {
+			return "", fmt.Errorf("unknown layout preset %q", layout.Preset)
+		}
+	}
+	// Panes are targeted by index, counted from the window's pane-base-index
+	paneBase := 0
+	if out, err := b.run(ctx, "display-message", "-p", "-t", windowID, "#{pane_index}"); err != nil {
+		return "", err
+	} else if index, err := strconv.Atoi(strings.TrimSpace(out)); err == nil {
+		paneBase = index
+	}
+
+	var firstErr error
+	fail := func(err error) {
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	focus := ""
+	l := &Layout{
+		Run: func(args ...string) (string, error) {
+			return b.run(ctx, args...)
+		},
+		PaneBase: paneBase,
+		Dir: func(node config.LayoutNode) string {
+			return nodeDir(node, window, sessionDir)
+		},
+		Pane: func(target string, node config.LayoutNode, startDir string) {
+			pane := findPane(window, node.PaneName)
+			if pane == nil {
+				fail(fmt.Errorf("layout references unknown pane %s", node.PaneName))
+				return
+			}
+			if pane.Focus {
+				focus = target
+			}
+			if err := b.setupPane(ctx, target, pane, nodeDir(node, window, sessionDir), startDir); err != nil {
+				fail(fmt.Errorf("pane %s: %v", pane.Name, err))
+			}
+		},
+		Failed: func(target string, step string, err error) {
+			fail(fmt.Errorf("%s failed for %s: %v", step, target, err))
+		},
+	}
+	l.Build(windowID, 0, layout, startDir)
+	if firstErr != nil {
+		return "", firstErr
+	}
+
+	if layout.Preset != "" {
+		if option := LayoutPresets[layout.Preset]; option != "" && window.MainPaneSize != "" {
+			if _, err := b.run(ctx, "set-option", "-w", "-t", windowID, option, window.MainPaneSize); err != nil {
+				return "", err
+			}
+		}
+		if _, err := b.run(ctx, "select-layout", "-t", windowID, layout.Preset); err != nil {
+			return "", err
+		}
+	}
+	return focus, nil
+}
+
+// setupPane restarts a pane with its shell, environment and directory where they differ from
+// how it was started, and types its commands into it
+func (b *Builder) setupPane(ctx context.Context, target string, pane *config.PaneConfig, dir string, startDir string) error {
+	if _, err := b.run(ctx, "set-option", "-p", "-t", target, PaneOption, pane.Name); err != nil {
+		return err
+	}
+	if pane.Shell != "" || len(pane.Env) > 0 || dir != startDir {
+		args := []string{"respawn-pane", "-k", "-t", target}
+		if dir != "" {
+			args = append(args, "-c", dir)
+		}
+		for _, k := range sortedKeys(pane.Env) {
+			args = append(args, "-e", k+"="+pane.Env[k])
+		}
+		if pane.Shell != "" {
+			args = append(args, pane.Shell)
+		}
+		if _, err := b.run(ctx, args...); err != nil {
+			return err
+		}
+	}
+	if pane.Style != "" {
+		if _, err := b.run(ctx, "select-pane", "-t", target, "-P", pane.Style); err != nil {
+			return err
+		}
+	}
+	if pane.Title != "" {
+		if _, err := b.run(ctx, "select-pane", "-t", target, "-T", pane.Title); err != nil {
+			return err
+		}
+	}
+	commands := append(config.Commands{}, pane.Commands...)
+	if pane.Command != "" {
+		commands = append(config.Commands{{Run: pane.Command}}, commands...)
+	}
+	for _, command := range commands {
+		if err := b.delay(ctx, command.Delay); err != nil {
+			return err
+		}
+		args := []string{"send-keys", "-t", target}
+		if command.Literal {
+			args = append(args, "-l")
+		}
+		if _, err := b.run(ctx, append(args, command.Line())...); err != nil {
+			return err
+		}
+		if command.Enter == nil || *command.Enter {
+			if _, err := b.run(ctx, "send-keys", "-t", target, "C-m"); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// delay waits for the delay of a command, unless the context is done first. Nothing runs
+// in dry-run mode or against an executor, so there is nothing to wait for.
+func (b *Builder) delay(ctx context.Context, delay string) error {
+	if delay == "" || b.Client.DryRun || b.Client.Executor != nil {
+		return nil
+	}
+	d, err := time.ParseDuration(delay)
+	if err != nil {
+		return fmt.Errorf("invalid delay %q: %v", delay, err)
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// nodeDir returns the directory of the first pane of a layout node
+func nodeDir(node config.LayoutNode, window *config.WindowConfig, sessionDir string) string {
+	for node.PaneName == "" && (len(node.Columns) > 0 || len(node.Rows) > 0) {
+		if len(node.Columns) > 0 {
+			node = node.Columns[0]
+		} else {
+			node = node.Rows[0]
+		}
+	}
+	if pane := findPane(window, node.PaneName); pane != nil && pane.WorkingDirectory != "" {
+		return ExpandPath(pane.WorkingDirectory)
+	}
+	return ExpandPath(firstNonEmpty(window.WorkingDirectory, sessionDir))
+}
+
+func findPane(window *config.WindowConfig, name string) *config.PaneConfig {
+	for i := range window.Panes {
+		if window.Panes[i].Name == name {
+			return &window.Panes[i]
+		}
+	}
+	return nil
+}
+
+// ExpandPath expands a leading ~ to the home directory
+func ExpandPath(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, strings.TrimPrefix(path, "~"))
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,165 @@
+package builder
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/esaiaswestberg/gridlock/pkg/config"
+	"github.com/esaiaswestberg/gridlock/pkg/tmux"
+)
+
+// fakeServer records the tmux commands a Builder runs against a server without sessions,
+// whose windows start their panes at paneBase
+type fakeServer struct {
+	commands []string
+	running  bool
+	paneBase string
+	windows  int
+}
+
+func (s *fakeServer) exec(args []string) (string, error) {
+	s.commands = append(s.commands, strings.Join(args, " "))
+	switch args[0] {
+	case "has-session":
+		if !s.running {
+			return "", errors.New("can't find session")
+		}
+	case "new-session", "new-window":
+		s.windows++
+		return "@" + string(rune('0'+s.windows)) + "\n", nil
+	case "display-message":
+		return s.paneBase + "\n", nil
+	}
+	return "", nil
+}
+
+func upWith(t *testing.T, server *fakeServer, cfg *config.Config) error {
+	t.Helper()
+	return New(&tmux.Client{Executor: server.exec}).Up(context.Background(), cfg)
+}
+
+func TestUp(t *testing.T) {
+	enter := false
+	cfg := &config.Config{Session: config.SessionConfig{
+		Name:             "dev",
+		WorkingDirectory: "/src",
+		Env:              map[string]string{"MODE": "dev"},
+		Windows: []config.WindowConfig{
+			{
+				Name: "editor",
+				Panes: []config.PaneConfig{
+					{Name: "vim", Command: "vim", Title: "code"},
+					{Name: "server", WorkingDirectory: "/srv", Env: map[string]string{"PORT": "8080"}, Focus: true,
+						Commands: config.Commands{{Run: "make", Delay: "1s"}, {Run: "C-c", Enter: &enter}}},
+				},
+				Layout: config.LayoutNode{Columns: []config.LayoutNode{{PaneName: "vim", Size: "70%"}, {PaneName: "server"}}},
+			},
+			{
+				Name:         "logs",
+				Focus:        true,
+				MainPaneSize: "60%",
+				Panes:        []config.PaneConfig{{Name: "tail", Style: "bg=black"}, {Name: "shell"}},
+				Layout:       config.LayoutNode{Preset: "main-vertical"},
+			},
+		},
+	}}
+	server := &fakeServer{paneBase: "1"}
+	if err := upWith(t, server, cfg); err != nil {
+		t.Fatalf("Up failed: %v", err)
+	}
+	want := []string{
+		"has-session -t =dev",
+		"new-session -d -s dev -n editor -P -F #{window_id} -c /src",
+		"set-environment -t dev MODE dev",
+		"set-option -w -t @1 @gridlock-window editor",
+		"display-message -p -t @1 #{pane_index}",
+		"split-window -h -p 30 -t @1.1 -c /srv",
+		"set-option -p -t @1.1 @gridlock-pane vim",
+		"select-pane -t @1.1 -T code",
+		"send-keys -t @1.1 vim",
+		"send-keys -t @1.1 C-m",
+		"set-option -p -t @1.2 @gridlock-pane server",
+		"respawn-pane -k -t @1.2 -c /srv -e PORT=8080",
+		"send-keys -t @1.2 make",
+		"send-keys -t @1.2 C-m",
+		"send-keys -t @1.2 C-c",
+		"select-pane -t @1.2",
+		"new-window -d -P -F #{window_id} -t dev: -n logs -c /src",
+		"set-option -w -t @2 @gridlock-window logs",
+		"display-message -p -t @2 #{pane_index}",
+		"split-window -h -p 50 -t @2.1 -c /src",
+		"set-option -p -t @2.1 @gridlock-pane tail",
+		"select-pane -t @2.1 -P bg=black",
+		"set-option -p -t @2.2 @gridlock-pane shell",
+		"set-option -w -t @2 main-pane-width 60%",
+		"select-layout -t @2 main-vertical",
+		"select-window -t @2",
+	}
+	if !reflect.DeepEqual(server.commands, want) {
+		t.Errorf("commands:\n%s\nwant:\n%s", strings.Join(server.commands, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestUpLeavesRunningSessionAlone(t *testing.T) {
+	server := &fakeServer{running: true}
+	cfg := &config.Config{Session: config.SessionConfig{Name: "dev", Windows: []config.WindowConfig{{Name: "w"}}}}
+	if err := upWith(t, server, cfg); err != nil {
+		t.Fatalf("Up failed: %v", err)
+	}
+	if want := []string{"has-session -t =dev"}; !reflect.DeepEqual(server.commands, want) {
+		t.Errorf("commands = %q, want %q", server.commands, want)
+	}
+}
+
+func TestUpErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		session config.SessionConfig
+		want    string
+	}{
+		{"no name", config.SessionConfig{Windows: []config.WindowConfig{{Name: "w"}}}, "session has no name"},
+		{"no windows", config.SessionConfig{Name: "dev"}, "session dev has no windows"},
+		{
+			"unknown pane",
+			config.SessionConfig{Name: "dev", Windows: []config.WindowConfig{{
+				Name:   "w",
+				Panes:  []config.PaneConfig{{Name: "vim"}},
+				Layout: config.LayoutNode{Columns: []config.LayoutNode{{PaneName: "vim"}, {PaneName: "emacs"}}},
+			}}},
+			"window w: layout references unknown pane emacs",
+		},
+		{
+			"unknown preset",
+			config.SessionConfig{Name: "dev", Windows: []config.WindowConfig{{
+				Name:   "w",
+				Panes:  []config.PaneConfig{{Name: "vim"}},
+				Layout: config.LayoutNode{Preset: "spiral"},
+			}}},
+			`window w: unknown layout preset "spiral"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := upWith(t, &fakeServer{paneBase: "0"}, &config.Config{Session: tt.session})
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("Up() error = %v, want %s", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	server := &fakeServer{}
+	cfg := &config.Config{Session: config.SessionConfig{Name: "dev", Windows: []config.WindowConfig{{Name: "w"}}}}
+	if err := New(&tmux.Client{Executor: server.exec}).Up(ctx, cfg); err == nil || !strings.Contains(err.Error(), context.Canceled.Error()) {
+		t.Errorf("Up() error = %v, want %v", err, context.Canceled)
+	}
+	if len(server.commands) > 0 {
+		t.Errorf("commands = %q, want none", server.commands)
+	}
+}
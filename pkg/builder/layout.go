@@ -0,0 +1,76 @@
+package builder
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/esaiaswestberg/gridlock/pkg/config"
+)
+
+// Layout splits a window into the panes of a layout. Both Builder.Up and the gridlock command
+// lay out their windows with it, so they split, size and place panes the same way and only
+// differ in how they set up the panes once they exist.
+type Layout struct {
+	// Run runs a tmux command
+	Run func(args ...string) (string, error)
+	// PaneBase is the index of the first pane of a window, tmux's pane-base-index
+	PaneBase int
+	// Dir returns the directory the panes of a node start in, that of its first pane
+	Dir func(node config.LayoutNode) string
+	// Pane sets up the pane at target created for a leaf node, which was started in startDir
+	Pane func(target string, node config.LayoutNode, startDir string)
+	// Failed is told about a split that failed. The rest of the layout is still built, with
+	// fewer panes than it has nodes.
+	Failed func(target string, step string, err error)
+}
+
+// Target returns the target of the pane with the given index, counted from 0, in a window
+func (l *Layout) Target(windowTarget string, index int) string {
+	return fmt.Sprintf("%s.%d", windowTarget, l.PaneBase+index)
+}
+
+// Build lays out node in the pane with the given index of a window, started in startDir.
+// It returns the index of the pane after the last one the node took.
+func (l *Layout) Build(windowTarget string, index int, node config.LayoutNode, startDir string) int {
+	children, flag, sizeFlag := node.Columns, "-h", "-x"
+	if len(children) == 0 {
+		children, flag, sizeFlag = node.Rows, "-v", "-y"
+	}
+	if node.PaneName != "" || len(children) == 0 {
+		if node.PaneName != "" {
+			l.Pane(l.Target(windowTarget, index), node, startDir)
+		}
+		return index + 1
+	}
+
+	// Each split divides the last pane into the next node and the space left after it
+	for i, percentage := range SplitPercentages(children) {
+		target := l.Target(windowTarget, index+i)
+		args := []string{"split-window", flag, "-p", strconv.Itoa(percentage), "-t", target}
+		if dir := l.Dir(children[i+1]); dir != "" {
+			args = append(args, "-c", dir)
+		}
+		if _, err := l.Run(args...); err != nil {
+			l.Failed(target, "split-window", err)
+		}
+	}
+	// Nodes sized in cells get their size once the pane has been split among them. A pane
+	// that cannot get it, e.g. in a window too small, keeps the size it was split to.
+	for i, child := range children {
+		value, percent, err := config.ParseSize(child.Size)
+		if child.Size == "" || err != nil || percent {
+			continue
+		}
+		l.Run("resize-pane", "-t", l.Target(windowTarget, index+i), sizeFlag, strconv.Itoa(value))
+	}
+
+	current := index
+	for i, child := range children {
+		childDir := startDir
+		if i > 0 {
+			childDir = l.Dir(child)
+		}
+		current = l.Build(windowTarget, current, child, childDir)
+	}
+	return current
+}
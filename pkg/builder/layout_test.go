@@ -0,0 +1,127 @@
+package builder
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/esaiaswestberg/gridlock/pkg/config"
+)
+
+// recordLayout builds node in window w with a Layout that records the tmux commands it runs
+// and the panes it sets up, with their start directory. dirs maps pane names to directories.
+func recordLayout(node config.LayoutNode, paneBase int, dirs map[string]string, fail string) (commands []string, panes []string, failed []string) {
+	l := &Layout{
+		Run: func(args ...string) (string, error) {
+			commands = append(commands, strings.Join(args, " "))
+			if fail != "" && strings.Contains(strings.Join(args, " "), fail) {
+				return "", errors.New("no space for new pane")
+			}
+			return "", nil
+		},
+		PaneBase: paneBase,
+		Dir: func(node config.LayoutNode) string {
+			for node.PaneName == "" && len(node.Columns)+len(node.Rows) > 0 {
+				node = append(node.Columns, node.Rows...)[0]
+			}
+			return dirs[node.PaneName]
+		},
+		Pane: func(target string, node config.LayoutNode, startDir string) {
+			panes = append(panes, node.PaneName+"@"+target+" in "+startDir)
+		},
+		Failed: func(target string, step string, err error) {
+			failed = append(failed, step+" "+target)
+		},
+	}
+	next := l.Build("w", 0, node, dirs[""])
+	panes = append(panes, "next "+l.Target("w", next))
+	return commands, panes, failed
+}
+
+func TestLayoutBuild(t *testing.T) {
+	pane := func(name string) config.LayoutNode { return config.LayoutNode{PaneName: name} }
+	dirs := map[string]string{"": "/home", "editor": "/src", "server": "/srv", "logs": "/var/log", "shell": "/tmp"}
+
+	tests := []struct {
+		name         string
+		node         config.LayoutNode
+		paneBase     int
+		wantCommands []string
+		wantPanes    []string
+	}{
+		{
+			name:         "single pane",
+			node:         pane("editor"),
+			wantCommands: nil,
+			wantPanes:    []string{"editor@w.0 in /home", "next w.1"},
+		},
+		{
+			name: "columns",
+			node: config.LayoutNode{Columns: []config.LayoutNode{pane("editor"), pane("server"), pane("logs")}},
+			wantCommands: []string{
+				"split-window -h -p 66 -t w.0 -c /srv",
+				"split-window -h -p 50 -t w.1 -c /var/log",
+			},
+			wantPanes: []string{"editor@w.0 in /home", "server@w.1 in /srv", "logs@w.2 in /var/log", "next w.3"},
+		},
+		{
+			name: "rows nested in columns with sizes",
+			node: config.LayoutNode{Columns: []config.LayoutNode{
+				{PaneName: "editor", Size: "30%"},
+				{Rows: []config.LayoutNode{{PaneName: "server", Size: "10"}, pane("logs")}},
+			}},
+			wantCommands: []string{
+				"split-window -h -p 70 -t w.0 -c /srv",
+				"split-window -v -p 50 -t w.1 -c /var/log",
+				"resize-pane -t w.1 -y 10",
+			},
+			wantPanes: []string{"editor@w.0 in /home", "server@w.1 in /srv", "logs@w.2 in /var/log", "next w.3"},
+		},
+		{
+			name:     "columns nested in rows counted from the pane base",
+			paneBase: 1,
+			node: config.LayoutNode{Rows: []config.LayoutNode{
+				{Columns: []config.LayoutNode{pane("editor"), pane("server")}},
+				{Columns: []config.LayoutNode{pane("logs"), pane("shell")}},
+			}},
+			wantCommands: []string{
+				"split-window -v -p 50 -t w.1 -c /var/log",
+				"split-window -h -p 50 -t w.1 -c /srv",
+				"split-window -h -p 50 -t w.3 -c /tmp",
+			},
+			wantPanes: []string{"editor@w.1 in /home", "server@w.2 in /srv", "logs@w.3 in /var/log", "shell@w.4 in /tmp", "next w.5"},
+		},
+		{
+			name:      "empty node",
+			node:      config.LayoutNode{},
+			wantPanes: []string{"next w.1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			commands, panes, failed := recordLayout(tt.node, tt.paneBase, dirs, "")
+			if !reflect.DeepEqual(commands, tt.wantCommands) {
+				t.Errorf("commands = %q, want %q", commands, tt.wantCommands)
+			}
+			if !reflect.DeepEqual(panes, tt.wantPanes) {
+				t.Errorf("panes = %q, want %q", panes, tt.wantPanes)
+			}
+			if len(failed) > 0 {
+				t.Errorf("failed = %q, want none", failed)
+			}
+		})
+	}
+}
+
+func TestLayoutBuildReportsFailedSplits(t *testing.T) {
+	node := config.LayoutNode{Columns: []config.LayoutNode{{PaneName: "editor"}, {PaneName: "server"}, {PaneName: "logs"}}}
+	commands, panes, failed := recordLayout(node, 0, map[string]string{}, "-t w.1")
+	if want := []string{"split-window w.1"}; !reflect.DeepEqual(failed, want) {
+		t.Errorf("failed = %q, want %q", failed, want)
+	}
+	// The layout is still built as far as it goes
+	if len(commands) != 2 || len(panes) != 4 {
+		t.Errorf("commands = %q, panes = %q, want 2 splits and 3 panes", commands, panes)
+	}
+}
@@ -0,0 +1,61 @@
+package builder
+
+import "github.com/esaiaswestberg/gridlock/pkg/config"
+
+// hasSizes reports whether any of the nodes has an explicit size
+func hasSizes(nodes []config.LayoutNode) bool {
+	for _, node := range nodes {
+		if node.Size != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// SplitPercentages returns the percentage passed to split-window for each of the n-1 splits
+// that divide a pane among n sibling nodes. Each split divides the pane of node i into
+// node i and the space left for the nodes after it. Nodes without a percentage, including
+// those sized in cells (resized once split), share the percentage left over equally.
+func SplitPercentages(nodes []config.LayoutNode) []int {
+	n := len(nodes)
+	if !hasSizes(nodes) {
+		percentages := make([]int, n-1)
+		for i := range percentages {
+			percentages[i] = 100 * (n - 1 - i) / (n - i)
+		}
+		return percentages
+	}
+
+	weights := make([]float64, n)
+	sized, total := 0, 0
+	for i, node := range nodes {
+		if value, percent, err := config.ParseSize(node.Size); err == nil && percent {
+			weights[i] = float64(value)
+			sized++
+			total += value
+		}
+	}
+	if sized < n {
+		share := float64(100-total) / float64(n-sized)
+		for i, node := range nodes {
+			if _, percent, err := config.ParseSize(node.Size); err != nil || !percent {
+				weights[i] = share
+			}
+		}
+	}
+
+	percentages := make([]int, n-1)
+	for i := range percentages {
+		rest := 0.0
+		for _, weight := range weights[i+1:] {
+			rest += weight
+		}
+		percentage := 50
+		if all := weights[i] + rest; all > 0 {
+			percentage = int(100*rest/all + 0.5)
+		}
+		// tmux needs at least a cell on both sides of the split
+		percentages[i] = min(max(percentage, 1), 99)
+	}
+	return percentages
+}
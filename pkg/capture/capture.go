@@ -0,0 +1,214 @@
+// Package capture records a running tmux session as a gridlock configuration, as gridlock
+// init --save-current does.
+package capture
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/esaiaswestberg/gridlock/pkg/config"
+)
+
+// Options control how a session is captured
+type Options struct {
+	// Socket name of the tmux server (tmux -L), empty for the default server
+	Socket string
+	// Record panes that are in copy-mode and their scroll position
+	CopyMode bool
+	// Naming strategy for panes, PaneNamesCommand (default) or PaneNamesIndex
+	PaneNames string
+	// Wrapper processes whose child is captured as the pane command, DefaultWrappers if nil
+	Wrappers []string
+	// Record the command line a pane runs, e.g. npm run dev, rather than only the program
+	FullCommand bool
+	// Runs a tmux command and returns its output, instead of the tmux binary
+	Run func(args ...string) (string, error)
+}
+
+// Session captures the named session of the default tmux server
+func Session(name string) (*config.Config, error) {
+	return SessionWith(name, Options{})
+}
+
+// SessionWith captures the named session with the given options
+func SessionWith(sessionName string, opts Options) (*config.Config, error) {
+	run := opts.Run
+	if run == nil {
+		run = tmuxRunner(opts.Socket)
+	}
+
+	// Verify session exists, matching its name exactly rather than as a prefix
+	_, err := run("has-session", "-t", "="+sessionName)
+	if err != nil {
+		return nil, fmt.Errorf("session %s not found", sessionName)
+	}
+
+	namer, err := newPaneNamer(opts.PaneNames)
+	if err != nil {
+		return nil, err
+	}
+	wrappers := make(map[string]bool)
+	if opts.Wrappers == nil {
+		opts.Wrappers = DefaultWrappers
+	}
+	for _, name := range opts.Wrappers {
+		wrappers[name] = true
+	}
+
+	// Get Windows. The name goes last as it may contain spaces
+	out, err := run("list-windows", "-t", sessionName, "-F", "#{window_id} #{window_layout} #{window_name}")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list windows: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	var windows []config.WindowConfig
+	var windowIDs []string
+
+	// Get Session CWD (from first pane of first window usually, or just assume user home for now,
+	// but let's try to infer from common prefix later? No, let's just leave it empty and set per-window/pane)
+	// Actually, tmux has a session working directory but it's not easily exposed unless we look at the session creation time or just ignore it.
+	// We will rely on window/pane working directories.
+
+	for _, line := range lines {
+		parts := strings.SplitN(line, " ", 3)
+		if len(parts) < 3 {
+			continue
+		}
+		winID := parts[0]
+		layoutStr := parts[1]
+		winName := parts[2]
+		windowIDs = append(windowIDs, winID)
+
+		// Get Panes for this window
+		paneOut, err := run("list-panes", "-t", winID, "-F", "#{pane_id} #{pane_mode} #{scroll_position} #{pane_pid} #{pane_current_command} #{pane_current_path}")
+		if err != nil {
+			return nil, fmt.Errorf("failed to list panes for window %s: %v", winName, err)
+		}
+
+		paneLines := strings.Split(strings.TrimSpace(paneOut), "\n")
+		var panes []config.PaneConfig
+		paneIDMap := make(map[int]string)
+
+		for i, pLine := range paneLines {
+			// The path goes last as it may contain spaces
+			pParts := strings.SplitN(pLine, " ", 6)
+			if len(pParts) < 6 {
+				continue
+			}
+			pIDStr := pParts[0]
+			pMode := pParts[1]
+			pScroll := pParts[2]
+			pPID := pParts[3]
+			pCmd := unwrapCommand(pPID, pParts[4], wrappers)
+			pPath := pParts[5]
+
+			// Generate a name
+			pName := namer.name(winName, i, pCmd, pPath)
+			if opts.FullCommand {
+				if full := fullCommand(pPID); full != "" {
+					pCmd = full
+				}
+			}
+
+			// Try to simplify path
+			home, _ := os.UserHomeDir()
+			if strings.HasPrefix(pPath, home) {
+				pPath = "~" + strings.TrimPrefix(pPath, home)
+			}
+
+			// Clean up command (if it's just a shell, maybe ignore it? No, keep it.)
+			// If it's bash/zsh/sh, it might be the default shell, but explicit is okay.
+
+			pane := config.PaneConfig{
+				Name:             pName,
+				WorkingDirectory: pPath,
+				Command:          pCmd,
+			}
+			if opts.CopyMode && pMode == "copy-mode" {
+				scroll, _ := strconv.Atoi(pScroll)
+				pane.CopyMode = &config.CopyModeConfig{ScrollPosition: scroll}
+			}
+			panes = append(panes, pane)
+
+			// Map ID (remove %) to name
+			idVal, _ := strconv.Atoi(strings.TrimPrefix(pIDStr, "%"))
+			paneIDMap[idVal] = pName
+		}
+
+		// Parse Layout
+		layoutNode, err := ParseLayout(layoutStr, paneIDMap)
+		if err != nil {
+			// Fallback: just columns
+			log.Printf("Warning: failed to parse layout for window %s: %v. Using simple column layout.", winName, err)
+			var cols []config.LayoutNode
+			for _, p := range panes {
+				cols = append(cols, config.LayoutNode{PaneName: p.Name})
+			}
+			layoutNode = config.LayoutNode{Columns: cols}
+		}
+
+		windows = append(windows, config.WindowConfig{
+			Name:   winName,
+			Panes:  panes,
+			Layout: layoutNode,
+		})
+	}
+
+	session := config.SessionConfig{
+		Name:    sessionName,
+		Windows: windows,
+	}
+	captureTuning(run, sessionName, windowIDs, &session)
+	return &config.Config{Session: session}, nil
+}
+
+// tmuxRunner runs tmux commands against the server of a socket
+func tmuxRunner(socket string) func(args ...string) (string, error) {
+	return func(args ...string) (string, error) {
+		if socket != "" {
+			args = append([]string{"-L", socket}, args...)
+		}
+		var stderr bytes.Buffer
+		cmd := exec.Command("tmux", args...)
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			if msg := strings.TrimSpace(stderr.String()); msg != "" {
+				return string(out), fmt.Errorf("%v: %s", err, msg)
+			}
+			return string(out), err
+		}
+		return string(out), nil
+	}
+}
+
+// captureTuning records the typed options set on the session or all of its windows.
+// Unset options inherit tmux's defaults and are left out. escape-time is not captured, as
+// a server option it does not belong to the session.
+func captureTuning(run func(args ...string) (string, error), sessionName string, windowIDs []string, session *config.SessionConfig) {
+	if out, err := run("show-options", "-v", "-t", sessionName, "history-limit"); err == nil {
+		if limit, err := strconv.Atoi(strings.TrimSpace(out)); err == nil {
+			session.HistoryLimit = limit
+		}
+	}
+
+	var resize string
+	for i, windowID := range windowIDs {
+		out, err := run("show-options", "-wv", "-t", windowID, "aggressive-resize")
+		value := strings.TrimSpace(out)
+		if err != nil || value == "" || (i > 0 && value != resize) {
+			return
+		}
+		resize = value
+	}
+	if resize != "" {
+		aggressive := resize == "on"
+		session.AggressiveResize = &aggressive
+	}
+}
@@ -0,0 +1,80 @@
+package capture
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/esaiaswestberg/gridlock/pkg/config"
+)
+
+// fakeServer answers the tmux commands SessionWith runs for a session with one window of
+// two panes side by side
+func fakeServer(args ...string) (string, error) {
+	switch args[0] {
+	case "has-session":
+		if args[2] != "=dev" {
+			return "", errors.New("can't find session")
+		}
+		return "", nil
+	case "list-windows":
+		return "@1 5f1c,160x48,0,0{80x48,0,0,3,79x48,81,0,4} my editor\n", nil
+	case "list-panes":
+		return "%3  0 100 vim /src/app\n%4 copy-mode 12 101 npm /src/app/web ui\n", nil
+	case "show-options":
+		if args[len(args)-1] == "history-limit" {
+			return "5000\n", nil
+		}
+		return "on\n", nil
+	}
+	return "", nil
+}
+
+func TestSessionWith(t *testing.T) {
+	got, err := SessionWith("dev", Options{Run: fakeServer, Wrappers: []string{}, CopyMode: true})
+	if err != nil {
+		t.Fatalf("SessionWith failed: %v", err)
+	}
+	aggressive := true
+	want := config.SessionConfig{
+		Name:             "dev",
+		HistoryLimit:     5000,
+		AggressiveResize: &aggressive,
+		Windows: []config.WindowConfig{{
+			Name: "my editor",
+			Panes: []config.PaneConfig{
+				{Name: "my-editor-vim", WorkingDirectory: "/src/app", Command: "vim"},
+				{Name: "my-editor-npm", WorkingDirectory: "/src/app/web ui", Command: "npm", CopyMode: &config.CopyModeConfig{ScrollPosition: 12}},
+			},
+			Layout: config.LayoutNode{Columns: []config.LayoutNode{{PaneName: "my-editor-vim"}, {PaneName: "my-editor-npm"}}},
+		}},
+	}
+	if !reflect.DeepEqual(got.Session, want) {
+		t.Errorf("SessionWith() = %+v, want %+v", got.Session, want)
+	}
+}
+
+func TestSessionWithIndexNames(t *testing.T) {
+	got, err := SessionWith("dev", Options{Run: fakeServer, Wrappers: []string{}, PaneNames: PaneNamesIndex})
+	if err != nil {
+		t.Fatalf("SessionWith failed: %v", err)
+	}
+	var names []string
+	for _, pane := range got.Session.Windows[0].Panes {
+		names = append(names, pane.Name)
+		if pane.CopyMode != nil {
+			t.Errorf("pane %s has copy-mode recorded without CopyMode", pane.Name)
+		}
+	}
+	if want := []string{"my-editor-pane-0", "my-editor-pane-1"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("pane names = %q, want %q", names, want)
+	}
+}
+
+func TestSessionWithMissingSession(t *testing.T) {
+	_, err := SessionWith("de", Options{Run: fakeServer})
+	if err == nil || !strings.Contains(err.Error(), "session de not found") {
+		t.Errorf("SessionWith() error = %v, want session de not found", err)
+	}
+}
@@ -0,0 +1,157 @@
+package capture
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/esaiaswestberg/gridlock/pkg/config"
+)
+
+// ParseLayout turns a tmux window layout, as printed by list-windows -F '#{window_layout}',
+// into a layout node. Panes are named by paneNames, keyed by their pane ID without the %;
+// panes missing from it are named unknown-pane-<id>.
+func ParseLayout(layout string, paneNames map[int]string) (config.LayoutNode, error) {
+	// Format: checksum,WxH,X,Y{...} or ...[...] or ...,ID
+	// 1. Remove checksum if present (hex followed by comma) at start
+	if idx := strings.Index(layout, ","); idx != -1 {
+		// Check if prefix is hex checksum (approx check)
+		prefix := layout[:idx]
+		if matched, _ := regexp.MatchString(`^[0-9a-f]{4}$`, prefix); matched {
+			layout = layout[idx+1:]
+		}
+	}
+
+	// Regex to match WxH,X,Y
+	// We just need to find where the geometry ends.
+	// It ends at `{`, `[`, or `,`.
+	// Actually, leaf node format: WxH,X,Y,ID
+	// Container: WxH,X,Y{...} or WxH,X,Y[...]
+
+	re := regexp.MustCompile(`^\d+x\d+,\d+,\d+`)
+	loc := re.FindStringIndex(layout)
+	if loc == nil {
+		return config.LayoutNode{}, fmt.Errorf("invalid layout format: %s", layout)
+	}
+
+	rest := layout[loc[1]:]
+	if len(rest) == 0 {
+		return config.LayoutNode{}, fmt.Errorf("unexpected end of layout string")
+	}
+
+	firstChar := rest[0]
+	content := rest[1:] // remove first char
+
+	if firstChar == ',' {
+		// Leaf node: ,ID
+		idStr := content
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			return config.LayoutNode{}, fmt.Errorf("invalid pane ID: %s", idStr)
+		}
+		name, ok := paneNames[id]
+		if !ok {
+			// Maybe pane is not in the list? (e.g. dead pane?)
+			// Or we parsed ID wrong.
+			return config.LayoutNode{PaneName: fmt.Sprintf("unknown-pane-%d", id)}, nil
+		}
+		return config.LayoutNode{PaneName: name}, nil
+	} else if firstChar == '{' {
+		// Horizontal split (Columns)
+		// Remove trailing }
+		if content == "" || content[len(content)-1] != '}' {
+			return config.LayoutNode{}, fmt.Errorf("mismatched braces in layout")
+		}
+		content = content[:len(content)-1]
+		childrenStr := splitLayoutChildren(content)
+		var columns []config.LayoutNode
+		for _, child := range childrenStr {
+			node, err := ParseLayout(child, paneNames)
+			if err != nil {
+				return config.LayoutNode{}, err
+			}
+			columns = append(columns, node)
+		}
+		return config.LayoutNode{Columns: columns}, nil
+
+	} else if firstChar == '[' {
+		// Vertical split (Rows)
+		// Remove trailing ]
+		if content == "" || content[len(content)-1] != ']' {
+			return config.LayoutNode{}, fmt.Errorf("mismatched brackets in layout")
+		}
+		content = content[:len(content)-1]
+		childrenStr := splitLayoutChildren(content)
+		var rows []config.LayoutNode
+		for _, child := range childrenStr {
+			node, err := ParseLayout(child, paneNames)
+			if err != nil {
+				return config.LayoutNode{}, err
+			}
+			rows = append(rows, node)
+		}
+		return config.LayoutNode{Rows: rows}, nil
+	}
+
+	return config.LayoutNode{}, fmt.Errorf("unexpected character after geometry: %c", firstChar)
+}
+
+func splitLayoutChildren(s string) []string {
+	var children []string
+	re := regexp.MustCompile(`^\d+x\d+,\d+,\d+`)
+
+	for len(s) > 0 {
+		// Find end of current node
+		// A node starts with WxH,X,Y
+		loc := re.FindStringIndex(s)
+		if loc == nil {
+			// Should not happen if valid layout
+			break
+		}
+
+		cursor := loc[1]
+		if cursor >= len(s) {
+			children = append(children, s)
+			break
+		}
+
+		char := s[cursor]
+		if char == ',' {
+			// Leaf: ,ID
+			cursor++
+			// Consume digits
+			for cursor < len(s) && s[cursor] >= '0' && s[cursor] <= '9' {
+				cursor++
+			}
+		} else if char == '{' || char == '[' {
+			// Container
+			openChar := char
+			closeChar := '}'
+			if openChar == '[' {
+				closeChar = ']'
+			}
+			cursor++
+			depth := 1
+			for cursor < len(s) && depth > 0 {
+				if s[cursor] == openChar {
+					depth++
+				}
+				if s[cursor] == byte(closeChar) {
+					depth--
+				}
+				cursor++
+			}
+		}
+
+		// Now cursor is at end of node
+		children = append(children, s[:cursor])
+
+		// If there is a comma separator, skip it for the next iteration
+		if cursor < len(s) && s[cursor] == ',' {
+			cursor++
+		}
+		s = s[cursor:]
+	}
+	return children
+}
@@ -0,0 +1,100 @@
+package capture
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/esaiaswestberg/gridlock/pkg/config"
+)
+
+func TestParseLayout(t *testing.T) {
+	names := map[int]string{0: "editor", 1: "server", 2: "logs", 3: "tests", 4: "shell"}
+	pane := func(name string) config.LayoutNode { return config.LayoutNode{PaneName: name} }
+
+	tests := []struct {
+		name   string
+		layout string
+		want   config.LayoutNode
+	}{
+		{
+			name:   "single pane",
+			layout: "b25d,80x24,0,0,0",
+			want:   pane("editor"),
+		},
+		{
+			name:   "single pane without checksum",
+			layout: "80x24,0,0,0",
+			want:   pane("editor"),
+		},
+		{
+			name:   "columns",
+			layout: "5f1c,160x48,0,0{80x48,0,0,0,79x48,81,0,1}",
+			want:   config.LayoutNode{Columns: []config.LayoutNode{pane("editor"), pane("server")}},
+		},
+		{
+			name:   "rows",
+			layout: "9a1f,80x49,0,0[80x24,0,0,0,80x12,0,25,1,80x11,0,38,2]",
+			want:   config.LayoutNode{Rows: []config.LayoutNode{pane("editor"), pane("server"), pane("logs")}},
+		},
+		{
+			name:   "rows nested in columns",
+			layout: "c1a3,200x50,0,0{100x50,0,0,0,99x50,101,0[99x25,101,0,1,99x24,101,26,2]}",
+			want: config.LayoutNode{Columns: []config.LayoutNode{
+				pane("editor"),
+				{Rows: []config.LayoutNode{pane("server"), pane("logs")}},
+			}},
+		},
+		{
+			name:   "columns nested in rows nested in columns",
+			layout: "c1a3,200x50,0,0{100x50,0,0,0,99x50,101,0[99x25,101,0,1,99x24,101,26{49x24,101,26,3,49x24,151,26,4}]}",
+			want: config.LayoutNode{Columns: []config.LayoutNode{
+				pane("editor"),
+				{Rows: []config.LayoutNode{
+					pane("server"),
+					{Columns: []config.LayoutNode{pane("tests"), pane("shell")}},
+				}},
+			}},
+		},
+		{
+			name:   "two nested containers side by side",
+			layout: "0d5e,161x40,0,0{80x40,0,0[80x20,0,0,0,80x19,0,21,1],80x40,81,0[80x20,81,0,2,80x19,81,21,3]}",
+			want: config.LayoutNode{Columns: []config.LayoutNode{
+				{Rows: []config.LayoutNode{pane("editor"), pane("server")}},
+				{Rows: []config.LayoutNode{pane("logs"), pane("tests")}},
+			}},
+		},
+		{
+			name:   "multi-digit pane IDs missing from the names",
+			layout: "5f1c,160x48,0,0{80x48,0,0,12,79x48,81,0,345}",
+			want:   config.LayoutNode{Columns: []config.LayoutNode{pane("unknown-pane-12"), pane("unknown-pane-345")}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseLayout(tt.layout, names)
+			if err != nil {
+				t.Fatalf("ParseLayout(%q) failed: %v", tt.layout, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseLayout(%q) = %+v, want %+v", tt.layout, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseLayoutInvalid(t *testing.T) {
+	for _, layout := range []string{
+		"",
+		"b25d",
+		"b25d,80x24,0,0",
+		"b25d,80x24,0,0,x",
+		"b25d,80x24,0,0{",
+		"b25d,80x24,0,0{80x24,0,0,0",
+		"b25d,80x24,0,0[80x24,0,0,0}",
+		"b25d,80x24,0,0|80x24,0,0,0",
+	} {
+		if got, err := ParseLayout(layout, nil); err == nil {
+			t.Errorf("ParseLayout(%q) = %+v, want an error", layout, got)
+		}
+	}
+}
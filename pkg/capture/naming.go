@@ -0,0 +1,90 @@
+package capture
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Naming strategies for captured panes
+const (
+	// <window>-<command>, or <window>-<directory> for panes running a shell
+	PaneNamesCommand = "command"
+	// <window>-pane-<index>
+	PaneNamesIndex = "index"
+)
+
+// Programs that only indicate an idle pane, so the directory says more about it
+var shellCommands = map[string]bool{
+	"bash": true,
+	"zsh":  true,
+	"fish": true,
+	"sh":   true,
+	"dash": true,
+	"ksh":  true,
+	"nu":   true,
+}
+
+// slugify lowercases text and replaces everything but letters and digits with single dashes,
+// so that names are safe to use in tmux targets and YAML
+func slugify(text string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(text) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "-")
+}
+
+// paneNamer generates pane names that are unique across all windows of a captured session
+type paneNamer struct {
+	strategy string
+	used     map[string]bool
+}
+
+func newPaneNamer(strategy string) (*paneNamer, error) {
+	switch strategy {
+	case "":
+		strategy = PaneNamesCommand
+	case PaneNamesCommand, PaneNamesIndex:
+	default:
+		return nil, fmt.Errorf("unknown pane naming strategy %q, expected %s or %s", strategy, PaneNamesCommand, PaneNamesIndex)
+	}
+	return &paneNamer{strategy: strategy, used: map[string]bool{}}, nil
+}
+
+// name returns the name of the index-th pane of a window running command in dir
+func (n *paneNamer) name(windowName string, index int, command string, dir string) string {
+	window := slugify(windowName)
+	if window == "" {
+		window = "window"
+	}
+
+	var base string
+	switch n.strategy {
+	case PaneNamesIndex:
+		base = fmt.Sprintf("%s-pane-%d", window, index)
+	default:
+		suffix := slugify(command)
+		if shellCommands[command] || suffix == "" {
+			suffix = slugify(filepath.Base(dir))
+		}
+		if suffix == "" || suffix == window {
+			suffix = "pane"
+		}
+		base = window + "-" + suffix
+	}
+
+	name := base
+	for i := 2; n.used[name]; i++ {
+		name = fmt.Sprintf("%s-%d", base, i)
+	}
+	n.used[name] = true
+	return name
+}
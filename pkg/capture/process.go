@@ -0,0 +1,88 @@
+package capture
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// process is a line of ps output
+type process struct {
+	pid, ppid, pgid int
+	args            string
+}
+
+// processes lists the processes of the system
+func processes() (map[int]process, error) {
+	out, err := exec.Command("ps", "-A", "-o", "pid=,ppid=,pgid=,args=").Output()
+	if err != nil {
+		return nil, err
+	}
+	procs := make(map[int]process)
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 {
+			continue
+		}
+		p := process{args: strings.Join(fields[3:], " ")}
+		if _, err := fmt.Sscan(line, &p.pid, &p.ppid, &p.pgid); err != nil {
+			continue
+		}
+		procs[p.pid] = p
+	}
+	return procs, nil
+}
+
+// fullCommand returns the command line of the job in the foreground of the terminal of the
+// pane whose process has the given pid, joining the commands of a pipeline with |. It
+// returns "" for a shell waiting at its prompt and when the job cannot be determined.
+// Arguments are joined with spaces, as ps reports them without their original quoting.
+func fullCommand(pid string) string {
+	out, err := exec.Command("ps", "-o", "tpgid=", "-p", pid).Output()
+	if err != nil {
+		return ""
+	}
+	foreground, err := strconv.Atoi(strings.TrimSpace(string(out)))
+	if err != nil || foreground <= 0 {
+		return ""
+	}
+	procs, err := processes()
+	if err != nil {
+		return ""
+	}
+	leader, ok := procs[foreground]
+	if !ok {
+		return ""
+	}
+
+	// The commands of a pipeline share the leader's group and parent. Their own children,
+	// e.g. the node process of npm run dev, share the group but not the parent.
+	var job []process
+	for _, p := range procs {
+		if p.pgid == leader.pgid && p.ppid == leader.ppid {
+			job = append(job, p)
+		}
+	}
+	sort.Slice(job, func(i, j int) bool { return job[i].pid < job[j].pid })
+	var commands []string
+	for _, p := range job {
+		commands = append(commands, p.args)
+	}
+	if len(job) == 1 && shellCommands[programName(job[0].args)] {
+		return ""
+	}
+	return strings.Join(commands, " | ")
+}
+
+// programName returns the name of the program of a command line. Login shells are reported
+// as -zsh.
+func programName(args string) string {
+	fields := strings.Fields(args)
+	if len(fields) == 0 {
+		return ""
+	}
+	return strings.TrimPrefix(filepath.Base(fields[0]), "-")
+}
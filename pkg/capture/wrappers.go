@@ -0,0 +1,35 @@
+package capture
+
+import (
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultWrappers are processes that merely wrap the program a pane runs, e.g. from a
+// default-command
+var DefaultWrappers = []string{"reattach-to-user-namespace"}
+
+// unwrapCommand returns the program a wrapper process with the given pid runs. It follows
+// nested wrappers and falls back to command when the child cannot be determined.
+func unwrapCommand(pid string, command string, wrappers map[string]bool) string {
+	for wrappers[command] {
+		out, err := exec.Command("pgrep", "-P", pid).Output()
+		if err != nil {
+			return command
+		}
+		children := strings.Fields(string(out))
+		if len(children) == 0 {
+			return command
+		}
+		pid = children[0]
+		out, err = exec.Command("ps", "-o", "comm=", "-p", pid).Output()
+		if err != nil {
+			return command
+		}
+		command = filepath.Base(strings.TrimSpace(string(out)))
+		// Login shells are reported as -zsh
+		command = strings.TrimPrefix(command, "-")
+	}
+	return command
+}
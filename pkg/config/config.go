@@ -0,0 +1,351 @@
+// Package config defines the gridlock configuration file format. The types decode from and
+// encode to the YAML of a .gridlock.yaml file with gopkg.in/yaml.v3.
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config is a gridlock configuration file
+type Config struct {
+	Session SessionConfig `yaml:"session,omitempty"`
+	// Projects turns the configuration into a workspace referencing other project configurations
+	Projects []string `yaml:"projects,omitempty"`
+	// Components are reusable panes and windows, see Component
+	Components     map[string]Component `yaml:"components,omitempty"`
+	ComponentFiles []string             `yaml:"components-from,omitempty"`
+	// Vars are expanded as ${NAME} in the string values of the configuration
+	Vars map[string]string `yaml:"vars,omitempty"`
+}
+
+// Session, Window and Pane are the names the configuration types go by outside gridlock
+type (
+	Session = SessionConfig
+	Window  = WindowConfig
+	Pane    = PaneConfig
+)
+
+type SessionConfig struct {
+	Name             string            `yaml:"name"`
+	WorkingDirectory string            `yaml:"working-directory,omitempty"`
+	Root             string            `yaml:"root,omitempty"`
+	Locked           bool              `yaml:"locked,omitempty"`
+	DetachOthers     bool              `yaml:"detach-others,omitempty"`
+	SmallWidth       int               `yaml:"small-width,omitempty"`
+	SmallHeight      int               `yaml:"small-height,omitempty"`
+	MinPaneWidth     int               `yaml:"min-pane-width,omitempty"`
+	MinPaneHeight    int               `yaml:"min-pane-height,omitempty"`
+	StrictSizes      bool              `yaml:"strict-sizes,omitempty"`
+	PaneDefaults     PaneDefaults      `yaml:"pane-defaults,omitempty"`
+	Clipboard        string            `yaml:"clipboard,omitempty"`
+	HistoryLimit     int               `yaml:"history-limit,omitempty"`
+	AggressiveResize *bool             `yaml:"aggressive-resize,omitempty"`
+	EscapeTime       *int              `yaml:"escape-time,omitempty"`
+	PaneBaseIndex    *int              `yaml:"pane-base-index,omitempty"`
+	ScriptThreshold  int               `yaml:"script-threshold,omitempty"`
+	SelectLastPane   bool              `yaml:"select-last-pane,omitempty"`
+	RestoreFocus     bool              `yaml:"restore-focus,omitempty"`
+	CollapseAfter    string            `yaml:"collapse-after,omitempty"`
+	Socket           string            `yaml:"socket,omitempty"`
+	Backend          string            `yaml:"backend,omitempty"`
+	ToolManager      string            `yaml:"tool-manager,omitempty"`
+	Stats            bool              `yaml:"stats,omitempty"`
+	Transform        string            `yaml:"transform,omitempty"`
+	Title            string            `yaml:"title,omitempty"`
+	Menus            []MenuConfig      `yaml:"menus,omitempty"`
+	Prefix           string            `yaml:"prefix,omitempty"`
+	KeyTable         string            `yaml:"key-table,omitempty"`
+	Keys             []KeyBinding      `yaml:"keys,omitempty"`
+	Scratchpad       *ScratchpadConfig `yaml:"scratchpad,omitempty"`
+	TMUXConfig       string            `yaml:"tmux-config,omitempty"`
+	Env              map[string]string `yaml:"env,omitempty"`
+	EnvScope         map[string]string `yaml:"env-scope,omitempty"`
+	Options          map[string]string `yaml:"options,omitempty"`
+	Hooks            Hooks             `yaml:"hooks,omitempty"`
+	Windows          []WindowConfig    `yaml:"windows,omitempty"`
+}
+
+type WindowConfig struct {
+	Name             string            `yaml:"name"`
+	WorkingDirectory string            `yaml:"working-directory,omitempty"`
+	KeepOpen         bool              `yaml:"keep-open,omitempty"`
+	Ephemeral        bool              `yaml:"ephemeral,omitempty"`
+	Focus            bool              `yaml:"focus,omitempty"`
+	Locked           bool              `yaml:"locked,omitempty"`
+	PaneDefaults     PaneDefaults      `yaml:"pane-defaults,omitempty"`
+	Env              map[string]string `yaml:"env,omitempty"`
+	Tools            map[string]string `yaml:"tools,omitempty"`
+	Options          map[string]string `yaml:"options,omitempty"`
+	Panes            []PaneConfig      `yaml:"panes,omitempty"`
+	PanesFromCommand string            `yaml:"panes-from-command,omitempty"`
+	Grid             string            `yaml:"grid,omitempty"`
+	Layout           LayoutNode        `yaml:"layout,omitempty"`
+	LayoutSmall      LayoutNode        `yaml:"layout-small,omitempty"`
+	MainPaneSize     string            `yaml:"main-pane-size,omitempty"`
+	Transform        string            `yaml:"transform,omitempty"`
+	Hooks            Hooks             `yaml:"hooks,omitempty"`
+	Use              string            `yaml:"use,omitempty"`
+	With             map[string]string `yaml:"with,omitempty"`
+}
+
+type PaneConfig struct {
+	Name                string            `yaml:"name"`
+	Kind                string            `yaml:"kind,omitempty"`
+	WorkingDirectory    string            `yaml:"working-directory,omitempty"`
+	WorkingDirectoryCmd string            `yaml:"working-directory-cmd,omitempty"`
+	Command             string            `yaml:"command,omitempty"`
+	Commands            Commands          `yaml:"commands,omitempty"`
+	Send                []SendKeys        `yaml:"send,omitempty"`
+	Schedule            []ScheduleEntry   `yaml:"schedule,omitempty"`
+	Shell               string            `yaml:"shell,omitempty"`
+	Env                 map[string]string `yaml:"env,omitempty"`
+	Tools               map[string]string `yaml:"tools,omitempty"`
+	Style               string            `yaml:"style,omitempty"`
+	Title               string            `yaml:"title,omitempty"`
+	OSC                 []string          `yaml:"osc,omitempty"`
+	KeepOpen            *bool             `yaml:"keep-open,omitempty"`
+	OnExit              string            `yaml:"on-exit,omitempty"`
+	AsScript            *bool             `yaml:"as-script,omitempty"`
+	Log                 *bool             `yaml:"log,omitempty"`
+	CopyMode            *CopyModeConfig   `yaml:"copy-mode,omitempty"`
+	AdoptPID            int               `yaml:"adopt-pid,omitempty"`
+	WaitFor             string            `yaml:"wait-for,omitempty"`
+	Verify              string            `yaml:"verify,omitempty"`
+	Collapsible         bool              `yaml:"collapsible,omitempty"`
+	Focus               bool              `yaml:"focus,omitempty"`
+	Tags                []string          `yaml:"tags,omitempty"`
+	Use                 string            `yaml:"use,omitempty"`
+	With                map[string]string `yaml:"with,omitempty"`
+	Locked              bool              `yaml:"locked,omitempty"`
+}
+
+// Commands are sent to a pane one by one. In YAML they are either a list or a block scalar
+// with one command per line, in which blank lines and lines starting with # are skipped.
+// Entries of a list are commands, or CommandEntry mappings with options.
+type Commands []CommandEntry
+
+func (c *Commands) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind != yaml.ScalarNode {
+		var list []CommandEntry
+		if err := value.Decode(&list); err != nil {
+			return err
+		}
+		*c = list
+		return nil
+	}
+	var entries []CommandEntry
+	for _, line := range strings.Split(value.Value, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		entries = append(entries, CommandEntry{Run: line})
+	}
+	*c = entries
+	return nil
+}
+
+// Strings returns the command lines of the entries, see CommandEntry.Line
+func (c Commands) Strings() []string {
+	lines := make([]string, len(c))
+	for i, entry := range c {
+		lines[i] = entry.Line()
+	}
+	return lines
+}
+
+// CommandEntry is a command typed into a pane. In YAML it is the command itself, or a
+// mapping with the command as run and options for typing it:
+//
+//   - enter: whether Enter is pressed after the command, true unless set
+//   - delay: how long to wait before typing it, a duration such as 500ms
+//   - literal: type the command with send-keys -l, so words such as Enter or Space are
+//     not taken for tmux key names. It is the opposite of key-names in SendKeys.
+//   - retry: how many more times the command runs when it fails
+type CommandEntry struct {
+	Run     string `yaml:"run"`
+	Enter   *bool  `yaml:"enter,omitempty"`
+	Delay   string `yaml:"delay,omitempty"`
+	Literal bool   `yaml:"literal,omitempty"`
+	Retry   int    `yaml:"retry,omitempty"`
+}
+
+func (e *CommandEntry) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*e = CommandEntry{}
+		return value.Decode(&e.Run)
+	}
+	type plain CommandEntry
+	return value.Decode((*plain)(e))
+}
+
+func (e CommandEntry) MarshalYAML() (interface{}, error) {
+	if e == (CommandEntry{Run: e.Run}) {
+		return e.Run, nil
+	}
+	type plain CommandEntry
+	return plain(e), nil
+}
+
+// Line returns the command line typed into the pane: the command, run again up to Retry
+// times for as long as it fails. With retries every copy is grouped in braces, so a list
+// such as "cd api; make" or "a && b" is retried as a whole.
+func (e CommandEntry) Line() string {
+	if e.Retry <= 0 {
+		return e.Run
+	}
+	group := "{ " + strings.TrimRight(strings.TrimSpace(e.Run), "; ") + "; }"
+	line := group
+	for i := 0; i < e.Retry; i++ {
+		line += " || " + group
+	}
+	return line
+}
+
+// SendKeys are typed into a pane after its commands. Keys are typed as text, with send-keys
+// -l as a literal CommandEntry is, and followed by Enter unless Enter is false; with KeyNames
+// they are tmux key names sent as they are, e.g. C-c or Escape, and Enter is off unless set.
+type SendKeys struct {
+	Keys     string `yaml:"keys"`
+	Enter    *bool  `yaml:"enter,omitempty"`
+	KeyNames bool   `yaml:"key-names,omitempty"`
+}
+
+// ScheduleEntry types Command into a pane whenever Cron, a cron expression such as
+// "*/15 * * * *" or "@every 15m", is due
+type ScheduleEntry struct {
+	Cron    string `yaml:"cron"`
+	Command string `yaml:"command"`
+}
+
+// CopyModeConfig puts a pane into copy-mode after its commands, scrolled back by ScrollPosition lines
+type CopyModeConfig struct {
+	ScrollPosition int `yaml:"scroll-position,omitempty"`
+}
+
+// PaneDefaults are inherited by every pane of a session or window unless the pane overrides them
+type PaneDefaults struct {
+	WorkingDirectory string            `yaml:"working-directory,omitempty"`
+	Shell            string            `yaml:"shell,omitempty"`
+	Env              map[string]string `yaml:"env,omitempty"`
+	Style            string            `yaml:"style,omitempty"`
+	KeepOpen         *bool             `yaml:"keep-open,omitempty"`
+	AsScript         *bool             `yaml:"as-script,omitempty"`
+	Log              *bool             `yaml:"log,omitempty"`
+}
+
+type LayoutNode struct {
+	PaneName string       `yaml:"pane,omitempty"`
+	Columns  []LayoutNode `yaml:"columns,omitempty"`
+	Rows     []LayoutNode `yaml:"rows,omitempty"`
+	// Size of the node within its parent, a percentage such as 30% or a number of cells such as 20. Nodes without one share the rest equally.
+	Size string `yaml:"size,omitempty"`
+	// Preset is one of tmux's built-in layouts, such as tiled, arranging the panes of the
+	// window in place of a tree
+	Preset string `yaml:"preset,omitempty"`
+}
+
+func (n LayoutNode) IsZero() bool {
+	return n.PaneName == "" && len(n.Columns) == 0 && len(n.Rows) == 0 && n.Preset == ""
+}
+
+func (n *LayoutNode) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		return value.Decode(&n.PaneName)
+	}
+	// A mapping holds columns or rows, or a pane with its size
+	type plain LayoutNode
+	return value.Decode((*plain)(n))
+}
+
+func (n LayoutNode) MarshalYAML() (interface{}, error) {
+	if n.PaneName != "" && n.Size == "" {
+		return n.PaneName, nil
+	}
+	m := make(map[string]interface{})
+	if n.PaneName != "" {
+		m["pane"] = n.PaneName
+	}
+	if len(n.Columns) > 0 {
+		m["columns"] = n.Columns
+	}
+	if len(n.Rows) > 0 {
+		m["rows"] = n.Rows
+	}
+	if n.Size != "" {
+		m["size"] = n.Size
+	}
+	if n.Preset != "" {
+		m["preset"] = n.Preset
+	}
+	return m, nil
+}
+
+// ParseSize parses the size of a layout node: a percentage of its parent ("70%") or a
+// number of cells ("80"), columns for a column and lines for a row
+func ParseSize(size string) (value int, percent bool, err error) {
+	percent = strings.HasSuffix(size, "%")
+	value, err = strconv.Atoi(strings.TrimSpace(strings.TrimSuffix(size, "%")))
+	if err != nil || value <= 0 || (percent && value > 100) {
+		return 0, false, fmt.Errorf("invalid size %q, expected a percentage like 70%% or a number of cells", size)
+	}
+	return value, percent, nil
+}
+
+// MenuConfig is a tmux menu bound to a key of the prefix table
+type MenuConfig struct {
+	Title string     `yaml:"title,omitempty"`
+	Key   string     `yaml:"key"`
+	Items []MenuItem `yaml:"items"`
+}
+
+// MenuItem is an entry of a menu. Selecting it runs a tmux command, types a shell command
+// into the active pane or selects a window. An item without a name is a separator.
+type MenuItem struct {
+	Name    string `yaml:"name,omitempty"`
+	Key     string `yaml:"key,omitempty"`
+	Command string `yaml:"command,omitempty"`
+	Run     string `yaml:"run,omitempty"`
+	Window  string `yaml:"window,omitempty"`
+}
+
+// KeyBinding binds a key of a tmux key table to a tmux command. The table defaults to
+// prefix, the keys pressed after the prefix key.
+type KeyBinding struct {
+	Key     string `yaml:"key"`
+	Table   string `yaml:"table,omitempty"`
+	Command string `yaml:"command"`
+}
+
+// ScratchpadConfig describes a hidden companion session that a key toggles into view
+type ScratchpadConfig struct {
+	Key              string `yaml:"key"`
+	WorkingDirectory string `yaml:"working-directory,omitempty"`
+	Command          string `yaml:"command,omitempty"`
+	// Show the scratchpad in a popup (default) instead of switching the client to it
+	Popup  *bool  `yaml:"popup,omitempty"`
+	Width  string `yaml:"width,omitempty"`
+	Height string `yaml:"height,omitempty"`
+}
+
+// Component is a reusable pane or window definition. Its string values can refer to
+// parameters as {{name}}, which are filled in from the with: of the entry using it.
+type Component struct {
+	// Parameters and their defaults. A parameter without a default must be given.
+	Params map[string]string `yaml:"params,omitempty"`
+	Pane   yaml.Node         `yaml:"pane,omitempty"`
+	Window yaml.Node         `yaml:"window,omitempty"`
+}
+
+// Hooks are shell commands gridlock runs itself, on the machine it runs on, around creating,
+// attaching to and killing a session or window. They are not sent into panes.
+type Hooks struct {
+	Before   []string `yaml:"before,omitempty"`
+	After    []string `yaml:"after,omitempty"`
+	OnAttach []string `yaml:"on-attach,omitempty"`
+	OnKill   []string `yaml:"on-kill,omitempty"`
+}
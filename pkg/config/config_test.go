@@ -0,0 +1,44 @@
+package config
+
+import (
+	"os/exec"
+	"testing"
+)
+
+func TestCommandEntryLine(t *testing.T) {
+	tests := []struct {
+		entry CommandEntry
+		want  string
+	}{
+		{CommandEntry{Run: "make"}, "make"},
+		{CommandEntry{Run: "make", Retry: 2}, "{ make; } || { make; } || { make; }"},
+		{CommandEntry{Run: "cd api; make", Retry: 1}, "{ cd api; make; } || { cd api; make; }"},
+		{CommandEntry{Run: "a && b;", Retry: 1}, "{ a && b; } || { a && b; }"},
+	}
+	for _, tt := range tests {
+		if got := tt.entry.Line(); got != tt.want {
+			t.Errorf("Line() of %+v = %q, want %q", tt.entry, got, tt.want)
+		}
+	}
+}
+
+func TestCommandEntryLineRetriesAsAWhole(t *testing.T) {
+	// Every part of the command prints a letter, so the output shows which parts each try ran
+	tests := []struct {
+		run  string
+		want string
+	}{
+		// The list fails the first time and succeeds on its retry
+		{"printf a; printf b; test -e $0.done || { touch $0.done; false; }", "abab"},
+		{"printf a && printf b", "ab"},
+		{"printf a && false", "aaa"},
+	}
+	for _, tt := range tests {
+		dir := t.TempDir()
+		line := CommandEntry{Run: tt.run, Retry: 2}.Line()
+		out, _ := exec.Command("sh", "-c", line, dir+"/marker").Output()
+		if string(out) != tt.want {
+			t.Errorf("sh -c %q printed %q, want %q", line, out, tt.want)
+		}
+	}
+}
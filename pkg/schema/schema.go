@@ -0,0 +1,143 @@
+// Package schema defines the JSON documents printed by gridlock's read-only
+// subcommands when run with --json.
+//
+// Every document carries a schema_version. Fields may be added within a
+// version, but renaming, removing or changing the meaning of a field bumps
+// Version so that scripts and status-bar widgets can rely on the output.
+package schema
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// Version is the current version of all documents in this package
+const Version = 1
+
+// Status describes the live state of a configured session (gridlock status)
+type Status struct {
+	SchemaVersion int            `json:"schema_version"`
+	Session       string         `json:"session"`
+	ConfigFile    string         `json:"config_file"`
+	Running       bool           `json:"running"`
+	Clients       int            `json:"clients"`
+	Windows       []WindowStatus `json:"windows"`
+}
+
+// WindowStatus describes a window that is either configured, running, or both
+type WindowStatus struct {
+	Name        string `json:"name"`
+	InConfig    bool   `json:"in_config"`
+	Running     bool   `json:"running"`
+	Panes       int    `json:"panes"`
+	ConfigPanes int    `json:"config_panes"`
+}
+
+// Change kinds reported by Diff
+const (
+	ChangeMissingWindow = "missing-window"
+	ChangeExtraWindow   = "extra-window"
+	ChangePaneCount     = "pane-count"
+)
+
+// Diff lists the differences between a configuration and its live session (gridlock diff)
+type Diff struct {
+	SchemaVersion int      `json:"schema_version"`
+	Session       string   `json:"session"`
+	ConfigFile    string   `json:"config_file"`
+	Running       bool     `json:"running"`
+	Changes       []Change `json:"changes"`
+}
+
+// Change is a single difference between configuration and session
+type Change struct {
+	Kind     string `json:"kind"`
+	Window   string `json:"window"`
+	Expected int    `json:"expected,omitempty"`
+	Actual   int    `json:"actual,omitempty"`
+}
+
+// Projects lists the known project directories (gridlock projects)
+type Projects struct {
+	SchemaVersion int       `json:"schema_version"`
+	Projects      []Project `json:"projects"`
+}
+
+// Project is a directory containing a gridlock configuration
+type Project struct {
+	Path    string `json:"path"`
+	Session string `json:"session"`
+	Running bool   `json:"running"`
+}
+
+// Stats summarizes how often the windows and panes of a session were selected (gridlock stats)
+type Stats struct {
+	SchemaVersion int           `json:"schema_version"`
+	Session       string        `json:"session"`
+	Windows       []WindowUsage `json:"windows"`
+}
+
+// WindowUsage counts the selections of a window and its panes
+type WindowUsage struct {
+	Name       string      `json:"name"`
+	InConfig   bool        `json:"in_config"`
+	Selections int         `json:"selections"`
+	Panes      []PaneUsage `json:"panes"`
+}
+
+// PaneUsage counts the selections of a configured pane
+type PaneUsage struct {
+	Name       string `json:"name"`
+	Selections int    `json:"selections"`
+}
+
+// Sessions lists the sessions running on the tmux server (gridlock list)
+type Sessions struct {
+	SchemaVersion int              `json:"schema_version"`
+	Sessions      []RunningSession `json:"sessions"`
+}
+
+// RunningSession is a session on the tmux server. ConfigFile is set for sessions created by
+// gridlock, BaseSession for those created with --force-new and ScratchpadOf for scratchpads.
+type RunningSession struct {
+	Name         string `json:"name"`
+	Windows      int    `json:"windows"`
+	Attached     int    `json:"attached"`
+	Gridlock     bool   `json:"gridlock"`
+	ConfigFile   string `json:"config_file,omitempty"`
+	BaseSession  string `json:"base_session,omitempty"`
+	ScratchpadOf string `json:"scratchpad_of,omitempty"`
+}
+
+// Run results recorded in History
+const (
+	RunOK     = "ok"
+	RunFailed = "failed"
+)
+
+// History lists the recorded provisioning runs, most recent last (gridlock history)
+type History struct {
+	SchemaVersion int   `json:"schema_version"`
+	Runs          []Run `json:"runs"`
+}
+
+// Run is a single run of gridlock up. Action is what it did to the session: create,
+// recreate, add-windows (--current), apply or attach (the session was running already).
+type Run struct {
+	Time       time.Time `json:"time"`
+	Session    string    `json:"session"`
+	ConfigFile string    `json:"config_file"`
+	ConfigHash string    `json:"config_hash"`
+	Action     string    `json:"action"`
+	DurationMS int64     `json:"duration_ms"`
+	Result     string    `json:"result"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// Write encodes a document as indented JSON
+func Write(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
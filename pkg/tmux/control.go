@@ -0,0 +1,155 @@
+package tmux
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// controlClient is a tmux client in control mode (tmux -C) that runs the commands written to
+// its stdin one after another, saving a tmux process and server connection per command
+type controlClient struct {
+	mu    sync.Mutex
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	lines chan string
+	// Set once the client is disconnected
+	closed bool
+}
+
+// startControlClient attaches a control-mode client to a session. tmux ends control clients
+// that are not attached once their first command completes. The client neither receives the
+// output of panes nor counts towards the size of windows (tmux 3.2 or later). As it is a
+// client of its own, commands that look at the current client must not be sent to it.
+func startControlClient(args []string, sessionName string) (*controlClient, error) {
+	args = append(args, "-C", "attach-session", "-t", "="+sessionName, "-f", "no-output,ignore-size")
+	cmd := exec.Command("tmux", args...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	c := &controlClient{cmd: cmd, stdin: stdin, lines: make(chan string, 64)}
+	go func() {
+		scanner := bufio.NewScanner(stdout)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			c.lines <- scanner.Text()
+		}
+		close(c.lines)
+	}()
+	return c, nil
+}
+
+// controlLine returns the command line for tmux's parser. A lone ; separates commands, as it
+// does on the command line. ok is false for arguments that do not fit on one line.
+func controlLine(args []string) (line string, ok bool) {
+	words := make([]string, len(args))
+	for i, arg := range args {
+		if strings.ContainsAny(arg, "\r\n") {
+			return "", false
+		}
+		if arg == ";" {
+			words[i] = arg
+		} else {
+			words[i] = Quote(arg)
+		}
+	}
+	return strings.Join(words, " "), true
+}
+
+// run sends the command line of args and waits for its output, which tmux frames between
+// %begin and %end (or %error) lines. Notifications about other clients' activity in between
+// are skipped. ok is false when the client cannot take the command, e.g. as it died.
+func (c *controlClient) run(line string, args []string, timeout time.Duration) (out string, ok bool, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return "", false, nil
+	}
+	if _, err := io.WriteString(c.stdin, line+"\n"); err != nil {
+		return "", false, nil
+	}
+
+	deadline := time.After(timeout)
+	block := ""
+	var output []string
+	for {
+		select {
+		case l, open := <-c.lines:
+			if !open {
+				return "", false, nil
+			}
+			fields := strings.Fields(l)
+			if block == "" {
+				// Blocks flagged 0 answer commands the client did not send itself
+				if len(fields) == 4 && fields[0] == "%begin" && fields[3] == "1" {
+					block = fields[2]
+				}
+				continue
+			}
+			if len(fields) == 4 && (fields[0] == "%end" || fields[0] == "%error") && fields[2] == block {
+				out = strings.Join(output, "\n")
+				if out != "" {
+					out += "\n"
+				}
+				if fields[0] == "%error" {
+					return out, true, fmt.Errorf("tmux %s failed: %s", strings.Join(args, " "), strings.TrimSpace(out))
+				}
+				return out, true, nil
+			}
+			output = append(output, l)
+		case <-deadline:
+			// The answer may still arrive and would be taken for the next command's
+			c.close()
+			return "", true, fmt.Errorf("tmux %s timed out after %s", strings.Join(args, " "), timeout)
+		}
+	}
+}
+
+// close disconnects the client, which exits once its stdin is closed
+func (c *controlClient) close() {
+	if c.closed {
+		return
+	}
+	c.closed = true
+	c.stdin.Close()
+	go c.cmd.Wait()
+}
+
+// StartControl sends the following tmux commands through a control-mode client attached to
+// the session. Failing to start one is not an error, the commands then run as separate tmux
+// processes.
+func (c *Client) StartControl(sessionName string) {
+	if c.DryRun || c.Executor != nil || c.control != nil {
+		return
+	}
+	client, err := startControlClient(c.Args(), sessionName)
+	if err != nil {
+		if c.Verbose {
+			log.Printf("Not using control mode: %v", err)
+		}
+		return
+	}
+	c.control = client
+}
+
+// StopControl disconnects the control-mode client, so the following commands run in tmux
+// processes of their own again and see the client they were started from
+func (c *Client) StopControl() {
+	if c.control != nil {
+		c.control.close()
+		c.control = nil
+	}
+}
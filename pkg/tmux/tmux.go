@@ -0,0 +1,143 @@
+// Package tmux runs tmux commands for gridlock, with timeouts, retries of transient
+// failures, dry-run mode and an optional control-mode client.
+package tmux
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultTimeout is the timeout of a single tmux invocation when Client.Timeout is zero
+	DefaultTimeout = 10 * time.Second
+	// Commands slower than this are reported in verbose mode
+	slowCommand  = 500 * time.Millisecond
+	retryBackoff = 100 * time.Millisecond
+)
+
+// Output fragments of failures that are worth retrying, e.g. while the server is starting up
+var transientErrors = []string{
+	"Connection refused",
+	"Resource temporarily unavailable",
+	"server exited unexpectedly",
+	"lost server",
+}
+
+// Client runs tmux commands against a tmux server. The zero value runs them against the
+// default server.
+type Client struct {
+	// Print the commands instead of running them
+	DryRun bool
+	// Report retries and slow commands
+	Verbose bool
+	// Timeout for a single tmux invocation (defaults to DefaultTimeout)
+	Timeout time.Duration
+	// Number of retries for transient failures
+	Retries int
+	// Socket name of the tmux server (tmux -L), empty for the default server
+	Socket string
+	// Runs tmux invocations instead of the tmux binary, e.g. to record them in tests
+	Executor func(args []string) (string, error)
+	// Runs tmux invocations while set, see StartControl
+	control *controlClient
+}
+
+// Args prefixes tmux arguments with the server selection
+func (c *Client) Args(args ...string) []string {
+	if c.Socket == "" {
+		return args
+	}
+	return append([]string{"-L", c.Socket}, args...)
+}
+
+// Run runs a tmux command and returns its output, retrying transient failures with
+// exponential backoff
+func (c *Client) Run(ctx context.Context, args ...string) (string, error) {
+	if c.DryRun {
+		fmt.Printf("tmux %s\n", strings.Join(c.Args(args...), " "))
+		return "", nil
+	}
+	if c.Executor != nil {
+		return c.Executor(c.Args(args...))
+	}
+
+	var out string
+	var err error
+	for attempt := 0; ; attempt++ {
+		var transient bool
+		out, transient, err = c.exec(ctx, args)
+		if err == nil || !transient || attempt >= c.Retries {
+			return out, err
+		}
+		backoff := retryBackoff << attempt
+		if c.Verbose {
+			log.Printf("Retrying in %s: %v", backoff, err)
+		}
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return out, ctx.Err()
+		}
+	}
+}
+
+// exec runs a single tmux invocation and reports whether a failure looks transient
+func (c *Client) exec(ctx context.Context, args []string) (string, bool, error) {
+	timeout := c.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	if c.control != nil {
+		// Commands that do not fit on a line of control mode run as a process
+		if line, fits := controlLine(args); fits {
+			out, ok, err := c.control.run(line, args, timeout)
+			if ok {
+				return out, false, err
+			}
+			if c.Verbose {
+				log.Printf("Control mode client is gone, running tmux commands as processes")
+			}
+			c.StopControl()
+		}
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	cmd := exec.CommandContext(ctx, "tmux", c.Args(args...)...)
+	outBytes, err := cmd.CombinedOutput()
+	out := string(outBytes)
+	if elapsed := time.Since(start); c.Verbose && elapsed > slowCommand {
+		log.Printf("Slow tmux command (%s): tmux %s", elapsed.Round(time.Millisecond), strings.Join(args, " "))
+	}
+
+	// A command that timed out may have run already, and running it again could split a
+	// pane or type a command twice, so it is not retried
+	if ctx.Err() == context.DeadlineExceeded {
+		return out, false, fmt.Errorf("tmux %s timed out after %s", strings.Join(args, " "), timeout)
+	}
+	if err != nil {
+		return out, IsTransient(out), fmt.Errorf("tmux %s failed: %v\nOutput: %s", strings.Join(args, " "), err, out)
+	}
+	return out, false, nil
+}
+
+// IsTransient reports whether a tmux failure with the given output is worth retrying,
+// e.g. because the server is still starting up
+func IsTransient(output string) bool {
+	for _, fragment := range transientErrors {
+		if strings.Contains(output, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+// Quote quotes an argument for a command string that tmux parses itself
+func Quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`).Replace(s) + `"`
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Error yaml.v3 reports for keys that no field decodes
+var unknownFieldError = regexp.MustCompile(`^(line \d+): field (.+) not found in type (\S+)$`)
+
+// configTypeName names a configuration type for error messages, e.g. config.PaneConfig as pane
+func configTypeName(typeName string) string {
+	name := strings.TrimSuffix(strings.TrimPrefix(typeName, "config."), "Config")
+	if name == "" {
+		return "the top level"
+	}
+	return strings.ToLower(name)
+}
+
+// preflightConfig checks a configuration file for mistakes that would otherwise surface as
+// tmux failures halfway through building the session: unknown keys, a missing session name,
+// duplicate window or pane names and layouts referencing panes that do not exist. All of
+// them are reported at once, with the line they are on.
+func preflightConfig(data []byte) error {
+	var problems []string
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	var config Config
+	if err := decoder.Decode(&config); err != nil {
+		var typeErr *yaml.TypeError
+		if !errors.As(err, &typeErr) {
+			// Empty files are left to the checks of the loaded configuration
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		for _, message := range typeErr.Errors {
+			if m := unknownFieldError.FindStringSubmatch(message); m != nil {
+				message = fmt.Sprintf("%s: unknown key %s in %s", m[1], m[2], configTypeName(m[3]))
+			}
+			problems = append(problems, message)
+		}
+	}
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return err
+	}
+	if len(root.Content) > 0 && root.Content[0].Kind == yaml.MappingNode {
+		problems = append(problems, preflightSession(root.Content[0])...)
+	}
+	sort.SliceStable(problems, func(i, j int) bool {
+		return problemLine(problems[i]) < problemLine(problems[j])
+	})
+	if len(problems) > 0 {
+		return fmt.Errorf("%s", strings.Join(problems, "\n"))
+	}
+	return nil
+}
+
+// preflightSession checks the session of a configuration's top-level mapping. Workspaces
+// have no session of their own.
+func preflightSession(top *yaml.Node) []string {
+	session := mappingValue(top, "session")
+	if session == nil || session.Kind != yaml.MappingNode {
+		if mappingValue(top, "projects") != nil {
+			return nil
+		}
+		return []string{fmt.Sprintf("line %d: the configuration has no session", top.Line)}
+	}
+	var problems []string
+	if name := mappingValue(session, "name"); name == nil || name.Value == "" {
+		problems = append(problems, fmt.Sprintf("line %d: session has no name", session.Line))
+	}
+
+	windows := mappingValue(session, "windows")
+	if windows == nil || windows.Kind != yaml.SequenceNode {
+		return problems
+	}
+	windowLines := make(map[string]int)
+	for _, window := range windows.Content {
+		if window.Kind != yaml.MappingNode {
+			continue
+		}
+		name := mappingValue(window, "name")
+		if name == nil || name.Value == "" {
+			// Windows instantiated from a component are named by it
+			if mappingValue(window, "use") == nil {
+				problems = append(problems, fmt.Sprintf("line %d: window has no name", window.Line))
+			}
+		} else if line, ok := windowLines[name.Value]; ok {
+			problems = append(problems, fmt.Sprintf("line %d: duplicate window %s, first defined on line %d", name.Line, name.Value, line))
+		} else {
+			windowLines[name.Value] = name.Line
+		}
+		problems = append(problems, preflightWindow(window, nameValue(name))...)
+	}
+	return problems
+}
+
+// preflightWindow checks the pane names of a window and the panes its layouts reference.
+// Panes from components or panes-from-command are only known once the window is expanded,
+// so their layouts are not checked.
+func preflightWindow(window *yaml.Node, windowName string) []string {
+	var problems []string
+	expanded := mappingValue(window, "use") != nil || mappingValue(window, "panes-from-command") != nil
+
+	var paneConfigs WindowConfig
+	paneLines := make(map[string]int)
+	if panes := mappingValue(window, "panes"); panes != nil && panes.Kind == yaml.SequenceNode {
+		for _, pane := range panes.Content {
+			if pane.Kind != yaml.MappingNode {
+				continue
+			}
+			if mappingValue(pane, "use") != nil {
+				expanded = true
+				continue
+			}
+			name := mappingValue(pane, "name")
+			if name == nil || name.Value == "" {
+				problems = append(problems, fmt.Sprintf("line %d: window %s: pane has no name", pane.Line, windowName))
+				continue
+			}
+			if line, ok := paneLines[name.Value]; ok {
+				problems = append(problems, fmt.Sprintf("line %d: window %s: duplicate pane %s, first defined on line %d", name.Line, windowName, name.Value, line))
+				continue
+			}
+			paneLines[name.Value] = name.Line
+			paneConfigs.Panes = append(paneConfigs.Panes, PaneConfig{Name: name.Value})
+		}
+	}
+	if expanded {
+		return problems
+	}
+
+	for _, key := range []string{"layout", "layout-small"} {
+		var check func(node *yaml.Node)
+		check = func(node *yaml.Node) {
+			switch node.Kind {
+			case yaml.ScalarNode:
+				// References to variables are only known once they are expanded
+				if node.Value != "" && !strings.Contains(node.Value, "${") && findPane(&paneConfigs, node.Value) == nil {
+					problems = append(problems, fmt.Sprintf("line %d: window %s: %s references unknown pane %s", node.Line, windowName, key, node.Value))
+				}
+			case yaml.MappingNode:
+				if pane := mappingValue(node, "pane"); pane != nil {
+					check(pane)
+				}
+				for _, children := range []*yaml.Node{mappingValue(node, "columns"), mappingValue(node, "rows")} {
+					if children != nil && children.Kind == yaml.SequenceNode {
+						for _, child := range children.Content {
+							check(child)
+						}
+					}
+				}
+			}
+		}
+		if layout := mappingValue(window, key); layout != nil {
+			// A single name may also be a layout preset, such as tiled
+			if _, ok := layoutPresets[layout.Value]; ok && layout.Kind == yaml.ScalarNode {
+				continue
+			}
+			check(layout)
+		}
+	}
+	return problems
+}
+
+// mappingValue returns the value of a key in a YAML mapping, or nil
+func mappingValue(mapping *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// problemLine returns the line a problem is reported on
+func problemLine(problem string) int {
+	var line int
+	fmt.Sscanf(problem, "line %d:", &line)
+	return line
+}
+
+func nameValue(node *yaml.Node) string {
+	if node == nil {
+		return "(unnamed)"
+	}
+	return node.Value
+}
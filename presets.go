@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/esaiaswestberg/gridlock/pkg/builder"
+	"github.com/esaiaswestberg/gridlock/pkg/config"
+)
+
+// tmux's built-in layouts, and the option sizing the main pane of those that have one
+var layoutPresets = builder.LayoutPresets
+
+// resolveLayoutPresets turns the layouts of a window that name a preset into a tree creating
+// every pane of the window, which the preset then arranges. A layout written as a single
+// name is a preset when no pane has that name.
+func resolveLayoutPresets(window *WindowConfig) error {
+	for _, layout := range []*LayoutNode{&window.Layout, &window.LayoutSmall} {
+		if _, ok := layoutPresets[layout.PaneName]; ok && findPaneConfig(window.Panes, layout.PaneName) == nil {
+			layout.Preset, layout.PaneName = layout.PaneName, ""
+		}
+		if layout.Preset == "" {
+			continue
+		}
+		if _, ok := layoutPresets[layout.Preset]; !ok {
+			return fmt.Errorf("unknown layout preset %q, expected even-horizontal, even-vertical, main-horizontal, main-vertical or tiled", layout.Preset)
+		}
+		if layout.PaneName != "" || len(layout.Columns) > 0 || len(layout.Rows) > 0 {
+			return fmt.Errorf("layout preset %s cannot have a pane, columns or rows", layout.Preset)
+		}
+		if len(window.Panes) == 0 {
+			return fmt.Errorf("layout preset %s has no panes to arrange", layout.Preset)
+		}
+		// The panes are split off in a grid, which has room for more of them than a row
+		tree, err := gridLayout(autoGrid(len(window.Panes)), window.Panes)
+		if err != nil {
+			return err
+		}
+		layout.PaneName, layout.Columns, layout.Rows = tree.PaneName, tree.Columns, tree.Rows
+	}
+	if window.MainPaneSize != "" {
+		if layoutPresets[window.Layout.Preset] == "" {
+			return fmt.Errorf("main-pane-size needs a main-horizontal or main-vertical layout")
+		}
+		if _, _, err := config.ParseSize(window.MainPaneSize); err != nil {
+			return fmt.Errorf("invalid main-pane-size: %v", err)
+		}
+	}
+	return nil
+}
+
+// applyLayoutPreset arranges the panes of a window created from a layout with a preset
+func (t *TMUX) applyLayoutPreset(windowTarget string, window *WindowConfig, layout LayoutNode) {
+	if option := layoutPresets[layout.Preset]; option != "" && window.MainPaneSize != "" {
+		t.run("set-option", "-w", "-t", windowTarget, option, window.MainPaneSize)
+	}
+	if _, err := t.run("select-layout", "-t", windowTarget, layout.Preset); err != nil {
+		log.Printf("Warning: window %s: failed to select layout %s: %v", window.Name, layout.Preset, err)
+	}
+}
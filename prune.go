@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+// confirm asks a yes/no question on stdin, defaulting to no
+func confirm(question string) bool {
+	fmt.Printf("%s [y/N] ", question)
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return false
+	}
+	answer := strings.ToLower(strings.TrimSpace(line))
+	return answer == "y" || answer == "yes"
+}
+
+// pruneWindowsCommand kills live windows of the session that are no longer in the configuration
+func pruneWindowsCommand(fs *flag.FlagSet) func(args []string, opts upOptions) {
+	yes := fs.Bool("yes", false, "Do not ask for confirmation")
+	return func(args []string, opts upOptions) {
+		config, err := loadConfig(opts.configFile)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		if err := prepareConfig(config); err != nil {
+			log.Fatalf("invalid config: %v", err)
+		}
+		sessionName := config.Session.Name
+
+		t := newTMUX(opts, config)
+		query := newTMUX(opts, config)
+		query.DryRun = false
+		if !query.sessionExists(sessionName) {
+			log.Fatalf("Session %s is not running", sessionName)
+		}
+		windows, err := query.liveWindows(sessionName)
+		if err != nil {
+			log.Fatalf("Failed to list windows: %v", err)
+		}
+
+		inConfig := make(map[string]bool)
+		for _, window := range config.Session.Windows {
+			inConfig[window.Name] = true
+		}
+		var extra []liveWindow
+		for _, w := range windows {
+			if !inConfig[w.configKey()] {
+				extra = append(extra, w)
+			}
+		}
+		if len(extra) == 0 {
+			fmt.Printf("No windows to prune in session %s\n", sessionName)
+			return
+		}
+
+		fmt.Printf("Windows in session %s that are not in %s:\n", sessionName, opts.configFile)
+		for _, w := range extra {
+			fmt.Printf("  %s (%d panes)\n", w.name, w.panes)
+		}
+		if !*yes && !opts.dryRun && !confirm(fmt.Sprintf("Kill %d window(s)?", len(extra))) {
+			fmt.Println("Aborted")
+			return
+		}
+
+		// Kill the window we are running in last so the rest of the run is not cut short
+		currentWindowID := ""
+		if os.Getenv("TMUX") != "" {
+			if out, err := query.run("display-message", "-p", "#{window_id}"); err == nil {
+				currentWindowID = strings.TrimSpace(out)
+			}
+		}
+		for _, w := range extra {
+			if w.id == currentWindowID {
+				continue
+			}
+			fmt.Printf("Killing window: %s\n", w.name)
+			if _, err := t.run("kill-window", "-t", w.id); err != nil {
+				log.Printf("Warning: failed to kill window %s: %v", w.name, err)
+			}
+		}
+		for _, w := range extra {
+			if w.id == currentWindowID {
+				fmt.Printf("Killing current window: %s\n", w.name)
+				t.run("kill-window", "-t", w.id)
+			}
+		}
+	}
+}
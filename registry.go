@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Maximum number of recently opened projects kept in the registry
+const maxRegistryEntries = 100
+
+// stateDir returns the directory where gridlock keeps local state
+func stateDir() (string, error) {
+	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+		return filepath.Join(dir, "gridlock"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "state", "gridlock"), nil
+}
+
+func registryPath() (string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "projects"), nil
+}
+
+// readRegistry returns the recently opened project directories, most recent first
+func readRegistry() []string {
+	path, err := registryPath()
+	if err != nil {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var dirs []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" {
+			dirs = append(dirs, line)
+		}
+	}
+	return dirs
+}
+
+// recordProject moves dir to the top of the registry
+func recordProject(dir string) error {
+	path, err := registryPath()
+	if err != nil {
+		return err
+	}
+	dirs := []string{dir}
+	for _, d := range readRegistry() {
+		if d != dir && len(dirs) < maxRegistryEntries {
+			dirs = append(dirs, d)
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(strings.Join(dirs, "\n")+"\n"), 0644)
+}
+
+// providerDirs runs the directory provider command (zoxide by default) and returns its output lines
+func providerDirs(provider string) []string {
+	if provider == "" {
+		if _, err := exec.LookPath("zoxide"); err != nil {
+			return nil
+		}
+		provider = "zoxide query --list"
+	}
+	out, err := exec.Command("sh", "-c", provider).Output()
+	if err != nil {
+		return nil
+	}
+	var dirs []string
+	for _, line := range strings.Split(string(out), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" {
+			dirs = append(dirs, line)
+		}
+	}
+	return dirs
+}
+
+// projectCandidates merges provider and registry directories that contain a gridlock configuration
+func projectCandidates(provider string) []string {
+	seen := make(map[string]bool)
+	var candidates []string
+	for _, dir := range append(providerDirs(provider), readRegistry()...) {
+		dir = filepath.Clean(expandPath(dir))
+		if seen[dir] {
+			continue
+		}
+		seen[dir] = true
+		if _, err := os.Stat(resolveConfigPath(filepath.Join(dir, ".gridlock.yaml"))); err == nil {
+			candidates = append(candidates, dir)
+		}
+	}
+	return candidates
+}
+
+// matchesQuery reports whether all keywords appear in order in the path, with the
+// last keyword in the final path component (the same rules zoxide uses)
+func matchesQuery(dir string, keywords []string) bool {
+	if len(keywords) == 0 {
+		return true
+	}
+	lower := strings.ToLower(dir)
+	pos := 0
+	for _, kw := range keywords {
+		idx := strings.Index(lower[pos:], strings.ToLower(kw))
+		if idx == -1 {
+			return false
+		}
+		pos += idx + len(kw)
+	}
+	last := strings.ToLower(keywords[len(keywords)-1])
+	return strings.Contains(strings.ToLower(filepath.Base(dir)), last)
+}
+
+// selectProject picks a project directory matching the keywords, prompting when there is no query
+func selectProject(keywords []string, provider string) (string, error) {
+	var matches []string
+	for _, dir := range projectCandidates(provider) {
+		if matchesQuery(dir, keywords) {
+			matches = append(matches, dir)
+		}
+	}
+	if len(matches) == 0 {
+		return "", fmt.Errorf("no projects found matching %q", strings.Join(keywords, " "))
+	}
+	if len(keywords) > 0 || len(matches) == 1 {
+		return matches[0], nil
+	}
+
+	for i, dir := range matches {
+		fmt.Fprintf(os.Stderr, "%3d) %s\n", i+1, dir)
+	}
+	fmt.Fprintf(os.Stderr, "Select project: ")
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return "", fmt.Errorf("no project selected")
+	}
+	choice, err := strconv.Atoi(strings.TrimSpace(line))
+	if err != nil || choice < 1 || choice > len(matches) {
+		return "", fmt.Errorf("invalid selection: %s", strings.TrimSpace(line))
+	}
+	return matches[choice-1], nil
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// strftime expands the date and time directives %Y, %m, %d, %H, %M and %S in a pattern
+func strftime(pattern string, t time.Time) string {
+	return strings.NewReplacer(
+		"%%", "%",
+		"%Y", t.Format("2006"),
+		"%m", t.Format("01"),
+		"%d", t.Format("02"),
+		"%H", t.Format("15"),
+		"%M", t.Format("04"),
+		"%S", t.Format("05"),
+	).Replace(pattern)
+}
+
+// renameExisting moves an existing session out of the way of a new one, renaming it to
+// <name>-<pattern> (numbered if that is taken) and returning the new name. The renamed
+// session records its original name like a session created with --force-new.
+func (t *TMUX) renameExisting(sessionName string, pattern string) (string, error) {
+	newName := t.getUniqueSessionName(sessionName + "-" + strftime(pattern, time.Now()))
+	// tmux does not allow these in session names
+	newName = strings.NewReplacer(":", "_", ".", "_").Replace(newName)
+	if _, err := t.run("rename-session", "-t", sessionName, newName); err != nil {
+		return "", fmt.Errorf("failed to rename session %s: %v", sessionName, err)
+	}
+	t.setSessionMetadata(newName, metadataBaseSession, sessionName)
+	return newName, nil
+}
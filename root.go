@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// applyRoot roots the working directories of the configuration at dir, so a configuration
+// kept apart from the project, e.g. in a central directory, can be used for any checkout of
+// it. The session starts in dir unless it sets a working-directory, relative directories are
+// resolved against dir and !git-root is the root of the repository dir is in.
+func applyRoot(config *Config, dir string) error {
+	root, err := filepath.Abs(expandPath(dir))
+	if err != nil {
+		return err
+	}
+	if info, err := os.Stat(root); err != nil || !info.IsDir() {
+		return fmt.Errorf("root %s is not a directory", dir)
+	}
+
+	gitRootDir := ""
+	var resolveErr error
+	resolve := func(path string) string {
+		switch {
+		case path == gitRootPrefix || strings.HasPrefix(path, gitRootPrefix+"/"):
+			if gitRootDir == "" {
+				out, err := exec.Command("git", "-C", root, "rev-parse", "--show-toplevel").Output()
+				if err != nil {
+					resolveErr = fmt.Errorf("cannot resolve %s: root %s is not inside a git repository", path, dir)
+					return path
+				}
+				gitRootDir = strings.TrimSpace(string(out))
+			}
+			return filepath.Join(gitRootDir, strings.TrimPrefix(path, gitRootPrefix))
+		case path == "" || path == "~" || strings.HasPrefix(path, "~/") || filepath.IsAbs(path):
+			return path
+		}
+		return filepath.Join(root, path)
+	}
+
+	session := &config.Session
+	session.WorkingDirectory = firstNonEmpty(resolve(session.WorkingDirectory), root)
+	session.PaneDefaults.WorkingDirectory = resolve(session.PaneDefaults.WorkingDirectory)
+	for i := range session.Windows {
+		window := &session.Windows[i]
+		window.WorkingDirectory = resolve(window.WorkingDirectory)
+		window.PaneDefaults.WorkingDirectory = resolve(window.PaneDefaults.WorkingDirectory)
+		for j := range window.Panes {
+			window.Panes[j].WorkingDirectory = resolve(window.Panes[j].WorkingDirectory)
+		}
+	}
+	return resolveErr
+}
+
+// resolveRoot makes the session's root absolute. A relative root is taken relative to the
+// directory of the configuration file at path rather than the directory gridlock runs in, so
+// "root: ." makes the configuration work wherever it is started from.
+func resolveRoot(config *Config, path string) error {
+	root := expandPath(config.Session.Root)
+	if root == "" || filepath.IsAbs(root) {
+		config.Session.Root = root
+		return nil
+	}
+	configPath, err := filepath.Abs(path)
+	if err != nil {
+		return fmt.Errorf("failed to resolve root: %v", err)
+	}
+	config.Session.Root = filepath.Join(filepath.Dir(configPath), root)
+	return nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// stripCommands removes the commands of all panes, leaving only the geometry and directories
+func stripCommands(config *Config) {
+	for i := range config.Session.Windows {
+		window := &config.Session.Windows[i]
+		for j := range window.Panes {
+			window.Panes[j].Command = ""
+			window.Panes[j].Commands = nil
+			window.Panes[j].Send = nil
+		}
+	}
+}
+
+// runCommandsCommand sends the configured commands to the panes of a session created with
+// --no-commands. Panes are found by the name gridlock recorded on them.
+func runCommandsCommand(fs *flag.FlagSet) func(args []string, opts upOptions) {
+	return func(args []string, opts upOptions) {
+		config, err := loadConfig(opts.configFile)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		if err := prepareConfig(config); err != nil {
+			log.Fatalf("invalid config: %v", err)
+		}
+		t := newTMUX(opts, config)
+		sessionName := config.Session.Name
+
+		query := newTMUX(opts, config)
+		query.DryRun = false
+		if !query.sessionExists(sessionName) {
+			log.Fatalf("Session %s is not running", sessionName)
+		}
+
+		onlyWindows := make(map[string]bool)
+		for _, name := range args {
+			onlyWindows[name] = true
+		}
+		if err := t.runPaneCommands(query, config, onlyWindows); err != nil {
+			log.Fatalf("Failed to list panes: %v", err)
+		}
+	}
+}
+
+// runPaneCommands sends the configured commands to the panes of the session, or of the windows
+// in onlyWindows, found by the window and pane names gridlock recorded on them. query lists the
+// panes, as it runs even when t only prints its commands.
+func (t *TMUX) runPaneCommands(query *TMUX, config *Config, onlyWindows map[string]bool) error {
+	out, err := query.run("list-panes", "-s", "-t", "="+config.Session.Name, "-F", "#{pane_id}\t#{"+metadataWindowName+"}\t#{"+metadataPaneName+"}")
+	if err != nil {
+		return err
+	}
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		parts := strings.SplitN(line, "\t", 3)
+		if len(parts) < 3 {
+			continue
+		}
+		paneID, windowName, paneName := parts[0], parts[1], parts[2]
+		if len(onlyWindows) > 0 && !onlyWindows[windowName] {
+			continue
+		}
+		window := findWindow(config.Session.Windows, windowName)
+		if window == nil {
+			continue
+		}
+		pane := findPane(window, paneName)
+		if pane == nil || (pane.Command == "" && len(pane.Commands) == 0 && len(pane.Send) == 0) {
+			continue
+		}
+		fmt.Printf("Running commands in %s:%s\n", window.Name, paneName)
+		if err := t.sendCommands(paneID, pane, window.Ephemeral); err != nil {
+			t.reportPaneFailure(paneID, "send-keys", err)
+		}
+	}
+	return nil
+}
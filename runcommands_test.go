@@ -0,0 +1,42 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRunPaneCommandsMatchesRenamedWindows(t *testing.T) {
+	var sent []string
+	exec := func(args []string) (string, error) {
+		switch args[0] {
+		case "list-panes":
+			return "%1\tédition\tvim\n%2\tédition\tserveur\n%3\tlogs\ttail\n%4\t\tshell\n", nil
+		case "send-keys":
+			sent = append(sent, strings.Join(args[1:], " "))
+		}
+		return "", nil
+	}
+	tmux := newTMUX(upOptions{executor: exec}, nil)
+	config := &Config{Session: SessionConfig{Name: "dev", Windows: []WindowConfig{
+		{Name: "édition", Panes: []PaneConfig{{Name: "vim", Command: "vim"}, {Name: "serveur"}}},
+		{Name: "logs", Panes: []PaneConfig{{Name: "tail", Command: "tail -f log"}}},
+	}}}
+
+	// The windows are found by the names gridlock recorded, whatever they are called now
+	if err := tmux.runPaneCommands(tmux, config, nil); err != nil {
+		t.Fatalf("runPaneCommands failed: %v", err)
+	}
+	want := []string{"-t %1 vim C-m", "-t %3 tail -f log C-m"}
+	if !reflect.DeepEqual(sent, want) {
+		t.Errorf("sent = %q, want %q", sent, want)
+	}
+
+	sent = nil
+	if err := tmux.runPaneCommands(tmux, config, map[string]bool{"logs": true}); err != nil {
+		t.Fatalf("runPaneCommands failed: %v", err)
+	}
+	if want := []string{"-t %3 tail -f log C-m"}; !reflect.DeepEqual(sent, want) {
+		t.Errorf("sent to logs = %q, want %q", sent, want)
+	}
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronSchedule is a parsed cron expression: the minutes, hours, days of the month, months
+// and days of the week it matches as bit sets, or a fixed interval for @every
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	// Whether the day of the month and of the week were both restricted, in which case
+	// matching either is enough, as in cron
+	domAndDow bool
+	every     time.Duration
+}
+
+// Shorthands for common cron expressions
+var cronShorthands = map[string]string{
+	"@hourly":   "0 * * * *",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@weekly":   "0 0 * * 0",
+	"@monthly":  "0 0 1 * *",
+	"@yearly":   "0 0 1 1 *",
+}
+
+// parseCron parses a five-field cron expression (minute, hour, day of month, month, day of
+// week) with *, lists, ranges and steps, one of the @hourly style shorthands, or
+// "@every <duration>" such as "@every 15m"
+func parseCron(expr string) (*cronSchedule, error) {
+	expr = strings.TrimSpace(expr)
+	if interval, ok := strings.CutPrefix(expr, "@every "); ok {
+		every, err := time.ParseDuration(strings.TrimSpace(interval))
+		if err != nil || every < time.Minute {
+			return nil, fmt.Errorf("invalid interval %q, expected a duration of at least 1m", interval)
+		}
+		return &cronSchedule{every: every}, nil
+	}
+	if full, ok := cronShorthands[expr]; ok {
+		expr = full
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid cron expression %q, expected 5 fields", expr)
+	}
+	bounds := [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+	var sets [5]uint64
+	for i, field := range fields {
+		set, err := parseCronField(field, bounds[i][0], bounds[i][1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid cron expression %q: %v", expr, err)
+		}
+		sets[i] = set
+	}
+	// Sunday is both 0 and 7
+	if sets[4]&(1<<7) != 0 {
+		sets[4] |= 1
+	}
+	return &cronSchedule{
+		minute:    sets[0],
+		hour:      sets[1],
+		dom:       sets[2],
+		month:     sets[3],
+		dow:       sets[4],
+		domAndDow: fields[2] != "*" && fields[4] != "*",
+	}, nil
+}
+
+// parseCronField parses a field of a cron expression into the set of values it matches
+func parseCronField(field string, min, max int) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			step, err = strconv.Atoi(stepPart)
+			if err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step %q", stepPart)
+			}
+		}
+		low, high := min, max
+		if rangePart != "*" {
+			lowPart, highPart, isRange := strings.Cut(rangePart, "-")
+			var err error
+			if low, err = strconv.Atoi(lowPart); err != nil {
+				return 0, fmt.Errorf("invalid value %q", part)
+			}
+			high = low
+			if isRange {
+				if high, err = strconv.Atoi(highPart); err != nil {
+					return 0, fmt.Errorf("invalid value %q", part)
+				}
+			} else if hasStep {
+				high = max
+			}
+		}
+		if low < min || high > max || low > high {
+			return 0, fmt.Errorf("%q is out of range %d-%d", part, min, max)
+		}
+		for v := low; v <= high; v += step {
+			set |= 1 << v
+		}
+	}
+	return set, nil
+}
+
+// matches reports whether the schedule is due in the minute of t. An @every schedule is
+// due once the interval has passed since it last ran.
+func (c *cronSchedule) matches(t time.Time, last time.Time) bool {
+	if c.every > 0 {
+		return !t.Before(last.Add(c.every))
+	}
+	if c.minute&(1<<t.Minute()) == 0 || c.hour&(1<<t.Hour()) == 0 || c.month&(1<<int(t.Month())) == 0 {
+		return false
+	}
+	dom := c.dom&(1<<t.Day()) != 0
+	dow := c.dow&(1<<int(t.Weekday())) != 0
+	if c.domAndDow {
+		return dom || dow
+	}
+	return dom && dow
+}
+
+// validateSchedules checks the schedule entries of every pane
+func validateSchedules(config *Config) error {
+	for _, window := range config.Session.Windows {
+		for _, pane := range window.Panes {
+			for _, entry := range pane.Schedule {
+				if entry.Command == "" {
+					return fmt.Errorf("window %s: pane %s: schedule entry %q has no command", window.Name, pane.Name, entry.Cron)
+				}
+				if _, err := parseCron(entry.Cron); err != nil {
+					return fmt.Errorf("window %s: pane %s: %v", window.Name, pane.Name, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// hasSchedules reports whether any pane of the session has schedule entries
+func hasSchedules(config *Config) bool {
+	for _, window := range config.Session.Windows {
+		for _, pane := range window.Panes {
+			if len(pane.Schedule) > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// scheduledCommand is a schedule entry of a configured pane
+type scheduledCommand struct {
+	window, pane string
+	command      string
+	schedule     *cronSchedule
+	last         time.Time
+}
+
+// scheduleCommand types the scheduled commands of the panes into them when they are due,
+// checking once a minute for as long as the session exists
+func scheduleCommand(fs *flag.FlagSet) func(args []string, opts upOptions) {
+	sessionID := fs.String("session", "", "ID or name of the session to run the schedules of (started by gridlock up)")
+	return func(args []string, opts upOptions) {
+		config, err := loadConfig(opts.configFile)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		if err := prepareConfig(config); err != nil {
+			log.Fatalf("invalid config: %v", err)
+		}
+		// @every intervals start now, as the panes just got their commands
+		started := time.Now().Truncate(time.Minute)
+		var scheduled []*scheduledCommand
+		for _, window := range config.Session.Windows {
+			for _, pane := range window.Panes {
+				for _, entry := range pane.Schedule {
+					schedule, _ := parseCron(entry.Cron)
+					scheduled = append(scheduled, &scheduledCommand{window: window.Name, pane: pane.Name, command: entry.Command, schedule: schedule, last: started})
+				}
+			}
+		}
+		target := firstNonEmpty(*sessionID, config.Session.Name)
+		t := newTMUX(opts, config)
+		t.DryRun = false
+
+		for t.sessionExists(target) {
+			// Wake up at the start of every minute
+			now := time.Now()
+			time.Sleep(now.Truncate(time.Minute).Add(time.Minute).Sub(now))
+			now = time.Now().Truncate(time.Minute)
+			var due []*scheduledCommand
+			for _, s := range scheduled {
+				if s.schedule.matches(now, s.last) {
+					s.last = now
+					due = append(due, s)
+				}
+			}
+			if len(due) > 0 {
+				t.runScheduled(target, due)
+			}
+		}
+	}
+}
+
+// runScheduled types the due commands into their panes, found by the names gridlock
+// recorded on them
+func (t *TMUX) runScheduled(target string, due []*scheduledCommand) {
+	out, err := t.run("list-panes", "-s", "-t", target, "-F", "#{pane_id}\t#{"+metadataWindowName+"}\t#{"+metadataPaneName+"}")
+	if err != nil {
+		return
+	}
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		parts := strings.SplitN(line, "\t", 3)
+		if len(parts) < 3 {
+			continue
+		}
+		windowName, paneName := parts[1], parts[2]
+		for _, s := range due {
+			if s.window == windowName && s.pane == paneName {
+				t.run("send-keys", "-t", parts[0], s.command, "C-m")
+			}
+		}
+	}
+}
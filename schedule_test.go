@@ -0,0 +1,32 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRunScheduledMatchesPanesByName(t *testing.T) {
+	var sent []string
+	exec := func(args []string) (string, error) {
+		switch args[0] {
+		case "list-panes":
+			return "%1\tédition\tvim\n%2\tédition\tserveur web\n%3\tlogs\tvim\n%4\tlogs\t\n", nil
+		case "send-keys":
+			sent = append(sent, strings.Join(args[1:], " "))
+		}
+		return "", nil
+	}
+	tmux := newTMUX(upOptions{executor: exec}, nil)
+
+	tmux.runScheduled("=dev", []*scheduledCommand{
+		{window: "édition", pane: "serveur web", command: "make"},
+		{window: "logs", pane: "vim", command: ":e"},
+		{window: "logs", pane: "tail", command: "date"},
+	})
+
+	want := []string{"-t %2 make C-m", "-t %3 :e C-m"}
+	if !reflect.DeepEqual(sent, want) {
+		t.Errorf("sent = %q, want %q", sent, want)
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/esaiaswestberg/gridlock/pkg/tmux"
+)
+
+// Session user option naming the session a scratchpad belongs to
+const metadataScratchpadOf = "@gridlock-scratchpad-of"
+
+func scratchpadName(sessionName string) string {
+	return sessionName + "-scratchpad"
+}
+
+// setupScratchpad creates the scratchpad session unless it is running already, and binds
+// its key: from the session it shows the scratchpad, from the scratchpad it goes back
+func (t *TMUX) setupScratchpad(sessionName string, sessionWorkDir string, scratchpad *ScratchpadConfig) {
+	name := scratchpadName(sessionName)
+	if !t.sessionExists("=" + name) {
+		fmt.Printf("Creating scratchpad session: %s\n", name)
+		args := []string{"new-session", "-d", "-s", name}
+		if dir := firstNonEmpty(scratchpad.WorkingDirectory, sessionWorkDir); dir != "" {
+			args = append(args, "-c", expandPath(dir))
+		}
+		if _, err := t.run(args...); err != nil {
+			log.Printf("Warning: failed to create scratchpad session: %v", err)
+			return
+		}
+		t.setSessionMetadata(name, metadataScratchpadOf, sessionName)
+		if scratchpad.Command != "" {
+			t.run("send-keys", "-t", name+":", scratchpad.Command, "C-m")
+		}
+	}
+
+	if scratchpad.Key == "" {
+		return
+	}
+	var show, hide string
+	if scratchpad.Popup == nil || *scratchpad.Popup {
+		attach := "TMUX= tmux"
+		if t.Socket != "" {
+			attach += " -L '" + t.Socket + "'"
+		}
+		attach += " attach-session -t '=" + name + "'"
+		show = strings.Join([]string{
+			"display-popup", "-E",
+			"-w", tmux.Quote(firstNonEmpty(scratchpad.Width, "80%")),
+			"-h", tmux.Quote(firstNonEmpty(scratchpad.Height, "80%")),
+			tmux.Quote(attach),
+		}, " ")
+		// The popup closes when its client detaches
+		hide = "detach-client"
+	} else {
+		show = "switch-client -t " + tmux.Quote("="+name)
+		hide = "switch-client -t " + tmux.Quote("="+sessionName)
+	}
+	// The key shows the scratchpad in the session, hides it in the scratchpad and does what it
+	// was bound to before everywhere else
+	condition, scratchpadCondition := sessionCondition(sessionName), sessionCondition(name)
+	hideIfScratchpad := "if-shell -F " + tmux.Quote(scratchpadCondition) + " " + tmux.Quote(hide)
+	if previous := unwrapBinding(t.previousBinding("prefix", scratchpad.Key, condition), scratchpadCondition); previous != "" {
+		hideIfScratchpad += " " + tmux.Quote(previous)
+	}
+	t.run("bind-key", scratchpad.Key, "if-shell", "-F", condition, show, hideIfScratchpad)
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// screen brings a configuration up in GNU screen, for servers without tmux. screen's
+// split regions belong to the terminal showing them rather than to the session, so layouts
+// cannot be expressed: every pane becomes a screen window of its own, named after its
+// window and pane when the window has more than one.
+type screen struct {
+	dryRun  bool
+	verbose bool
+}
+
+func (s *screen) run(args ...string) error {
+	if s.verbose || s.dryRun {
+		fmt.Printf("screen %s\n", strings.Join(quoteArgs(args), " "))
+	}
+	if s.dryRun {
+		return nil
+	}
+	out, err := exec.Command("screen", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("screen %s: %v: %s", strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// sessionExists looks for the session in screen -ls, which lists sessions as <pid>.<name>
+// and exits with 1 even when it found some
+func (s *screen) sessionExists(name string) bool {
+	out, _ := exec.Command("screen", "-ls").Output()
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if _, session, ok := strings.Cut(fields[0], "."); ok && session == name {
+			return true
+		}
+	}
+	return false
+}
+
+// create writes a screenrc creating the windows of the session, and a script for every pane
+// that needs one, to the state directory and starts the session from it detached
+func (s *screen) create(config *Config, layouts []LayoutNode) error {
+	fmt.Printf("Creating session: %s\n", config.Session.Name)
+	dir, err := stateDir()
+	if err != nil {
+		return err
+	}
+	dir = filepath.Join(dir, "screen", slugify(config.Session.Name))
+	rc, scripts := screenrc(config, layouts, dir)
+	if !s.dryRun {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		for path, script := range scripts {
+			if err := os.WriteFile(path, []byte(script+"\n"), 0644); err != nil {
+				return fmt.Errorf("failed to write pane script: %v", err)
+			}
+		}
+		if err := os.WriteFile(filepath.Join(dir, "screenrc"), []byte(rc), 0644); err != nil {
+			return fmt.Errorf("failed to write screenrc: %v", err)
+		}
+	}
+	return s.run("-dmS", config.Session.Name, "-c", filepath.Join(dir, "screenrc"))
+}
+
+func (s *screen) attach(name string) error {
+	if s.dryRun {
+		return s.run("-x", name)
+	}
+	cmd := exec.Command("screen", "-x", name)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// screenrc returns the screenrc of a session and the scripts of its panes by path. The
+// user's own screenrc is read first, so their key bindings and status line still apply.
+func screenrc(config *Config, layouts []LayoutNode, dir string) (string, map[string]string) {
+	var b strings.Builder
+	scripts := make(map[string]string)
+	if home, err := os.UserHomeDir(); err == nil {
+		if _, err := os.Stat(filepath.Join(home, ".screenrc")); err == nil {
+			fmt.Fprintf(&b, "source %s\n", screenString(filepath.Join(home, ".screenrc")))
+		}
+	}
+	session := &config.Session
+	index := 0
+	focus := 0
+	for i := range session.Windows {
+		window := &session.Windows[i]
+		names := layoutPaneNames(layouts[i])
+		if len(names) == 0 && len(window.Panes) > 0 {
+			names = []string{window.Panes[0].Name}
+		}
+		if len(names) == 0 {
+			names = []string{""}
+		}
+		for _, name := range names {
+			title := window.Name
+			if len(names) > 1 {
+				title += "/" + name
+			}
+			node := LayoutNode{PaneName: name}
+			workDir := getWorkDirForNode(&node, window, session.WorkingDirectory)
+			if workDir == "" {
+				workDir, _ = os.Getwd()
+			}
+			fmt.Fprintf(&b, "chdir %s\n", screenString(workDir))
+			line := fmt.Sprintf("screen -t %s %d", screenString(title), index)
+			pane := findPane(window, name)
+			if pane != nil {
+				if script := paneScript(pane); script != "" {
+					path := filepath.Join(dir, fmt.Sprintf("%d.sh", index))
+					scripts[path] = script
+					line += " sh " + screenString(path)
+				}
+			}
+			b.WriteString(line + "\n")
+			// The window with focus starts on its pane with focus, if it has one
+			if window.Focus && (name == names[0] || pane != nil && pane.Focus) {
+				focus = index
+			}
+			index++
+		}
+	}
+	fmt.Fprintf(&b, "select %d\n", focus)
+	return b.String(), scripts
+}
+
+// screenString quotes a string for a screenrc, where $ would expand environment variables
+// inside double quotes
+func screenString(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`)
+	return `"` + r.Replace(s) + `"`
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Commands longer than this many bytes are sent as scripts unless the session sets its own
+// script-threshold, as tmux and the shell's line editor struggle with very long input
+const defaultScriptThreshold = 1024
+
+// sendsAsScript reports whether a command of the pane is sent as a script: always with
+// as-script: true, never with as-script: false, and otherwise when it is longer than the
+// threshold. A negative script-threshold turns the latter off.
+func (t *TMUX) sendsAsScript(pane *PaneConfig, command string) bool {
+	if pane.AsScript != nil {
+		return *pane.AsScript
+	}
+	return t.scriptThreshold > 0 && len(command) > t.scriptThreshold
+}
+
+// writeCommandScript writes a command to a temporary script that removes itself once the
+// shell starts running it. Against a simulated server and in dry-run mode nothing is written
+// and a placeholder path is returned, so the tmux commands stay the same from run to run.
+func (t *TMUX) writeCommandScript(command string) (string, error) {
+	if t.DryRun || t.Executor != nil {
+		return filepath.Join(os.TempDir(), "gridlock-XXXX.sh"), nil
+	}
+	f, err := os.CreateTemp("", "gridlock-*.sh")
+	if err != nil {
+		return "", fmt.Errorf("failed to create script: %v", err)
+	}
+	defer f.Close()
+	if _, err := fmt.Fprintf(f, "rm -f \"$0\"\n%s\n", command); err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("failed to write script: %v", err)
+	}
+	return f.Name(), nil
+}
+
+// sendCommand types a command into a pane, or a line running it from a script, after its
+// delay. With exit set, the shell exits once the command succeeded.
+func (t *TMUX) sendCommand(target string, pane *PaneConfig, entry CommandEntry, exit bool) error {
+	if entry.Delay != "" && !t.DryRun && t.Executor == nil {
+		delay, _ := time.ParseDuration(entry.Delay)
+		time.Sleep(delay)
+	}
+	command := entry.Line()
+	if t.sendsAsScript(pane, command) {
+		path, err := t.writeCommandScript(command)
+		if err != nil {
+			return err
+		}
+		command = "sh " + shellQuote(path)
+	}
+	enter := entry.Enter == nil || *entry.Enter
+	if exit && enter {
+		command = exitOnSuccess(command)
+	}
+	if !entry.Literal {
+		args := []string{"send-keys", "-t", target, command}
+		if enter {
+			args = append(args, "C-m")
+		}
+		_, err := t.run(args...)
+		return err
+	}
+	if _, err := t.run("send-keys", "-t", target, "-l", command); err != nil {
+		return err
+	}
+	if enter {
+		_, err := t.run("send-keys", "-t", target, "C-m")
+		return err
+	}
+	return nil
+}
+
+// validateCommands checks the options of the command entries of every pane
+func validateCommands(config *Config) error {
+	for _, window := range config.Session.Windows {
+		for _, pane := range window.Panes {
+			for i, entry := range pane.Commands {
+				if strings.TrimSpace(entry.Run) == "" {
+					return fmt.Errorf("window %s: pane %s: command %d has nothing to run", window.Name, pane.Name, i+1)
+				}
+				if entry.Delay != "" {
+					if delay, err := time.ParseDuration(entry.Delay); err != nil || delay < 0 {
+						return fmt.Errorf("window %s: pane %s: command %d: invalid delay %q, expected a duration such as 500ms", window.Name, pane.Name, i+1, entry.Delay)
+					}
+				}
+				if entry.Retry < 0 {
+					return fmt.Errorf("window %s: pane %s: command %d: retry must not be negative, got %d", window.Name, pane.Name, i+1, entry.Retry)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// validateSend checks the keys panes send after their commands
+func validateSend(config *Config) error {
+	for _, window := range config.Session.Windows {
+		for _, pane := range window.Panes {
+			for i, send := range pane.Send {
+				if strings.TrimSpace(send.Keys) == "" && !sendsEnter(send) {
+					return fmt.Errorf("window %s: pane %s: send entry %d has no keys", window.Name, pane.Name, i+1)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// sendKeys types one send entry of a pane. Text is sent with send-keys -l so tmux does not
+// look up words like Enter or Space as keys; key-names entries are split on whitespace into
+// the key names tmux sends as they are.
+func (t *TMUX) sendKeys(target string, send SendKeys) error {
+	args := []string{"send-keys", "-t", target}
+	if send.KeyNames {
+		args = append(args, strings.Fields(send.Keys)...)
+	} else if send.Keys != "" {
+		if _, err := t.run("send-keys", "-t", target, "-l", send.Keys); err != nil {
+			return err
+		}
+	}
+	if sendsEnter(send) {
+		args = append(args, "C-m")
+	}
+	if len(args) == 3 {
+		return nil
+	}
+	_, err := t.run(args...)
+	return err
+}
+
+// sendsEnter reports whether Enter follows the keys of a send entry: by default after text
+// and not after key names
+func sendsEnter(send SendKeys) bool {
+	if send.Enter != nil {
+		return *send.Enter
+	}
+	return !send.KeyNames
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSendKeys(t *testing.T) {
+	yes, no := true, false
+	tests := []struct {
+		name string
+		send SendKeys
+		want []string
+	}{
+		{"text", SendKeys{Keys: "echo Enter"}, []string{"tmux send-keys -t s:w.0 -l echo Enter", "tmux send-keys -t s:w.0 C-m"}},
+		{"text without enter", SendKeys{Keys: "vim ", Enter: &no}, []string{"tmux send-keys -t s:w.0 -l vim "}},
+		{"key names", SendKeys{Keys: "C-c Escape", KeyNames: true}, []string{"tmux send-keys -t s:w.0 C-c Escape"}},
+		{"key names with enter", SendKeys{Keys: "F6", KeyNames: true, Enter: &yes}, []string{"tmux send-keys -t s:w.0 F6 C-m"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeTMUX{}
+			if err := newTMUX(upOptions{executor: fake.exec}, nil).sendKeys("s:w.0", tt.send); err != nil {
+				t.Fatalf("sendKeys failed: %v", err)
+			}
+			if !reflect.DeepEqual(fake.commands, tt.want) {
+				t.Errorf("commands = %q, want %q", fake.commands, tt.want)
+			}
+		})
+	}
+}
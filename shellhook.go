@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// shellHookRCFiles are the startup files gridlock hook install adds the hook to
+var shellHookRCFiles = map[string]string{
+	"bash": ".bashrc",
+	"zsh":  ".zshrc",
+	"fish": filepath.Join(".config", "fish", "config.fish"),
+}
+
+const hookUsage = "Usage: gridlock hook bash|zsh|fish | install bash|zsh|fish | allow [dir] | deny [dir]"
+
+// hookCommand prints or installs the shell hook that reacts to entering a directory with a
+// configuration, and manages the directories where it brings the session up by itself
+func hookCommand(fs *flag.FlagSet) func(args []string, opts upOptions) {
+	return func(args []string, opts upOptions) {
+		if len(args) == 0 {
+			log.Fatalf(hookUsage)
+		}
+		switch args[0] {
+		case "bash", "zsh", "fish":
+			writeShellHook(os.Stdout, args[0])
+		case "install":
+			if len(args) != 2 {
+				log.Fatalf("Usage: gridlock hook install bash|zsh|fish")
+			}
+			if err := installShellHook(args[1]); err != nil {
+				log.Fatalf("%v", err)
+			}
+		case "allow", "deny":
+			dir := "."
+			if len(args) > 1 {
+				dir = args[1]
+			}
+			dir, err := filepath.Abs(expandPath(dir))
+			if err != nil {
+				log.Fatalf("failed to resolve path: %v", err)
+			}
+			if err := setHookAllowed(dir, args[0] == "allow"); err != nil {
+				log.Fatalf("%v", err)
+			}
+		case "enter":
+			enterDirectory(opts)
+		default:
+			log.Fatalf(hookUsage)
+		}
+	}
+}
+
+// gridlockPath returns the path the shell hook runs gridlock by, quoted for the shell
+func gridlockPath() string {
+	executable, err := os.Executable()
+	if err != nil {
+		return "gridlock"
+	}
+	return shellQuote(executable)
+}
+
+// writeShellHook writes the hook of a shell, which runs gridlock hook enter whenever the
+// working directory changes, but not when the shell starts
+func writeShellHook(w io.Writer, shell string) {
+	gridlock := gridlockPath()
+	switch shell {
+	case "zsh":
+		fmt.Fprintln(w, "_gridlock_hook() {")
+		fmt.Fprintf(w, "    %s hook enter\n", gridlock)
+		fmt.Fprintln(w, "}")
+		fmt.Fprintln(w, "autoload -Uz add-zsh-hook")
+		fmt.Fprintln(w, "add-zsh-hook chpwd _gridlock_hook")
+	case "bash":
+		// bash has no hook for directory changes, so the prompt compares the directory
+		fmt.Fprintln(w, "_gridlock_hook() {")
+		fmt.Fprintln(w, "    local status=$?")
+		fmt.Fprintln(w, `    if [[ -n "${_gridlock_dir-}" && "$PWD" != "$_gridlock_dir" ]]; then`)
+		fmt.Fprintf(w, "        %s hook enter\n", gridlock)
+		fmt.Fprintln(w, "    fi")
+		fmt.Fprintln(w, `    _gridlock_dir="$PWD"`)
+		fmt.Fprintln(w, "    return $status")
+		fmt.Fprintln(w, "}")
+		fmt.Fprintln(w, `if [[ ";${PROMPT_COMMAND[*]:-};" != *";_gridlock_hook;"* ]]; then`)
+		fmt.Fprintln(w, `    PROMPT_COMMAND="_gridlock_hook${PROMPT_COMMAND:+;$PROMPT_COMMAND}"`)
+		fmt.Fprintln(w, "fi")
+	case "fish":
+		fmt.Fprintln(w, "function __gridlock_hook --on-variable PWD")
+		fmt.Fprintf(w, "    %s hook enter\n", gridlock)
+		fmt.Fprintln(w, "end")
+	}
+}
+
+// installShellHook adds the line loading the hook to the startup file of the shell, unless
+// it is there already
+func installShellHook(shell string) error {
+	rcFile, ok := shellHookRCFiles[shell]
+	if !ok {
+		return fmt.Errorf("unsupported shell %q, expected bash, zsh or fish", shell)
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(home, rcFile)
+	line := fmt.Sprintf(`eval "$(%s hook %s)"`, gridlockPath(), shell)
+	if shell == "fish" {
+		line = fmt.Sprintf("%s hook fish | source", gridlockPath())
+	}
+	existing, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if strings.Contains(string(existing), line) {
+		fmt.Printf("The hook is already installed in %s\n", path)
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	prefix := ""
+	if len(existing) > 0 && !strings.HasSuffix(string(existing), "\n") {
+		prefix = "\n"
+	}
+	if _, err := fmt.Fprintf(file, "%s\n# gridlock: react to entering a directory with a configuration\n%s\n", prefix, line); err != nil {
+		return err
+	}
+	fmt.Printf("Installed the hook in %s, it takes effect in new shells\n", path)
+	return nil
+}
+
+// hookAllowlistPath returns the file listing the directories where entering brings the
+// session up without asking, one per line
+func hookAllowlistPath() (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "hook-allow"), nil
+}
+
+// hookAllowlist returns the directories of the allowlist
+func hookAllowlist() []string {
+	path, err := hookAllowlistPath()
+	if err != nil {
+		return nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+	var dirs []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			dirs = append(dirs, filepath.Clean(expandPath(line)))
+		}
+	}
+	return dirs
+}
+
+// isHookAllowed reports whether dir is in the allowlist or below a directory that is
+func isHookAllowed(dir string) bool {
+	for _, allowed := range hookAllowlist() {
+		if dir == allowed || strings.HasPrefix(dir, allowed+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// setHookAllowed adds dir to the allowlist or removes it
+func setHookAllowed(dir string, allow bool) error {
+	path, err := hookAllowlistPath()
+	if err != nil {
+		return err
+	}
+	var dirs []string
+	for _, existing := range hookAllowlist() {
+		if existing != dir {
+			dirs = append(dirs, existing)
+		}
+	}
+	if allow {
+		dirs = append(dirs, dir)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	content := ""
+	if len(dirs) > 0 {
+		content = strings.Join(dirs, "\n") + "\n"
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return err
+	}
+	if allow {
+		fmt.Printf("Entering %s brings its session up\n", dir)
+	} else {
+		fmt.Printf("Entering %s only suggests bringing its session up\n", dir)
+	}
+	return nil
+}
+
+// enterDirectory is run by the shell hook when the working directory changed. With a
+// configuration in the directory, it brings the session up when the directory is in the
+// allowlist, and suggests it otherwise. Inside tmux it only suggests, and stays quiet in
+// the session of the configuration.
+func enterDirectory(opts upOptions) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return
+	}
+	configFile := resolveConfigPath(filepath.Join(dir, ".gridlock.yaml"))
+	if _, err := os.Stat(configFile); err != nil {
+		return
+	}
+	inTMUX := os.Getenv("TMUX") != ""
+	if inTMUX && !isEncryptedConfig(configFile) {
+		if config, err := loadConfig(configFile); err == nil {
+			query := newTMUX(opts, config)
+			query.DryRun = false
+			if out, err := query.run("display-message", "-p", "#S"); err == nil && strings.TrimSpace(out) == config.Session.Name {
+				return
+			}
+		}
+	}
+	if !inTMUX && isHookAllowed(dir) {
+		opts.configFile = configFile
+		up(opts)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "gridlock: %s found, run gridlock to bring its session up", filepath.Base(configFile))
+	if !inTMUX {
+		fmt.Fprint(os.Stderr, " (gridlock hook allow to do it on entering)")
+	}
+	fmt.Fprintln(os.Stderr)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/esaiaswestberg/gridlock/pkg/config"
+)
+
+// normalizeLayoutSizes checks the sizes in a layout tree. Percentages of siblings adding up
+// to more than 100% are an error with strict-sizes, and are otherwise scaled down to add up
+// to 100%, which needs every sibling to have one as there would be no room left for the others.
+func normalizeLayoutSizes(node *LayoutNode, strict bool) error {
+	for _, children := range [][]LayoutNode{node.Columns, node.Rows} {
+		total := 0
+		allPercent := true
+		for i := range children {
+			child := &children[i]
+			percent := false
+			if child.Size != "" {
+				value, isPercent, err := config.ParseSize(child.Size)
+				if err != nil {
+					return err
+				}
+				if isPercent {
+					total += value
+					percent = true
+				}
+			}
+			allPercent = allPercent && percent
+			if err := normalizeLayoutSizes(child, strict); err != nil {
+				return err
+			}
+		}
+		if total <= 100 {
+			continue
+		}
+		if strict || !allPercent {
+			return fmt.Errorf("sizes of %s add up to %d%%", describeNodes(children), total)
+		}
+		scalePercentages(children, total)
+		log.Printf("Warning: sizes of %s add up to %d%%, scaled down to 100%% (set strict-sizes to make this an error)", describeNodes(children), total)
+	}
+	return nil
+}
+
+// scalePercentages scales the percentages of nodes that add up to total so that they add
+// up to 100%. Each is rounded down, but to no less than 1% as tmux needs at least a cell for
+// every pane, and the points left over go to the largest remainders, the earlier node winning
+// ties, so the result is the same on every run and platform. Points the 1% floor takes beyond
+// 100% are taken back from the largest values.
+func scalePercentages(nodes []LayoutNode, total int) {
+	values := make([]int, len(nodes))
+	remainders := make([]int, len(nodes))
+	var order []int
+	left := 100
+	for i, node := range nodes {
+		value, _, _ := config.ParseSize(node.Size)
+		values[i] = value * 100 / total
+		remainders[i] = value * 100 % total
+		if values[i] == 0 {
+			// Raised to the floor, the remainder is used up
+			values[i] = 1
+		} else {
+			order = append(order, i)
+		}
+		left -= values[i]
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return remainders[order[a]] > remainders[order[b]]
+	})
+	for _, i := range order[:max(min(left, len(order)), 0)] {
+		values[i]++
+	}
+	for ; left < 0; left++ {
+		largest := 0
+		for i := range values {
+			if values[i] > values[largest] {
+				largest = i
+			}
+		}
+		values[largest]--
+	}
+	for i := range nodes {
+		nodes[i].Size = fmt.Sprintf("%d%%", values[i])
+	}
+}
+
+// describeNodes names a list of sibling layout nodes for error messages
+func describeNodes(nodes []LayoutNode) string {
+	var names []string
+	for _, node := range nodes {
+		if node.PaneName != "" {
+			names = append(names, node.PaneName)
+		} else {
+			names = append(names, "...")
+		}
+	}
+	return strings.Join(names, ", ")
+}
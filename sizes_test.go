@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// scaled returns the sizes scalePercentages gives nodes of the given percentages
+func scaled(percentages ...int) []string {
+	nodes := make([]LayoutNode, len(percentages))
+	total := 0
+	for i, p := range percentages {
+		nodes[i].Size = fmt.Sprintf("%d%%", p)
+		total += p
+	}
+	scalePercentages(nodes, total)
+	sizes := make([]string, len(nodes))
+	for i, node := range nodes {
+		sizes[i] = node.Size
+	}
+	return sizes
+}
+
+func TestScalePercentages(t *testing.T) {
+	tests := []struct {
+		name        string
+		percentages []int
+		want        []string
+	}{
+		{"halved", []int{100, 100}, []string{"50%", "50%"}},
+		{"proportional", []int{60, 60, 80}, []string{"30%", "30%", "40%"}},
+		{"largest remainder wins", []int{70, 70, 60}, []string{"35%", "35%", "30%"}},
+		{"tie goes to the earlier node", []int{50, 50, 50}, []string{"34%", "33%", "33%"}},
+		{"ties among later nodes", []int{10, 45, 45, 45, 45}, []string{"5%", "24%", "24%", "24%", "23%"}},
+		{"1% floor", []int{1, 1, 100, 100}, []string{"1%", "1%", "49%", "49%"}},
+		{"1% floor among several", []int{1, 1, 1, 1, 100, 100}, []string{"1%", "1%", "1%", "1%", "48%", "48%"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := scaled(tt.percentages...); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("scalePercentages(%v) = %q, want %q", tt.percentages, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScalePercentagesAddUpTo100(t *testing.T) {
+	for n := 2; n <= 10; n++ {
+		// Equal sizes, sizes growing by a step, and one full size among 1% ones
+		cases := [][]int{make([]int, n), make([]int, n), make([]int, n)}
+		for i := 0; i < n; i++ {
+			cases[0][i] = 100
+			cases[1][i] = 7 + 10*i
+			cases[2][i] = 1
+		}
+		cases[2][n-1] = 100
+		for _, percentages := range cases {
+			sum := 0
+			for _, size := range scaled(percentages...) {
+				var value int
+				fmt.Sscanf(size, "%d%%", &value)
+				if value < 1 {
+					t.Errorf("scalePercentages(%v) gives %s, want at least 1%%", percentages, size)
+				}
+				sum += value
+			}
+			if sum != 100 {
+				t.Errorf("scalePercentages(%v) = %q, adds up to %d%%, want 100%%", percentages, scaled(percentages...), sum)
+			}
+		}
+	}
+}
+
+func TestNormalizeLayoutSizes(t *testing.T) {
+	over := func() LayoutNode {
+		return LayoutNode{Columns: []LayoutNode{{PaneName: "a", Size: "80%"}, {Rows: []LayoutNode{{PaneName: "b", Size: "60%"}, {PaneName: "c", Size: "60%"}}, Size: "40%"}}}
+	}
+
+	node := over()
+	if err := normalizeLayoutSizes(&node, false); err != nil {
+		t.Fatalf("normalizeLayoutSizes failed: %v", err)
+	}
+	got := []string{node.Columns[0].Size, node.Columns[1].Size, node.Columns[1].Rows[0].Size, node.Columns[1].Rows[1].Size}
+	if want := []string{"67%", "33%", "50%", "50%"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("sizes = %q, want %q", got, want)
+	}
+
+	node = over()
+	if err := normalizeLayoutSizes(&node, true); err == nil || !strings.Contains(err.Error(), "add up to 120%") {
+		t.Errorf("strict normalizeLayoutSizes() error = %v, want sizes adding up to 120%%", err)
+	}
+
+	node = LayoutNode{Columns: []LayoutNode{{PaneName: "a", Size: "90%"}, {PaneName: "b", Size: "20%"}, {PaneName: "c", Size: "10"}}}
+	if err := normalizeLayoutSizes(&node, false); err == nil {
+		t.Error("normalizeLayoutSizes() of sizes mixing cells and percentages over 100% succeeded, want an error")
+	}
+}
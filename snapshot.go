@@ -0,0 +1,183 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/esaiaswestberg/gridlock/pkg/capture"
+)
+
+// snapshotsDir returns the directory captured session snapshots are kept in
+func snapshotsDir() (string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "snapshots"), nil
+}
+
+// snapshotSync runs the commands that synchronize the snapshots directory with a remote,
+// printing them instead in dry-run mode
+type snapshotSync struct {
+	dir    string
+	remote string
+	dryRun bool
+}
+
+func (s *snapshotSync) run(name string, args ...string) error {
+	if s.dryRun {
+		fmt.Printf("%s %s\n", name, strings.Join(args, " "))
+		return nil
+	}
+	cmd := exec.Command(name, args...)
+	cmd.Dir = s.dir
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s %s failed: %v", name, strings.Join(args, " "), err)
+	}
+	return nil
+}
+
+// output runs a git command that only inspects the repository, even in dry-run mode
+func (s *snapshotSync) output(args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = s.dir
+	out, err := cmd.Output()
+	return strings.TrimSpace(string(out)), err
+}
+
+// isGit reports whether the remote is a git repository rather than an rsync destination
+func (s *snapshotSync) isGit() bool {
+	return strings.HasSuffix(s.remote, ".git") || strings.HasSuffix(s.remote, ".git/")
+}
+
+// gitPrepare turns the snapshots directory into a clone of the remote and commits the
+// snapshots taken since the last sync, returning the branch to sync
+func (s *snapshotSync) gitPrepare() (string, error) {
+	if _, err := os.Stat(filepath.Join(s.dir, ".git")); err != nil {
+		if err := s.run("git", "init", "-q"); err != nil {
+			return "", err
+		}
+		if err := s.run("git", "remote", "add", "origin", s.remote); err != nil {
+			return "", err
+		}
+	}
+	if err := s.run("git", "add", "-A"); err != nil {
+		return "", err
+	}
+	if status, _ := s.output("status", "--porcelain"); status != "" || s.dryRun {
+		host, _ := os.Hostname()
+		if err := s.run("git", "commit", "-q", "-m", "Snapshots from "+host); err != nil {
+			return "", err
+		}
+	}
+	branch, err := s.output("symbolic-ref", "--short", "HEAD")
+	if err != nil || branch == "" {
+		branch = "main"
+	}
+	return branch, nil
+}
+
+// pull brings in the snapshots of the remote. Snapshots of the same session taken on both
+// sides are resolved by git or, with rsync, in favour of the newer file.
+func (s *snapshotSync) pull() error {
+	if !s.isGit() {
+		return s.run("rsync", "-az", "--update", strings.TrimSuffix(s.remote, "/")+"/", s.dir+"/")
+	}
+	branch, err := s.gitPrepare()
+	if err != nil {
+		return err
+	}
+	// An empty remote has nothing to pull
+	if refs, err := s.output("ls-remote", "--heads", "origin", branch); err == nil && refs == "" && !s.dryRun {
+		return nil
+	}
+	return s.run("git", "pull", "-q", "--rebase", "origin", branch)
+}
+
+// push sends the snapshots to the remote, pulling in those of the remote first
+func (s *snapshotSync) push() error {
+	if !s.isGit() {
+		return s.run("rsync", "-az", "--update", s.dir+"/", strings.TrimSuffix(s.remote, "/")+"/")
+	}
+	if err := s.pull(); err != nil {
+		return err
+	}
+	branch, err := s.output("symbolic-ref", "--short", "HEAD")
+	if err != nil || branch == "" {
+		branch = "main"
+	}
+	return s.run("git", "push", "-q", "origin", "HEAD:"+branch)
+}
+
+// snapshotCommand captures a running session into the snapshots directory, and synchronizes
+// that directory with a git repository or rsync destination
+func snapshotCommand(fs *flag.FlagSet) func(args []string, opts upOptions) {
+	push := fs.Bool("push", false, "Send the snapshots to --remote after capturing")
+	pull := fs.Bool("pull", false, "Fetch the snapshots from --remote instead of capturing")
+	remote := fs.String("remote", os.Getenv("GRIDLOCK_REMOTE"), "Git repository (ending in .git) or rsync destination (host:path) to sync snapshots with, GRIDLOCK_REMOTE by default")
+	return func(args []string, opts upOptions) {
+		if len(args) > 0 {
+			fs.Parse(args[1:])
+			args = append(args[:1], fs.Args()...)
+		}
+		if len(args) > 1 || (*push && *pull) {
+			log.Fatalf("Usage: gridlock snapshot [session] [--push | --pull] [--remote remote]")
+		}
+		if (*push || *pull) && *remote == "" {
+			log.Fatalf("--push and --pull need a --remote or GRIDLOCK_REMOTE")
+		}
+		dir, err := snapshotsDir()
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			log.Fatalf("failed to create snapshots directory: %v", err)
+		}
+		snapshots := &snapshotSync{dir: dir, remote: *remote, dryRun: opts.dryRun}
+
+		if *pull {
+			if err := snapshots.pull(); err != nil {
+				log.Fatalf("Failed to pull snapshots: %v", err)
+			}
+			fmt.Printf("Pulled snapshots from %s into %s\n", *remote, dir)
+			return
+		}
+
+		t := newTMUX(opts, nil)
+		t.DryRun = false
+		sessionName := ""
+		if len(args) > 0 {
+			sessionName = args[0]
+		} else {
+			out, err := t.run("display-message", "-p", "#S")
+			if err != nil {
+				log.Fatalf("Failed to get current session: %v. Name the session to snapshot", err)
+			}
+			sessionName = strings.TrimSpace(out)
+		}
+		// The command lines panes run, as a snapshot is meant to bring the session back
+		config, err := capture.SessionWith(sessionName, capture.Options{FullCommand: true, Wrappers: captureWrappers(""), Run: t.run})
+		if err != nil {
+			log.Fatalf("Failed to capture session: %v", err)
+		}
+		path := filepath.Join(dir, slugify(sessionName)+".yaml")
+		if err := writeConfig(path, config); err != nil {
+			log.Fatalf("%v", err)
+		}
+		fmt.Printf("Saved snapshot of %s to %s\n", sessionName, path)
+
+		if *push {
+			if err := snapshots.push(); err != nil {
+				log.Fatalf("Failed to push snapshots: %v", err)
+			}
+			fmt.Printf("Pushed snapshots to %s\n", *remote)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/esaiaswestberg/gridlock/pkg/schema"
+)
+
+// statsPath returns the file in which window and pane selections of a session are recorded
+func statsPath(sessionName string) (string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "stats", sessionName), nil
+}
+
+// usageHook returns a tmux command appending the current window and pane to the stats file.
+// It runs inside tmux, so nothing leaves the machine.
+func usageHook(kind string, path string) string {
+	return fmt.Sprintf(`run-shell -b "printf \"%%s\t%%s\t%%s\n\" %s #{q:window_name} #{q:%s} >> \"%s\""`, kind, metadataPaneName, path)
+}
+
+// prepareUsageStats creates the stats directory and returns the stats file of the session
+func prepareUsageStats(statsName string) (string, error) {
+	path, err := statsPath(statsName)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// trackSessionUsage records every window selection of the session in the stats file
+func (t *TMUX) trackSessionUsage(sessionName string, path string) {
+	t.run("set-hook", "-t", sessionName, "session-window-changed", usageHook("window", path))
+}
+
+// trackWindowUsage records every pane selection of the window in the stats file
+func (t *TMUX) trackWindowUsage(windowTarget string, path string) {
+	t.run("set-hook", "-w", "-t", windowTarget, "window-pane-changed", usageHook("pane", path))
+}
+
+// usageStats counts the recorded selections of the configured windows and panes. Windows and
+// panes that were never selected are included, as those are the ones worth pruning.
+func usageStats(config *Config, path string) (schema.Stats, error) {
+	stats := schema.Stats{SchemaVersion: schema.Version, Session: config.Session.Name, Windows: []schema.WindowUsage{}}
+	index := map[string]int{}
+	panes := map[string]map[string]int{}
+	for _, window := range config.Session.Windows {
+		index[window.Name] = len(stats.Windows)
+		stats.Windows = append(stats.Windows, schema.WindowUsage{Name: window.Name, InConfig: true})
+		panes[window.Name] = map[string]int{}
+	}
+
+	file, err := os.Open(path)
+	if err != nil && !os.IsNotExist(err) {
+		return stats, err
+	}
+	if err == nil {
+		defer file.Close()
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			fields := strings.SplitN(scanner.Text(), "\t", 3)
+			if len(fields) != 3 {
+				continue
+			}
+			kind, windowName, paneName := fields[0], fields[1], fields[2]
+			i, ok := index[windowName]
+			if !ok {
+				i = len(stats.Windows)
+				index[windowName] = i
+				stats.Windows = append(stats.Windows, schema.WindowUsage{Name: windowName})
+				panes[windowName] = map[string]int{}
+			}
+			if kind == "window" {
+				stats.Windows[i].Selections++
+			}
+			if paneName != "" {
+				panes[windowName][paneName]++
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return stats, err
+		}
+	}
+
+	for i := range stats.Windows {
+		window := &stats.Windows[i]
+		window.Panes = []schema.PaneUsage{}
+		if configWindow := findWindow(config.Session.Windows, window.Name); configWindow != nil {
+			for _, pane := range configWindow.Panes {
+				window.Panes = append(window.Panes, schema.PaneUsage{Name: pane.Name, Selections: panes[window.Name][pane.Name]})
+			}
+		}
+		sort.SliceStable(window.Panes, func(a, b int) bool {
+			return window.Panes[a].Selections > window.Panes[b].Selections
+		})
+	}
+	sort.SliceStable(stats.Windows, func(a, b int) bool {
+		return stats.Windows[a].Selections > stats.Windows[b].Selections
+	})
+	return stats, nil
+}
+
+// statsCommand summarizes how often the windows and panes of the session were selected
+func statsCommand(fs *flag.FlagSet) func(args []string, opts upOptions) {
+	jsonOutput := fs.Bool("json", false, "Print the usage stats as JSON")
+	return func(args []string, opts upOptions) {
+		config, err := loadConfig(opts.configFile)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		if err := prepareConfig(config); err != nil {
+			log.Fatalf("invalid config: %v", err)
+		}
+		path, err := statsPath(config.Session.Name)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		stats, err := usageStats(config, path)
+		if err != nil {
+			log.Fatalf("failed to read stats: %v", err)
+		}
+
+		if *jsonOutput {
+			if err := schema.Write(os.Stdout, stats); err != nil {
+				log.Fatalf("failed to write json: %v", err)
+			}
+			return
+		}
+
+		out := newOutput()
+		if !config.Session.Stats {
+			out.printf("Usage stats are not enabled for %s, set stats: true under session\n", stats.Session)
+		}
+		t := out.table("")
+		for _, window := range stats.Windows {
+			note := ""
+			if !window.InConfig {
+				note = out.style(styleYellow, "not in config")
+			}
+			t.row(out.style(styleBold, window.Name), strconv.Itoa(window.Selections), note)
+			for _, pane := range window.Panes {
+				t.row("  "+pane.Name, strconv.Itoa(pane.Selections))
+			}
+		}
+		t.flush()
+	}
+}
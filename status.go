@@ -0,0 +1,286 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/esaiaswestberg/gridlock/pkg/schema"
+)
+
+type liveWindow struct {
+	id    string
+	name  string
+	panes int
+	// Name of the configured window it was created for, empty if not created by gridlock
+	configName string
+}
+
+// configKey returns the name the configuration knows the window by
+func (w liveWindow) configKey() string {
+	if w.configName != "" {
+		return w.configName
+	}
+	return w.name
+}
+
+// liveWindows lists the windows of a running session
+func (t *TMUX) liveWindows(sessionName string) ([]liveWindow, error) {
+	// Both names may contain spaces, so the recorded one is preceded by its length
+	out, err := t.run("list-windows", "-t", sessionName, "-F", "#{window_id} #{window_panes} #{n:"+metadataWindowName+"} #{"+metadataWindowName+"}#{window_name}")
+	if err != nil {
+		return nil, err
+	}
+	var windows []liveWindow
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		parts := strings.SplitN(line, " ", 4)
+		if len(parts) < 4 {
+			continue
+		}
+		panes, _ := strconv.Atoi(parts[1])
+		n, err := strconv.Atoi(parts[2])
+		if err != nil || n > len(parts[3]) {
+			continue
+		}
+		windows = append(windows, liveWindow{id: parts[0], name: parts[3][n:], panes: panes, configName: parts[3][:n]})
+	}
+	return windows, nil
+}
+
+func (t *TMUX) sessionExists(sessionName string) bool {
+	_, err := t.run("has-session", "-t", sessionName)
+	return err == nil
+}
+
+// countLayoutPanes returns the number of panes a layout creates
+func countLayoutPanes(node LayoutNode) int {
+	if len(node.Columns) > 0 {
+		total := 0
+		for _, col := range node.Columns {
+			total += countLayoutPanes(col)
+		}
+		return total
+	}
+	if len(node.Rows) > 0 {
+		total := 0
+		for _, row := range node.Rows {
+			total += countLayoutPanes(row)
+		}
+		return total
+	}
+	return 1
+}
+
+func sessionStatus(t *TMUX, config *Config, configFile string) schema.Status {
+	status := schema.Status{
+		SchemaVersion: schema.Version,
+		Session:       config.Session.Name,
+		ConfigFile:    configFile,
+		Windows:       []schema.WindowStatus{},
+	}
+
+	live := make(map[string]liveWindow)
+	var liveOrder []string
+	if t.sessionExists(config.Session.Name) {
+		status.Running = true
+		if out, err := t.run("list-clients", "-t", config.Session.Name, "-F", "#{client_name}"); err == nil {
+			status.Clients = len(strings.Fields(out))
+		}
+		windows, _ := t.liveWindows(config.Session.Name)
+		for _, w := range windows {
+			live[w.configKey()] = w
+			liveOrder = append(liveOrder, w.configKey())
+		}
+	}
+
+	inConfig := make(map[string]bool)
+	for _, window := range config.Session.Windows {
+		inConfig[window.Name] = true
+		ws := schema.WindowStatus{
+			Name:        window.Name,
+			InConfig:    true,
+			ConfigPanes: countLayoutPanes(window.Layout),
+		}
+		if w, ok := live[window.Name]; ok {
+			ws.Running = true
+			ws.Panes = w.panes
+		}
+		status.Windows = append(status.Windows, ws)
+	}
+	for _, name := range liveOrder {
+		if !inConfig[name] {
+			status.Windows = append(status.Windows, schema.WindowStatus{Name: name, Running: true, Panes: live[name].panes})
+		}
+	}
+	return status
+}
+
+func sessionDiff(status schema.Status) schema.Diff {
+	diff := schema.Diff{
+		SchemaVersion: schema.Version,
+		Session:       status.Session,
+		ConfigFile:    status.ConfigFile,
+		Running:       status.Running,
+		Changes:       []schema.Change{},
+	}
+	for _, w := range status.Windows {
+		switch {
+		case w.InConfig && !w.Running:
+			diff.Changes = append(diff.Changes, schema.Change{Kind: schema.ChangeMissingWindow, Window: w.Name, Expected: w.ConfigPanes})
+		case !w.InConfig:
+			diff.Changes = append(diff.Changes, schema.Change{Kind: schema.ChangeExtraWindow, Window: w.Name, Actual: w.Panes})
+		case w.Panes != w.ConfigPanes:
+			diff.Changes = append(diff.Changes, schema.Change{Kind: schema.ChangePaneCount, Window: w.Name, Expected: w.ConfigPanes, Actual: w.Panes})
+		}
+	}
+	return diff
+}
+
+// statusCommand prints the live state of the configured session
+func statusCommand(fs *flag.FlagSet) func(args []string, opts upOptions) {
+	jsonOutput := fs.Bool("json", false, "Print the status as JSON")
+	return func(args []string, opts upOptions) {
+		config, err := loadConfig(opts.configFile)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		if err := prepareConfig(config); err != nil {
+			log.Fatalf("invalid config: %v", err)
+		}
+		query := newTMUX(opts, config)
+		query.DryRun = false
+		status := sessionStatus(query, config, opts.configFile)
+
+		if *jsonOutput {
+			if err := schema.Write(os.Stdout, status); err != nil {
+				log.Fatalf("failed to write json: %v", err)
+			}
+			return
+		}
+
+		out := newOutput()
+		state := out.style(styleDim, "not running")
+		if status.Running {
+			state = out.style(styleGreen, fmt.Sprintf("running, %d client(s) attached", status.Clients))
+		}
+		out.printf("Session: %s (%s)\n", out.style(styleBold, status.Session), state)
+		t := out.table("  ")
+		for _, window := range status.Windows {
+			switch {
+			case !window.InConfig:
+				t.row(window.Name, "running", fmt.Sprintf("%d panes", window.Panes), out.style(styleYellow, "not in config"))
+			case window.Running:
+				t.row(window.Name, "running", fmt.Sprintf("%d panes", window.Panes))
+			default:
+				t.row(window.Name, out.style(styleRed, "missing"))
+			}
+		}
+		t.flush()
+	}
+}
+
+// diffCommand prints the differences between the configuration and the live session
+func diffCommand(fs *flag.FlagSet) func(args []string, opts upOptions) {
+	jsonOutput := fs.Bool("json", false, "Print the differences as JSON")
+	return func(args []string, opts upOptions) {
+		config, err := loadConfig(opts.configFile)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		if err := prepareConfig(config); err != nil {
+			log.Fatalf("invalid config: %v", err)
+		}
+		query := newTMUX(opts, config)
+		query.DryRun = false
+		diff := sessionDiff(sessionStatus(query, config, opts.configFile))
+
+		if *jsonOutput {
+			if err := schema.Write(os.Stdout, diff); err != nil {
+				log.Fatalf("failed to write json: %v", err)
+			}
+			return
+		}
+
+		out := newOutput()
+		if !diff.Running {
+			out.printf("Session %s is not running\n", diff.Session)
+			return
+		}
+		if len(diff.Changes) == 0 {
+			out.printf("Session %s matches %s\n", diff.Session, diff.ConfigFile)
+			return
+		}
+		for _, change := range diff.Changes {
+			switch change.Kind {
+			case schema.ChangeMissingWindow:
+				out.printf("%s\n", out.style(styleGreen, fmt.Sprintf("+ window %s (missing from session)", change.Window)))
+			case schema.ChangeExtraWindow:
+				out.printf("%s\n", out.style(styleRed, fmt.Sprintf("- window %s (not in config)", change.Window)))
+			case schema.ChangePaneCount:
+				out.printf("%s\n", out.style(styleYellow, fmt.Sprintf("~ window %s: %d panes, config defines %d", change.Window, change.Actual, change.Expected)))
+			}
+		}
+	}
+}
+
+// projectsCommand lists known project directories and named projects and whether their
+// sessions are running
+func projectsCommand(fs *flag.FlagSet) func(args []string, opts upOptions) {
+	jsonOutput := fs.Bool("json", false, "Print the projects as JSON")
+	provider := fs.String("provider", os.Getenv("GRIDLOCK_PROVIDER"), "Command listing candidate directories (default \"zoxide query --list\")")
+	return func(args []string, opts upOptions) {
+		projects := schema.Projects{SchemaVersion: schema.Version, Projects: []schema.Project{}}
+		for _, dir := range projectCandidates(*provider) {
+			config, err := loadConfig(filepath.Join(dir, ".gridlock.yaml"))
+			if err != nil {
+				continue
+			}
+			query := newTMUX(opts, config)
+			query.DryRun = false
+			projects.Projects = append(projects.Projects, schema.Project{
+				Path:    dir,
+				Session: config.Session.Name,
+				Running: query.sessionExists(config.Session.Name),
+			})
+		}
+		for _, name := range namedProjects() {
+			path, err := namedProjectPath(name)
+			if err != nil {
+				continue
+			}
+			config, err := loadConfig(path)
+			if err != nil {
+				continue
+			}
+			query := newTMUX(opts, config)
+			query.DryRun = false
+			projects.Projects = append(projects.Projects, schema.Project{
+				Path:    path,
+				Session: config.Session.Name,
+				Running: query.sessionExists(config.Session.Name),
+			})
+		}
+
+		if *jsonOutput {
+			if err := schema.Write(os.Stdout, projects); err != nil {
+				log.Fatalf("failed to write json: %v", err)
+			}
+			return
+		}
+
+		out := newOutput()
+		t := out.table("")
+		for _, p := range projects.Projects {
+			state := ""
+			if p.Running {
+				state = out.style(styleGreen, "running")
+			}
+			t.row(out.style(styleBold, p.Session), p.Path, state)
+		}
+		t.flush()
+	}
+}
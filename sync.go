@@ -0,0 +1,294 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Name given to live windows that are being rebuilt, so the rebuilt ones can take their name
+const replacedWindowName = ".gridlock-replaced"
+
+// syncPlan is what gridlock apply changes in a running session
+type syncPlan struct {
+	// Configured windows to create, as they are missing or their panes differ
+	create map[int]bool
+	// Live window each created window is inserted after, to keep the configured order
+	after map[int]string
+	// Live windows killed once the windows replacing them exist, and those that are not
+	// in the configuration when pruning
+	kill []liveWindow
+	// Live windows that are kept as they are, by the index of their configured window
+	keep map[int]string
+}
+
+// layoutPaneNames returns the names of the panes a layout creates
+func layoutPaneNames(node LayoutNode) []string {
+	if node.PaneName != "" {
+		return []string{node.PaneName}
+	}
+	var names []string
+	for _, col := range node.Columns {
+		names = append(names, layoutPaneNames(col)...)
+	}
+	for _, row := range node.Rows {
+		names = append(names, layoutPaneNames(row)...)
+	}
+	return names
+}
+
+// livePaneNames returns the configured names recorded for the panes of a live window
+func (t *TMUX) livePaneNames(windowID string) ([]string, error) {
+	out, err := t.run("list-panes", "-t", windowID, "-F", "#{"+metadataPaneName+"}")
+	if err != nil {
+		return nil, err
+	}
+	// Panes gridlock did not create have an empty name, which must not be trimmed away
+	return strings.Split(strings.TrimSuffix(out, "\n"), "\n"), nil
+}
+
+func samePanes(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a = append([]string{}, a...)
+	b = append([]string{}, b...)
+	sort.Strings(a)
+	sort.Strings(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// planSync compares the running session with the configuration. Windows renamed in the
+// session get their configured name back right away; missing windows are left for up to
+// create, as is the window named by restart after its on-kill hooks ran, leaving the other
+// windows alone. Windows whose panes differ are only reported, as rebuilding them restarts
+// whatever runs in them, unless rebuild is set. query inspects the session even in dry-run
+// mode.
+func (t *TMUX) planSync(query *TMUX, sessionName string, config *Config, layouts []LayoutNode, prune bool, rebuild bool, restart string) (*syncPlan, error) {
+	windows, err := query.liveWindows(sessionName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list windows: %v", err)
+	}
+	live := make(map[string]liveWindow)
+	for _, w := range windows {
+		if _, ok := live[w.configKey()]; !ok {
+			live[w.configKey()] = w
+		}
+	}
+
+	out := newOutput()
+	plan := &syncPlan{create: map[int]bool{}, after: map[int]string{}, keep: map[int]string{}}
+	inConfig := make(map[string]bool)
+	previous := ""
+	for i, window := range config.Session.Windows {
+		inConfig[window.Name] = true
+		w, ok := live[window.Name]
+		if !ok && restart != "" && window.Name != restart {
+			continue
+		}
+		if !ok {
+			out.printf("%s\n", out.style(styleGreen, "Missing window: "+window.Name))
+			plan.create[i] = true
+			plan.after[i] = previous
+			continue
+		}
+		previous = w.id
+		if restart != "" && window.Name != restart {
+			continue
+		}
+		if window.Name == restart {
+			out.printf("%s\n", out.style(styleYellow, "Restarting window: "+window.Name))
+			if err := t.runWindowHook(hookOnKill, &config.Session, &config.Session.Windows[i], sessionName); err != nil {
+				log.Printf("Warning: window %s: %v", window.Name, err)
+			}
+			t.run("rename-window", "-t", w.id, replacedWindowName)
+			plan.create[i] = true
+			plan.after[i] = w.id
+			plan.kill = append(plan.kill, w)
+			continue
+		}
+		panes, err := query.livePaneNames(w.id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list panes of window %s: %v", w.name, err)
+		}
+		if configured := layoutPaneNames(layouts[i]); !samePanes(panes, configured) {
+			if !rebuild {
+				out.printf("%s (%s; rebuild it with --rebuild, which restarts its panes)\n", out.style(styleYellow, "Window differs from the configuration: "+window.Name), paneDifference(panes, configured))
+			} else {
+				out.printf("%s (%s)\n", out.style(styleYellow, "Rebuilding window: "+window.Name), paneDifference(panes, configured))
+				t.run("rename-window", "-t", w.id, replacedWindowName)
+				plan.create[i] = true
+				plan.after[i] = w.id
+				plan.kill = append(plan.kill, w)
+				continue
+			}
+		}
+		if w.name != window.Name {
+			out.printf("Renaming window: %s -> %s\n", w.name, window.Name)
+			t.run("rename-window", "-t", w.id, window.Name)
+		}
+		plan.keep[i] = w.id
+	}
+
+	for _, w := range windows {
+		if inConfig[w.configKey()] || restart != "" {
+			continue
+		}
+		if prune {
+			plan.kill = append(plan.kill, w)
+		} else {
+			out.printf("%s (kill it with --prune)\n", out.style(styleYellow, "Window not in configuration: "+w.name))
+		}
+	}
+	return plan, nil
+}
+
+// paneDifference describes how the panes of a live window differ from the configured ones
+func paneDifference(live, configured []string) string {
+	count := make(map[string]int)
+	for _, name := range live {
+		count[name]++
+	}
+	var missing, extra []string
+	for _, name := range configured {
+		if count[name] > 0 {
+			count[name]--
+		} else {
+			missing = append(missing, name)
+		}
+	}
+	unnamed := 0
+	for _, name := range live {
+		if count[name] == 0 {
+			continue
+		}
+		count[name]--
+		if name == "" {
+			unnamed++
+		} else {
+			extra = append(extra, name)
+		}
+	}
+
+	var parts []string
+	if len(missing) > 0 {
+		parts = append(parts, "missing panes: "+strings.Join(missing, ", "))
+	}
+	if len(extra) > 0 {
+		parts = append(parts, "panes not in the configuration: "+strings.Join(extra, ", "))
+	}
+	if unnamed > 0 {
+		parts = append(parts, fmt.Sprintf("%d pane(s) gridlock did not create", unnamed))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// planAppend plans adding the configured windows that are missing from the running session,
+// matched by name, in their configured place. Unlike planSync it leaves the windows the
+// session has alone, however much they differ from the configuration.
+func planAppend(query *TMUX, sessionName string, config *Config) (*syncPlan, error) {
+	windows, err := query.liveWindows(sessionName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list windows: %v", err)
+	}
+	live := make(map[string]liveWindow)
+	for _, w := range windows {
+		if _, ok := live[w.configKey()]; !ok {
+			live[w.configKey()] = w
+		}
+	}
+
+	out := newOutput()
+	plan := &syncPlan{create: map[int]bool{}, after: map[int]string{}, keep: map[int]string{}}
+	previous := ""
+	for i, window := range config.Session.Windows {
+		if w, ok := live[window.Name]; ok {
+			previous = w.id
+			plan.keep[i] = w.id
+			continue
+		}
+		out.printf("%s\n", out.style(styleGreen, "Missing window: "+window.Name))
+		plan.create[i] = true
+		plan.after[i] = previous
+	}
+	if len(plan.create) == 0 {
+		out.printf("No windows to append to session: %s\n", sessionName)
+	}
+	return plan, nil
+}
+
+// finishSync kills the windows that were rebuilt or pruned. The window we are running in
+// goes last so the rest of the run is not cut short.
+func (t *TMUX) finishSync(plan *syncPlan) {
+	out := newOutput()
+	currentWindowID := ""
+	if os.Getenv("TMUX") != "" {
+		if out, err := t.run("display-message", "-p", "#{window_id}"); err == nil {
+			currentWindowID = strings.TrimSpace(out)
+		}
+	}
+	for _, w := range plan.kill {
+		if w.id != currentWindowID {
+			out.printf("%s\n", out.style(styleRed, "Killing window: "+w.name))
+			t.run("kill-window", "-t", w.id)
+		}
+	}
+	for _, w := range plan.kill {
+		if w.id == currentWindowID {
+			out.printf("%s\n", out.style(styleRed, "Killing current window: "+w.name))
+			t.run("kill-window", "-t", w.id)
+		}
+	}
+}
+
+// applyCommand reconciles the running session with the configuration instead of only
+// attaching to it, and creates the session when it is not running
+func applyCommand(fs *flag.FlagSet) func(args []string, opts upOptions) {
+	prune := fs.Bool("prune", false, "Kill windows that are not in the configuration")
+	rebuild := fs.Bool("rebuild", false, "Rebuild windows whose panes differ from the configuration, restarting their panes")
+	return func(args []string, opts upOptions) {
+		opts.sync = true
+		opts.prune = *prune
+		opts.rebuild = *rebuild
+		up(opts)
+	}
+}
+
+// restartCommand kills and recreates the session, running its on-kill and creation hooks
+// like up --recreate. With --window only that window is rebuilt, in a running session.
+func restartCommand(fs *flag.FlagSet) func(args []string, opts upOptions) {
+	window := fs.String("window", "", "Only restart the panes of this window")
+	return func(args []string, opts upOptions) {
+		if *window == "" {
+			opts.recreate = true
+			up(opts)
+			return
+		}
+		config, err := loadConfig(opts.configFile)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		if err := prepareConfig(config); err != nil {
+			log.Fatalf("invalid config: %v", err)
+		}
+		if findWindow(config.Session.Windows, *window) == nil {
+			log.Fatalf("Window %s is not in the configuration", *window)
+		}
+		query := newTMUX(opts, config)
+		query.DryRun = false
+		if !query.sessionExists(config.Session.Name) {
+			log.Fatalf("Session %s is not running", config.Session.Name)
+		}
+		opts.sync = true
+		opts.restartWindow = *window
+		up(opts)
+	}
+}
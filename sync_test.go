@@ -0,0 +1,21 @@
+package main
+
+import "testing"
+
+func TestPaneDifference(t *testing.T) {
+	tests := []struct {
+		live, configured []string
+		want             string
+	}{
+		{[]string{"a", "b"}, []string{"a", "b", "c"}, "missing panes: c"},
+		{[]string{"a", "b", "c"}, []string{"a", "b"}, "panes not in the configuration: c"},
+		{[]string{"a", ""}, []string{"a", "b"}, "missing panes: b; 1 pane(s) gridlock did not create"},
+		{[]string{"a", "a"}, []string{"a", "b"}, "missing panes: b; panes not in the configuration: a"},
+		{[]string{"b", "a"}, []string{"a", "b"}, ""},
+	}
+	for _, tt := range tests {
+		if got := paneDifference(tt.live, tt.configured); got != tt.want {
+			t.Errorf("paneDifference(%q, %q) = %q, want %q", tt.live, tt.configured, got, tt.want)
+		}
+	}
+}
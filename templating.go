@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// varFlags collects the KEY=VALUE pairs of repeated --var flags
+type varFlags map[string]string
+
+func (v varFlags) String() string {
+	var pairs []string
+	for _, k := range envKeys(v) {
+		pairs = append(pairs, k+"="+v[k])
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (v varFlags) Set(s string) error {
+	key, value, ok := strings.Cut(s, "=")
+	if !ok || !templateVarName.MatchString(key) {
+		return fmt.Errorf("expected KEY=VALUE, got %q", s)
+	}
+	v[key] = value
+	return nil
+}
+
+// args returns the variables as flags for another gridlock process
+func (v varFlags) args() []string {
+	var args []string
+	for _, k := range envKeys(v) {
+		args = append(args, "--var", k+"="+v[k])
+	}
+	return args
+}
+
+// templateVars are the variables given with --var. They take precedence over the vars of
+// the configuration, which take precedence over the environment.
+var templateVars = varFlags{}
+
+var (
+	templateVarName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	templateVar     = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+)
+
+// expandTemplate replaces the ${NAME} references of the variables lookup knows. Others are
+// left for the shell the value may end up in.
+func expandTemplate(s string, lookup func(string) (string, bool)) string {
+	return templateVar.ReplaceAllStringFunc(s, func(ref string) string {
+		if value, ok := lookup(templateVar.FindStringSubmatch(ref)[1]); ok {
+			return value
+		}
+		return ref
+	})
+}
+
+// expandVars expands ${NAME} in every string value of the configuration, including those
+// of the local overlay and components. The vars of the configuration may themselves refer
+// to other vars, --var and environment variables.
+func expandVars(config *Config) error {
+	external := func(name string) (string, bool) {
+		if value, ok := templateVars[name]; ok {
+			return value, true
+		}
+		return os.LookupEnv(name)
+	}
+	vars := make(map[string]string, len(config.Vars))
+	for k, v := range config.Vars {
+		vars[k] = v
+	}
+	// An exported variable does not override a var the project set
+	lookup := func(name string) (string, bool) {
+		if value, ok := templateVars[name]; ok {
+			return value, true
+		}
+		if value, ok := vars[name]; ok {
+			return value, true
+		}
+		return os.LookupEnv(name)
+	}
+	// Vars may refer to each other, which takes at most as many rounds as there are vars. A var
+	// referring to itself, such as PATH: ${PATH}:bin, gets the value from --var or the environment.
+	for round := 0; ; round++ {
+		changed := false
+		for k, v := range vars {
+			self := func(name string) (string, bool) {
+				if name == k {
+					return external(name)
+				}
+				return lookup(name)
+			}
+			if expanded := expandTemplate(v, self); expanded != v {
+				vars[k] = expanded
+				changed = true
+			}
+		}
+		if !changed {
+			break
+		}
+		if round == len(vars) {
+			return fmt.Errorf("vars refer to each other in a cycle")
+		}
+	}
+
+	var node yaml.Node
+	if err := node.Encode(config); err != nil {
+		return err
+	}
+	if !expandNode(&node, lookup) {
+		return nil
+	}
+	var expanded Config
+	if err := node.Decode(&expanded); err != nil {
+		return fmt.Errorf("failed to expand variables: %v", err)
+	}
+	*config = expanded
+	return nil
+}
+
+// expandNode expands the scalar values below node, leaving mapping keys alone, and reports
+// whether it changed any
+func expandNode(node *yaml.Node, lookup func(string) (string, bool)) bool {
+	changed := false
+	switch node.Kind {
+	case yaml.ScalarNode:
+		if expanded := expandTemplate(node.Value, lookup); expanded != node.Value {
+			node.Value = expanded
+			changed = true
+		}
+	case yaml.MappingNode:
+		for i := 1; i < len(node.Content); i += 2 {
+			changed = expandNode(node.Content[i], lookup) || changed
+		}
+	default:
+		for _, child := range node.Content {
+			changed = expandNode(child, lookup) || changed
+		}
+	}
+	return changed
+}
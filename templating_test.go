@@ -0,0 +1,29 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExpandVarsPrecedence(t *testing.T) {
+	t.Setenv("STAGE", "prod")
+	t.Setenv("BRANCH", "main")
+	t.Setenv("BIN", "/usr/bin")
+	templateVars["BRANCH"] = "checkout-v2"
+	defer delete(templateVars, "BRANCH")
+
+	config := &Config{
+		Vars:    map[string]string{"STAGE": "dev", "BRANCH": "trunk", "BIN": "${BIN}:./bin", "DIR": "~/src/${BRANCH}-${STAGE}"},
+		Session: SessionConfig{Name: "${DIR}", Env: map[string]string{"PATH": "${BIN}", "HOME_STAGE": "${STAGE}"}},
+	}
+	if err := expandVars(config); err != nil {
+		t.Fatalf("expandVars failed: %v", err)
+	}
+	// --var comes first, then the vars of the configuration, then the environment
+	if want := "~/src/checkout-v2-dev"; config.Session.Name != want {
+		t.Errorf("name = %q, want %q", config.Session.Name, want)
+	}
+	if want := map[string]string{"PATH": "/usr/bin:./bin", "HOME_STAGE": "dev"}; !reflect.DeepEqual(config.Session.Env, want) {
+		t.Errorf("env = %q, want %q", config.Session.Env, want)
+	}
+}
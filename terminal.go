@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Default client width below which a window's layout-small is used
+const defaultSmallWidth = 120
+
+// clientSize returns the size of the terminal that will attach to the session.
+// Inside TMUX this is the current client, otherwise the terminal gridlock runs in.
+func (t *TMUX) clientSize(inTMUX bool) (width int, height int, ok bool) {
+	if inTMUX && !t.DryRun {
+		out, err := t.run("display-message", "-p", "#{client_width} #{client_height}")
+		if err == nil {
+			if w, h, ok := parseSize(out); ok {
+				return w, h, true
+			}
+		}
+	}
+
+	return terminalSize()
+}
+
+// terminalSize returns the size of the terminal gridlock runs in, from stty or else the
+// COLUMNS and LINES variables
+func terminalSize() (width int, height int, ok bool) {
+	cmd := exec.Command("stty", "size")
+	cmd.Stdin = os.Stdin
+	if out, err := cmd.Output(); err == nil {
+		// stty prints "rows cols"
+		if h, w, ok := parseSize(string(out)); ok {
+			return w, h, true
+		}
+	}
+
+	w, errW := strconv.Atoi(os.Getenv("COLUMNS"))
+	h, errH := strconv.Atoi(os.Getenv("LINES"))
+	if errW == nil && errH == nil && w > 0 && h > 0 {
+		return w, h, true
+	}
+	return 0, 0, false
+}
+
+func parseSize(s string) (int, int, bool) {
+	fields := strings.Fields(s)
+	if len(fields) != 2 {
+		return 0, 0, false
+	}
+	a, errA := strconv.Atoi(fields[0])
+	b, errB := strconv.Atoi(fields[1])
+	if errA != nil || errB != nil || a <= 0 || b <= 0 {
+		return 0, 0, false
+	}
+	return a, b, true
+}
+
+// isSmallClient reports whether the client is below the session's small-width/small-height thresholds
+func isSmallClient(session *SessionConfig, width, height int) bool {
+	smallWidth := session.SmallWidth
+	if smallWidth == 0 {
+		smallWidth = defaultSmallWidth
+	}
+	if width < smallWidth {
+		return true
+	}
+	return session.SmallHeight > 0 && height < session.SmallHeight
+}
+
+// Size of windows created without an attached client (tmux's default-size)
+const (
+	defaultWindowWidth  = 80
+	defaultWindowHeight = 24
+)
+
+// Usable minimum size of a pane, overridable with min-pane-width/min-pane-height
+const (
+	defaultMinPaneWidth  = 5
+	defaultMinPaneHeight = 2
+)
+
+// minLayoutSize returns the smallest window size in which every pane of the layout
+// gets at least the minimum pane size, counting the one-cell borders between panes
+func minLayoutSize(node LayoutNode, minWidth, minHeight int) (int, int) {
+	if len(node.Columns) > 0 {
+		width, height := len(node.Columns)-1, 0
+		for _, col := range node.Columns {
+			w, h := minLayoutSize(col, minWidth, minHeight)
+			width += w
+			height = max(height, h)
+		}
+		return width, height
+	}
+	if len(node.Rows) > 0 {
+		width, height := 0, len(node.Rows)-1
+		for _, row := range node.Rows {
+			w, h := minLayoutSize(row, minWidth, minHeight)
+			width = max(width, w)
+			height += h
+		}
+		return width, height
+	}
+	return minWidth, minHeight
+}
+
+// chooseLayouts picks the layout of every window for a window of the given size: layout-small
+// on small clients, or as a fallback when the regular layout does not fit. It fails with a
+// clear message before anything is created when a layout cannot fit at all.
+func chooseLayouts(session *SessionConfig, width, height int, small bool) ([]LayoutNode, error) {
+	minWidth := session.MinPaneWidth
+	if minWidth == 0 {
+		minWidth = defaultMinPaneWidth
+	}
+	minHeight := session.MinPaneHeight
+	if minHeight == 0 {
+		minHeight = defaultMinPaneHeight
+	}
+	// One row is taken by the status line
+	height--
+
+	fits := func(layout LayoutNode) bool {
+		w, h := minLayoutSize(layout, minWidth, minHeight)
+		return w <= width && h <= height
+	}
+
+	layouts := make([]LayoutNode, len(session.Windows))
+	for i, window := range session.Windows {
+		layout := window.Layout
+		if small && !window.LayoutSmall.IsZero() {
+			layout = window.LayoutSmall
+		}
+		if !fits(layout) {
+			if window.LayoutSmall.IsZero() || !fits(window.LayoutSmall) {
+				w, h := minLayoutSize(layout, minWidth, minHeight)
+				return nil, fmt.Errorf("window %s needs at least %dx%d cells but the terminal is %dx%d; use a larger terminal or give the window a layout-small that fits", window.Name, w, h+1, width, height+1)
+			}
+			fmt.Printf("Terminal too small for window %s, using its layout-small\n", window.Name)
+			layout = window.LayoutSmall
+		}
+		layouts[i] = layout
+	}
+	return layouts, nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/esaiaswestberg/gridlock/pkg/tmux"
+)
+
+// Commands of a tmux configuration that set session or window options
+var optionCommands = map[string]bool{
+	"set":               true,
+	"set-option":        true,
+	"setw":              true,
+	"set-window-option": true,
+}
+
+// scopeTMUXConfig targets the option commands of a tmux configuration fragment that do not
+// choose a scope themselves (-g, -s or -t) at the session. Everything else, such as key
+// bindings, keeps the server-wide scope tmux gives it.
+func scopeTMUXConfig(data string, sessionName string) string {
+	lines := strings.Split(data, "\n")
+	for i, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || !optionCommands[fields[0]] {
+			continue
+		}
+		scoped := false
+		for _, field := range fields[1:] {
+			if !strings.HasPrefix(field, "-") {
+				break
+			}
+			if strings.ContainsAny(field, "gst") {
+				scoped = true
+			}
+		}
+		if scoped {
+			continue
+		}
+		indent := line[:strings.Index(line, fields[0])]
+		rest := strings.TrimPrefix(strings.TrimSpace(line), fields[0])
+		lines[i] = indent + fields[0] + " -t " + tmux.Quote(sessionName) + rest
+	}
+	return strings.Join(lines, "\n")
+}
+
+// sourceSessionConfig loads a project-local tmux configuration fragment for the session.
+// Relative paths are resolved against the directory of the gridlock configuration.
+func (t *TMUX) sourceSessionConfig(sessionName string, configFile string, path string) error {
+	path = expandPath(path)
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(filepath.Dir(configFile), path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read tmux config: %v", err)
+	}
+
+	scoped, err := os.CreateTemp("", "gridlock-*.conf")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(scoped.Name())
+	if _, err := scoped.WriteString(scopeTMUXConfig(string(data), sessionName)); err != nil {
+		scoped.Close()
+		return err
+	}
+	scoped.Close()
+
+	if _, err := t.run("source-file", scoped.Name()); err != nil {
+		return fmt.Errorf("failed to source %s: %v", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Tool managers that can provide the tools of a pane
+const (
+	toolManagerMise = "mise"
+	toolManagerAsdf = "asdf"
+)
+
+// validateTools checks the tool manager of the session and the tools of its windows and panes
+func validateTools(config *Config) error {
+	switch config.Session.ToolManager {
+	case "", toolManagerMise, toolManagerAsdf:
+	default:
+		return fmt.Errorf("unknown tool-manager %q, expected mise or asdf", config.Session.ToolManager)
+	}
+	for _, window := range config.Session.Windows {
+		if err := validateToolVersions(window.Tools); err != nil {
+			return fmt.Errorf("window %s: %v", window.Name, err)
+		}
+		for _, pane := range window.Panes {
+			if err := validateToolVersions(pane.Tools); err != nil {
+				return fmt.Errorf("window %s: pane %s: %v", window.Name, pane.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+func validateToolVersions(tools map[string]string) error {
+	for _, name := range envKeys(tools) {
+		if strings.ContainsAny(name, " \t@") {
+			return fmt.Errorf("invalid tool name %q", name)
+		}
+		if tools[name] == "" || strings.ContainsAny(tools[name], " \t") {
+			return fmt.Errorf("invalid version %q of tool %s", tools[name], name)
+		}
+	}
+	return nil
+}
+
+// applyTools makes the panes run with the tools of their window and their own, the pane's
+// version of a tool taking precedence. With mise, the pane's shell is started through
+// mise exec. asdf reads the version of a tool from ASDF_<TOOL>_VERSION, which is what
+// asdf shell sets, so with asdf the variables are added to the pane's env. Either way the
+// commands typed into the pane run with the tools.
+func applyTools(config *Config) {
+	manager := firstNonEmpty(config.Session.ToolManager, toolManagerMise)
+	for i := range config.Session.Windows {
+		window := &config.Session.Windows[i]
+		for j := range window.Panes {
+			pane := &window.Panes[j]
+			tools := mergeEnv(window.Tools, pane.Tools)
+			if len(tools) == 0 {
+				continue
+			}
+			if manager == toolManagerAsdf {
+				versions := make(map[string]string, len(tools))
+				for name, version := range tools {
+					versions["ASDF_"+strings.ToUpper(strings.ReplaceAll(name, "-", "_"))+"_VERSION"] = version
+				}
+				pane.Env = mergeEnv(versions, pane.Env)
+				continue
+			}
+			args := []string{"mise", "exec"}
+			for _, name := range envKeys(tools) {
+				args = append(args, shellQuote(name+"@"+tools[name]))
+			}
+			args = append(args, "--", firstNonEmpty(pane.Shell, `"${SHELL:-sh}"`))
+			pane.Shell = strings.Join(args, " ")
+		}
+	}
+}
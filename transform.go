@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Layout transforms
+const (
+	// Reverse the order of columns, swapping left and right
+	transformMirrorHorizontal = "mirror-horizontal"
+	// Reverse the order of rows, swapping top and bottom
+	transformMirrorVertical = "mirror-vertical"
+	// Turn columns into rows and rows into columns
+	transformRotate = "rotate"
+)
+
+// transformLayout applies a comma separated list of transforms to a layout, in order
+func transformLayout(node LayoutNode, transforms string) (LayoutNode, error) {
+	for _, transform := range strings.Split(transforms, ",") {
+		switch strings.TrimSpace(transform) {
+		case "":
+		case transformMirrorHorizontal:
+			node = mirrorLayout(node, true)
+		case transformMirrorVertical:
+			node = mirrorLayout(node, false)
+		case transformRotate:
+			node = rotateLayout(node)
+		default:
+			return node, fmt.Errorf("unknown transform %q, expected %s, %s or %s", transform, transformMirrorHorizontal, transformMirrorVertical, transformRotate)
+		}
+	}
+	return node, nil
+}
+
+// mirrorLayout reverses the columns (horizontal) or rows of every node in the tree
+func mirrorLayout(node LayoutNode, horizontal bool) LayoutNode {
+	mirrored := LayoutNode{PaneName: node.PaneName, Size: node.Size, Preset: node.Preset}
+	for _, col := range node.Columns {
+		mirrored.Columns = append(mirrored.Columns, mirrorLayout(col, horizontal))
+	}
+	for _, row := range node.Rows {
+		mirrored.Rows = append(mirrored.Rows, mirrorLayout(row, horizontal))
+	}
+	if horizontal {
+		reverseNodes(mirrored.Columns)
+	} else {
+		reverseNodes(mirrored.Rows)
+	}
+	return mirrored
+}
+
+// rotateLayout swaps columns and rows in every node of the tree
+func rotateLayout(node LayoutNode) LayoutNode {
+	rotated := LayoutNode{PaneName: node.PaneName, Size: node.Size, Preset: node.Preset}
+	for _, col := range node.Columns {
+		rotated.Rows = append(rotated.Rows, rotateLayout(col))
+	}
+	for _, row := range node.Rows {
+		rotated.Columns = append(rotated.Columns, rotateLayout(row))
+	}
+	return rotated
+}
+
+func reverseNodes(nodes []LayoutNode) {
+	for i, j := 0, len(nodes)-1; i < j; i, j = i+1, j-1 {
+		nodes[i], nodes[j] = nodes[j], nodes[i]
+	}
+}
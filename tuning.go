@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// validateTuning checks the typed tmux options of the session
+func validateTuning(session *SessionConfig) error {
+	if session.HistoryLimit < 0 {
+		return fmt.Errorf("history-limit must not be negative, got %d", session.HistoryLimit)
+	}
+	if session.EscapeTime != nil && *session.EscapeTime < 0 {
+		return fmt.Errorf("escape-time must not be negative, got %d", *session.EscapeTime)
+	}
+	if session.PaneBaseIndex != nil && *session.PaneBaseIndex < 0 {
+		return fmt.Errorf("pane-base-index must not be negative, got %d", *session.PaneBaseIndex)
+	}
+	if _, err := collapseAfter(session); err != nil {
+		return fmt.Errorf("invalid collapse-after: %v", err)
+	}
+	return nil
+}
+
+// withHistoryLimit wraps the new-session command so its first pane gets the session's
+// history limit. tmux fixes the limit of a pane when creating it, so setting the session
+// option afterwards would only reach the panes split from it; instead the global limit is
+// raised for the duration of the command and restored in the same tmux invocation.
+func (t *TMUX) withHistoryLimit(limit int, newSessionArgs []string) []string {
+	out, err := t.run("show-options", "-gv", "history-limit")
+	global := strings.TrimSpace(out)
+	if err != nil || global == "" {
+		return newSessionArgs
+	}
+	args := []string{"set-option", "-g", "history-limit", strconv.Itoa(limit), ";"}
+	args = append(args, newSessionArgs...)
+	return append(args, ";", "set-option", "-g", "history-limit", global)
+}
+
+// applySessionTuning sets the typed tmux options of a session created by gridlock.
+// escape-time is a server option, so it affects every session on the server.
+func (t *TMUX) applySessionTuning(sessionName string, session *SessionConfig) {
+	if session.Prefix != "" {
+		t.run("set-option", "-t", sessionName, "prefix", session.Prefix)
+	}
+	if session.KeyTable != "" {
+		t.run("set-option", "-t", sessionName, "key-table", session.KeyTable)
+	}
+	if session.HistoryLimit > 0 {
+		t.run("set-option", "-t", sessionName, "history-limit", strconv.Itoa(session.HistoryLimit))
+	}
+	if session.EscapeTime != nil {
+		t.run("set-option", "-s", "escape-time", strconv.Itoa(*session.EscapeTime))
+	}
+}
+
+// applySessionOptions sets the tmux session options of the session's options map, e.g.
+// status-style or mouse. Windows created with the session are renumbered for base-index.
+func (t *TMUX) applySessionOptions(sessionName string, session *SessionConfig) {
+	for _, name := range envKeys(session.Options) {
+		if _, err := t.run("set-option", "-t", sessionName, name, session.Options[name]); err != nil {
+			log.Printf("Warning: failed to set session option %s: %v", name, err)
+		}
+	}
+	if _, ok := session.Options["base-index"]; ok {
+		t.run("move-window", "-r", "-t", sessionName)
+	}
+}
+
+// applyWindowTuning sets the typed tmux options that are window options in tmux
+func (t *TMUX) applyWindowTuning(windowTarget string, session *SessionConfig) {
+	if session.AggressiveResize != nil {
+		t.run("set-option", "-w", "-t", windowTarget, "aggressive-resize", onOff(*session.AggressiveResize))
+	}
+	if session.PaneBaseIndex != nil {
+		t.run("set-option", "-w", "-t", windowTarget, "pane-base-index", strconv.Itoa(*session.PaneBaseIndex))
+	}
+}
+
+// applyWindowOptions sets the tmux window options of a window's options map, e.g.
+// synchronize-panes. They are set once the panes exist and got their commands, as typing
+// into synchronized panes would send every command to all of them.
+func (t *TMUX) applyWindowOptions(windowTarget string, window *WindowConfig) {
+	for _, name := range envKeys(window.Options) {
+		if _, err := t.run("set-option", "-w", "-t", windowTarget, name, window.Options[name]); err != nil {
+			log.Printf("Warning: window %s: failed to set %s: %v", window.Name, name, err)
+		}
+	}
+}
+
+// syncOptions brings the options of a running session, and of the windows apply keeps, in
+// line with the configuration. Windows apply creates get their options when they are
+// created. Only options whose value differs are set, and they are reported. query reads
+// the current values even in dry-run mode.
+func (t *TMUX) syncOptions(query *TMUX, sessionName string, config *Config, plan *syncPlan) {
+	session := &config.Session
+	sessionScope := []string{"-t", sessionName}
+	if session.HistoryLimit > 0 {
+		t.setOptionIfChanged(query, sessionName, sessionScope, "history-limit", strconv.Itoa(session.HistoryLimit))
+	}
+	if session.Prefix != "" {
+		t.setOptionIfChanged(query, sessionName, sessionScope, "prefix", session.Prefix)
+	}
+	if session.KeyTable != "" {
+		t.setOptionIfChanged(query, sessionName, sessionScope, "key-table", session.KeyTable)
+	}
+	if session.EscapeTime != nil {
+		t.setOptionIfChanged(query, "server", []string{"-s"}, "escape-time", strconv.Itoa(*session.EscapeTime))
+	}
+	for _, name := range envKeys(session.Options) {
+		t.setOptionIfChanged(query, sessionName, sessionScope, name, session.Options[name])
+	}
+
+	for i := range session.Windows {
+		windowID, ok := plan.keep[i]
+		if !ok {
+			continue
+		}
+		window := &session.Windows[i]
+		windowScope := []string{"-w", "-t", windowID}
+		if session.AggressiveResize != nil {
+			t.setOptionIfChanged(query, window.Name, windowScope, "aggressive-resize", onOff(*session.AggressiveResize))
+		}
+		if session.PaneBaseIndex != nil {
+			t.setOptionIfChanged(query, window.Name, windowScope, "pane-base-index", strconv.Itoa(*session.PaneBaseIndex))
+		}
+		for _, name := range envKeys(window.Options) {
+			t.setOptionIfChanged(query, window.Name, windowScope, name, window.Options[name])
+		}
+	}
+}
+
+// setOptionIfChanged sets an option in the scope, given as the set-option flags selecting
+// it, unless it already has the value. target names the scope in the report. Values are
+// compared as tmux prints them, including values inherited from the global options.
+func (t *TMUX) setOptionIfChanged(query *TMUX, target string, scope []string, name string, value string) {
+	out, err := query.run(append(append([]string{"show-options", "-A", "-v"}, scope...), name)...)
+	current := strings.TrimSpace(out)
+	if err == nil && current == value {
+		return
+	}
+	if _, err := t.run(append(append([]string{"set-option"}, scope...), name, value)...); err != nil {
+		log.Printf("Warning: %s: failed to set %s: %v", target, name, err)
+		return
+	}
+	fmt.Printf("Setting option: %s %s: %q -> %q\n", target, name, current, value)
+}
+
+// paneBaseIndex returns the index the panes of the session's windows are numbered from:
+// the configured pane-base-index, or else the global one of the server, which tmux.conf
+// commonly sets to 1
+func (t *TMUX) paneBaseIndex(session *SessionConfig) int {
+	if session.PaneBaseIndex != nil {
+		return *session.PaneBaseIndex
+	}
+	out, err := t.run("show-options", "-gv", "pane-base-index")
+	if err != nil {
+		return 0
+	}
+	base, _ := strconv.Atoi(strings.TrimSpace(out))
+	return base
+}
+
+func onOff(b bool) string {
+	if b {
+		return "on"
+	}
+	return "off"
+}
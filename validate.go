@@ -0,0 +1,218 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// validateCommand checks that the configuration loads and resolves, and optionally compares
+// it with another configuration
+func validateCommand(fs *flag.FlagSet) func(args []string, opts upOptions) {
+	against := fs.String("against", "", "Print the structural differences to another configuration file")
+	return func(args []string, opts upOptions) {
+		config := loadValidConfig(opts.configFile)
+		if *against == "" {
+			fmt.Printf("%s is valid\n", opts.configFile)
+			return
+		}
+		other := loadValidConfig(*against)
+		changes := configDiff(config, other)
+		if len(changes) == 0 {
+			fmt.Printf("%s and %s are equivalent\n", opts.configFile, *against)
+			return
+		}
+		for _, change := range changes {
+			fmt.Println(change)
+		}
+	}
+}
+
+// loadValidConfig loads a configuration and exits when it is invalid. The returned
+// configuration is the one written in the file, not its resolved form.
+func loadValidConfig(path string) *Config {
+	config, err := loadConfig(path)
+	if err != nil {
+		log.Fatalf("%s: %v", path, err)
+	}
+	prepared, err := loadConfig(path)
+	if err != nil {
+		log.Fatalf("%s: %v", path, err)
+	}
+	if err := prepareConfig(prepared); err != nil {
+		log.Fatalf("%s: invalid config: %v", path, err)
+	}
+	return config
+}
+
+// configDiff compares two configurations semantically: windows are matched by name, and
+// panes by name within their window, so reordering or inserting entries does not make
+// everything after them differ. Changes are reported from the first to the second.
+func configDiff(from, to *Config) []string {
+	var changes []string
+	fromSession, toSession := from.Session, to.Session
+	fromSession.Windows, toSession.Windows = nil, nil
+	changes = append(changes, fieldChanges("session", fromSession, toSession)...)
+	changes = append(changes, listChanges("project", from.Projects, to.Projects)...)
+
+	fromWindows := map[string]WindowConfig{}
+	var fromNames, toNames []string
+	for _, window := range from.Session.Windows {
+		fromWindows[window.Name] = window
+		fromNames = append(fromNames, window.Name)
+	}
+	toWindows := map[string]bool{}
+	for _, window := range to.Session.Windows {
+		toWindows[window.Name] = true
+		toNames = append(toNames, window.Name)
+	}
+	for _, window := range from.Session.Windows {
+		if !toWindows[window.Name] {
+			changes = append(changes, fmt.Sprintf("- window %s", window.Name))
+		}
+	}
+	for _, window := range to.Session.Windows {
+		fromWindow, ok := fromWindows[window.Name]
+		if !ok {
+			changes = append(changes, fmt.Sprintf("+ window %s", window.Name))
+			continue
+		}
+		changes = append(changes, windowChanges(fromWindow, window)...)
+	}
+	return append(changes, orderChanges("window order", fromNames, toNames)...)
+}
+
+// windowChanges compares two windows of the same name
+func windowChanges(from, to WindowConfig) []string {
+	path := "window " + from.Name
+	fromPanes, toPanes := from.Panes, to.Panes
+	from.Panes, to.Panes = nil, nil
+	changes := fieldChanges(path, from, to)
+
+	byName := map[string]PaneConfig{}
+	var fromNames, toNames []string
+	for _, pane := range fromPanes {
+		byName[pane.Name] = pane
+		fromNames = append(fromNames, pane.Name)
+	}
+	inTo := map[string]bool{}
+	for _, pane := range toPanes {
+		inTo[pane.Name] = true
+		toNames = append(toNames, pane.Name)
+	}
+	for _, pane := range fromPanes {
+		if !inTo[pane.Name] {
+			changes = append(changes, fmt.Sprintf("- pane %s/%s", from.Name, pane.Name))
+		}
+	}
+	for _, pane := range toPanes {
+		fromPane, ok := byName[pane.Name]
+		if !ok {
+			changes = append(changes, fmt.Sprintf("+ pane %s/%s", from.Name, pane.Name))
+			continue
+		}
+		changes = append(changes, fieldChanges("pane "+from.Name+"/"+pane.Name, fromPane, pane)...)
+	}
+	// The order of panes decides where grids place them
+	return append(changes, orderChanges(path+" pane order", fromNames, toNames)...)
+}
+
+// orderChanges reports when the entries present in both lists are in a different order
+func orderChanges(what string, from, to []string) []string {
+	inFrom, inTo := map[string]bool{}, map[string]bool{}
+	for _, name := range from {
+		inFrom[name] = true
+	}
+	for _, name := range to {
+		inTo[name] = true
+	}
+	var fromOrder, toOrder []string
+	for _, name := range from {
+		if inTo[name] {
+			fromOrder = append(fromOrder, name)
+		}
+	}
+	for _, name := range to {
+		if inFrom[name] {
+			toOrder = append(toOrder, name)
+		}
+	}
+	if strings.Join(fromOrder, "\x00") == strings.Join(toOrder, "\x00") {
+		return nil
+	}
+	return []string{fmt.Sprintf("~ %s: %s -> %s", what, strings.Join(fromOrder, ", "), strings.Join(toOrder, ", "))}
+}
+
+// listChanges compares two lists of strings as sets
+func listChanges(kind string, from, to []string) []string {
+	var changes []string
+	for _, change := range []struct {
+		sign       string
+		list, base []string
+	}{{"-", from, to}, {"+", to, from}} {
+		present := map[string]bool{}
+		for _, item := range change.base {
+			present[item] = true
+		}
+		for _, item := range change.list {
+			if !present[item] {
+				changes = append(changes, fmt.Sprintf("%s %s %s", change.sign, kind, item))
+			}
+		}
+	}
+	return changes
+}
+
+// fieldChanges compares the fields of two values by their YAML keys
+func fieldChanges(path string, from, to interface{}) []string {
+	fromFields, toFields := yamlFields(from), yamlFields(to)
+	keys := map[string]bool{}
+	for key := range fromFields {
+		keys[key] = true
+	}
+	for key := range toFields {
+		keys[key] = true
+	}
+	var sorted []string
+	for key := range keys {
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
+
+	var changes []string
+	for _, key := range sorted {
+		fromValue, toValue := formatValue(fromFields[key]), formatValue(toFields[key])
+		if fromValue != toValue {
+			changes = append(changes, fmt.Sprintf("~ %s %s: %s -> %s", path, key, fromValue, toValue))
+		}
+	}
+	return changes
+}
+
+// yamlFields returns the fields of a value as they are written in a configuration file
+func yamlFields(v interface{}) map[string]interface{} {
+	fields := map[string]interface{}{}
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		return fields
+	}
+	yaml.Unmarshal(data, &fields)
+	return fields
+}
+
+// formatValue renders a field value on a single line
+func formatValue(v interface{}) string {
+	if v == nil {
+		return "(unset)"
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Interval between two rounds of readiness checks
+const readinessPollInterval = 500 * time.Millisecond
+
+// readinessCheck is a pane of the session that has a wait-for or verify check
+type readinessCheck struct {
+	paneID  string
+	name    string
+	pane    *PaneConfig
+	waitFor *regexp.Regexp
+	// Last reason the check did not pass
+	reason string
+}
+
+// compileReadinessChecks validates the wait-for expressions of all panes
+func compileReadinessChecks(config *Config) error {
+	for _, window := range config.Session.Windows {
+		for _, pane := range window.Panes {
+			if pane.WaitFor == "" {
+				continue
+			}
+			if _, err := regexp.Compile(pane.WaitFor); err != nil {
+				return fmt.Errorf("window %s: pane %s: invalid wait-for: %v", window.Name, pane.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// readinessChecks finds the live panes of the session that have checks configured
+func (t *TMUX) readinessChecks(sessionName string, config *Config) ([]*readinessCheck, error) {
+	// The window name may contain spaces, so it is preceded by its length
+	out, err := t.run("list-panes", "-s", "-t", sessionName, "-F", "#{pane_id} #{n:"+metadataWindowName+"} #{"+metadataWindowName+"}#{"+metadataPaneName+"}")
+	if err != nil {
+		return nil, err
+	}
+	var checks []*readinessCheck
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		parts := strings.SplitN(line, " ", 3)
+		if len(parts) < 3 {
+			continue
+		}
+		n, err := strconv.Atoi(parts[1])
+		if err != nil || n > len(parts[2]) {
+			continue
+		}
+		windowName, paneName := parts[2][:n], parts[2][n:]
+		window := findWindow(config.Session.Windows, windowName)
+		if window == nil {
+			continue
+		}
+		pane := findPane(window, paneName)
+		if pane == nil || (pane.WaitFor == "" && pane.Verify == "") {
+			continue
+		}
+		check := &readinessCheck{paneID: parts[0], name: windowName + "/" + paneName, pane: pane}
+		if pane.WaitFor != "" {
+			check.waitFor = regexp.MustCompile(pane.WaitFor)
+		}
+		checks = append(checks, check)
+	}
+	return checks, nil
+}
+
+// ready runs the checks of a pane once
+func (t *TMUX) ready(ctx context.Context, check *readinessCheck) bool {
+	if check.waitFor != nil {
+		out, err := t.run("capture-pane", "-p", "-J", "-S", "-", "-t", check.paneID)
+		if err != nil {
+			check.reason = err.Error()
+			return false
+		}
+		if !check.waitFor.MatchString(out) {
+			check.reason = fmt.Sprintf("output does not match %q", check.pane.WaitFor)
+			return false
+		}
+	}
+	if check.pane.Verify != "" {
+		cmd := exec.CommandContext(ctx, "sh", "-c", check.pane.Verify)
+		if out, err := t.run("display-message", "-p", "-t", check.paneID, "#{pane_current_path}"); err == nil {
+			cmd.Dir = strings.TrimSpace(out)
+		}
+		if out, err := cmd.CombinedOutput(); err != nil {
+			check.reason = fmt.Sprintf("verify %q failed: %v", check.pane.Verify, err)
+			if output := lastLine(string(out)); output != "" {
+				check.reason += ": " + output
+			}
+			return false
+		}
+	}
+	return true
+}
+
+// waitForPanes blocks until the readiness checks of all panes pass, or fails once the
+// timeout expires, naming the panes that are not ready
+func (t *TMUX) waitForPanes(sessionName string, config *Config, timeout time.Duration) error {
+	if t.DryRun {
+		fmt.Printf("# wait up to %s for the readiness checks of %s\n", timeout, sessionName)
+		return nil
+	}
+	checks, err := t.readinessChecks(sessionName, config)
+	if err != nil {
+		return fmt.Errorf("failed to list panes: %v", err)
+	}
+	if len(checks) == 0 {
+		return nil
+	}
+
+	fmt.Printf("Waiting for %d panes to be ready\n", len(checks))
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	for {
+		var pending []*readinessCheck
+		for _, check := range checks {
+			if !t.ready(ctx, check) {
+				pending = append(pending, check)
+			}
+		}
+		checks = pending
+		if len(checks) == 0 {
+			fmt.Println("All panes are ready")
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			var reasons []string
+			for _, check := range checks {
+				reasons = append(reasons, fmt.Sprintf("  %s: %s", check.name, check.reason))
+			}
+			return fmt.Errorf("panes not ready after %s:\n%s", timeout, strings.Join(reasons, "\n"))
+		case <-time.After(readinessPollInterval):
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// recordedDirs returns the -c of every split-window and respawn-pane the fake tmux recorded,
+// as "split <dir>" and "respawn <target> <dir>"
+func recordedDirs(commands []string) []string {
+	var dirs []string
+	for _, command := range commands {
+		args := strings.Fields(command)[1:]
+		dir := ""
+		for i := range args {
+			if args[i] == "-c" && i+1 < len(args) {
+				dir = args[i+1]
+			}
+		}
+		switch args[0] {
+		case "split-window":
+			dirs = append(dirs, "split "+dir)
+		case "respawn-pane":
+			dirs = append(dirs, "respawn "+args[3]+" "+dir)
+		}
+	}
+	return dirs
+}
+
+func TestApplyLayoutWorkingDirectories(t *testing.T) {
+	tests := []struct {
+		name       string
+		layout     LayoutNode
+		panes      []PaneConfig
+		windowDir  string
+		sessionDir string
+		want       []string
+	}{
+		{
+			name:   "columns with their own directories",
+			layout: LayoutNode{Columns: []LayoutNode{{PaneName: "editor"}, {PaneName: "server"}, {PaneName: "logs"}}},
+			panes: []PaneConfig{
+				{Name: "editor", WorkingDirectory: "/src"},
+				{Name: "server", WorkingDirectory: "/srv"},
+				{Name: "logs", WorkingDirectory: "/var/log"},
+			},
+			want: []string{"split /srv", "split /var/log"},
+		},
+		{
+			name: "rows nested in a column start in their own directories",
+			layout: LayoutNode{Columns: []LayoutNode{
+				{PaneName: "editor"},
+				{Rows: []LayoutNode{{PaneName: "server"}, {PaneName: "logs"}}},
+			}},
+			panes: []PaneConfig{
+				{Name: "editor", WorkingDirectory: "/src"},
+				{Name: "server", WorkingDirectory: "/srv"},
+				{Name: "logs", WorkingDirectory: "/var/log"},
+			},
+			// The column is split off in the directory of its first pane, server
+			want: []string{"split /srv", "split /var/log"},
+		},
+		{
+			name: "columns nested in rows start in their own directories",
+			layout: LayoutNode{Rows: []LayoutNode{
+				{Columns: []LayoutNode{{PaneName: "editor"}, {PaneName: "server"}}},
+				{Columns: []LayoutNode{{PaneName: "logs"}, {PaneName: "shell"}}},
+			}},
+			panes: []PaneConfig{
+				{Name: "editor", WorkingDirectory: "/src"},
+				{Name: "server", WorkingDirectory: "/srv"},
+				{Name: "logs", WorkingDirectory: "/var/log"},
+				{Name: "shell", WorkingDirectory: "/tmp"},
+			},
+			// The second row is split off in the directory of logs, its first pane; shell is
+			// then split off logs in its own directory
+			want: []string{"split /var/log", "split /srv", "split /tmp"},
+		},
+		{
+			name: "panes without a directory fall back to the window's",
+			layout: LayoutNode{Rows: []LayoutNode{
+				{PaneName: "editor"},
+				{Columns: []LayoutNode{{PaneName: "server"}, {PaneName: "logs"}}},
+			}},
+			panes: []PaneConfig{
+				{Name: "editor", WorkingDirectory: "/src"},
+				{Name: "server"},
+				{Name: "logs", WorkingDirectory: "/var/log"},
+			},
+			windowDir:  "/app",
+			sessionDir: "/home",
+			want:       []string{"split /app", "split /var/log"},
+		},
+		{
+			name: "panes without a directory in a window without one fall back to the session's",
+			layout: LayoutNode{Columns: []LayoutNode{
+				{Rows: []LayoutNode{{PaneName: "editor"}, {PaneName: "server"}}},
+				{PaneName: "logs"},
+			}},
+			panes: []PaneConfig{
+				{Name: "editor", WorkingDirectory: "/src"},
+				{Name: "server"},
+				{Name: "logs"},
+			},
+			sessionDir: "/home",
+			want:       []string{"split /home", "split /home"},
+		},
+		{
+			name: "without directories nothing is passed",
+			layout: LayoutNode{Columns: []LayoutNode{
+				{PaneName: "editor"},
+				{Rows: []LayoutNode{{PaneName: "server"}, {PaneName: "logs"}}},
+			}},
+			panes: []PaneConfig{{Name: "editor"}, {Name: "server"}, {Name: "logs"}},
+			want:  []string{"split ", "split "},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeTMUX{}
+			tmux := newTMUX(upOptions{executor: fake.exec}, nil)
+			window := &WindowConfig{Name: "w", WorkingDirectory: tt.windowDir, Panes: tt.panes}
+			startDir := getWorkDirForNode(&tt.layout, window, tt.sessionDir)
+
+			tmux.applyLayout("s:w", 0, tt.layout, window, tt.sessionDir, startDir)
+
+			var splits []string
+			for _, dir := range recordedDirs(fake.commands) {
+				if strings.HasPrefix(dir, "split ") {
+					splits = append(splits, dir)
+				}
+			}
+			if !reflect.DeepEqual(splits, tt.want) {
+				t.Errorf("splits = %q, want %q\ncommands:\n%s", splits, tt.want, strings.Join(fake.commands, "\n"))
+			}
+		})
+	}
+}
+
+func TestApplyLayoutRespawnsFirstPaneInItsOwnDirectory(t *testing.T) {
+	// The window is created in its own directory, which the first pane does not share
+	layout := LayoutNode{Columns: []LayoutNode{
+		{Rows: []LayoutNode{{PaneName: "editor"}, {PaneName: "server"}}},
+		{PaneName: "logs"},
+	}}
+	window := &WindowConfig{Name: "w", WorkingDirectory: "/app", Panes: []PaneConfig{
+		{Name: "editor", WorkingDirectory: "/src"},
+		{Name: "server"},
+		{Name: "logs", WorkingDirectory: "/var/log"},
+	}}
+	fake := &fakeTMUX{}
+	tmux := newTMUX(upOptions{executor: fake.exec}, nil)
+	tmux.applyLayout("s:w", 0, layout, window, "", "/app")
+
+	want := []string{"split /var/log", "split /app", "respawn s:w.0 /src"}
+	if got := recordedDirs(fake.commands); !reflect.DeepEqual(got, want) {
+		t.Errorf("commands = %q, want %q\ncommands:\n%s", got, want, strings.Join(fake.commands, "\n"))
+	}
+}
+
+func TestGetWorkDirForNode(t *testing.T) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Skip("no home directory")
+	}
+	window := &WindowConfig{Name: "w", Panes: []PaneConfig{
+		{Name: "editor", WorkingDirectory: "/src"},
+		{Name: "server"},
+		{Name: "notes", WorkingDirectory: "~/notes"},
+	}}
+	tests := []struct {
+		name       string
+		node       LayoutNode
+		windowDir  string
+		sessionDir string
+		want       string
+	}{
+		{"pane directory", LayoutNode{PaneName: "editor"}, "/app", "/home", "/src"},
+		{"window directory", LayoutNode{PaneName: "server"}, "/app", "/home", "/app"},
+		{"session directory", LayoutNode{PaneName: "server"}, "", "/home", "/home"},
+		{"expanded home", LayoutNode{PaneName: "notes"}, "", "", filepath.Join(home, "notes")},
+		{"first pane of columns", LayoutNode{Columns: []LayoutNode{{PaneName: "editor"}, {PaneName: "server"}}}, "/app", "", "/src"},
+		{"first pane of nested rows", LayoutNode{Columns: []LayoutNode{
+			{Rows: []LayoutNode{{PaneName: "server"}, {PaneName: "editor"}}},
+			{PaneName: "editor"},
+		}}, "/app", "", "/app"},
+		{"empty node", LayoutNode{}, "/app", "/home", "/home"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := *window
+			w.WorkingDirectory = tt.windowDir
+			if got := getWorkDirForNode(&tt.node, &w, tt.sessionDir); got != tt.want {
+				t.Errorf("getWorkDirForNode() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// resolveProjectConfig returns the configuration file of a workspace project entry,
+// which may name a directory containing .gridlock.yaml or a configuration file.
+// Relative entries are resolved against the workspace directory.
+func resolveProjectConfig(workspaceDir string, entry string) string {
+	path := expandPath(entry)
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(workspaceDir, path)
+	}
+	if isDir(path) {
+		return resolveConfigPath(filepath.Join(path, ".gridlock.yaml"))
+	}
+	return path
+}
+
+// upWorkspace brings up every project referenced by a workspace configuration,
+// then attaches to the first one unless detached
+func upWorkspace(config *Config, opts upOptions) {
+	workspaceDir, err := filepath.Abs(filepath.Dir(opts.configFile))
+	if err != nil {
+		log.Fatalf("failed to resolve workspace directory: %v", err)
+	}
+
+	var configFiles []string
+	for _, entry := range config.Projects {
+		configFiles = append(configFiles, resolveProjectConfig(workspaceDir, entry))
+	}
+
+	for _, configFile := range configFiles {
+		fmt.Printf("Bringing up project: %s\n", configFile)
+		if err := os.Chdir(filepath.Dir(configFile)); err != nil {
+			log.Fatalf("failed to change directory: %v", err)
+		}
+		projectOpts := opts
+		projectOpts.configFile = configFile
+		projectOpts.detached = true
+		up(projectOpts)
+	}
+
+	if !opts.detached && len(configFiles) > 0 {
+		if err := os.Chdir(filepath.Dir(configFiles[0])); err != nil {
+			log.Fatalf("failed to change directory: %v", err)
+		}
+		projectOpts := opts
+		projectOpts.configFile = configFiles[0]
+		projectOpts.recreate = false
+		up(projectOpts)
+	}
+}
+
+// projectRoot returns the git repository root containing dir, or dir itself outside a repository
+func projectRoot(dir string) (string, bool) {
+	out, err := exec.Command("git", "-C", dir, "rev-parse", "--show-toplevel").Output()
+	if err != nil {
+		return dir, false
+	}
+	return strings.TrimSpace(string(out)), true
+}
+
+// windowDirectory returns the directory a captured window is rooted in (its first pane's)
+func windowDirectory(window *WindowConfig) string {
+	if window.WorkingDirectory != "" {
+		return expandPath(window.WorkingDirectory)
+	}
+	for _, pane := range window.Panes {
+		if pane.WorkingDirectory != "" {
+			return expandPath(pane.WorkingDirectory)
+		}
+	}
+	return ""
+}
+
+// relativeToRoot rewrites a captured path inside a repository to a !git-root path
+func relativeToRoot(path string, root string) string {
+	rel, err := filepath.Rel(root, expandPath(path))
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return path
+	}
+	if rel == "." {
+		return gitRootPrefix
+	}
+	return gitRootPrefix + "/" + filepath.ToSlash(rel)
+}
+
+// splitConfigByProject groups the windows of a captured session by the project root
+// they are rooted in. It returns the root directories in order of first appearance.
+func splitConfigByProject(config *Config) ([]string, map[string]*Config) {
+	var roots []string
+	configs := make(map[string]*Config)
+
+	for _, window := range config.Session.Windows {
+		dir := windowDirectory(&window)
+		if dir == "" {
+			dir = "."
+		}
+		root, inRepo := projectRoot(dir)
+		if inRepo {
+			for i := range window.Panes {
+				if window.Panes[i].WorkingDirectory != "" {
+					window.Panes[i].WorkingDirectory = relativeToRoot(window.Panes[i].WorkingDirectory, root)
+				}
+			}
+		}
+
+		project, ok := configs[root]
+		if !ok {
+			project = &Config{Session: SessionConfig{Name: filepath.Base(root)}}
+			configs[root] = project
+			roots = append(roots, root)
+		}
+		project.Session.Windows = append(project.Session.Windows, window)
+	}
+	return roots, configs
+}
+
+// writeSplitConfigs writes one configuration per project root plus a workspace
+// configuration at workspacePath referencing them
+func writeSplitConfigs(config *Config, workspacePath string) error {
+	roots, configs := splitConfigByProject(config)
+	home, _ := os.UserHomeDir()
+
+	workspace := &Config{}
+	for _, root := range roots {
+		path := filepath.Join(root, ".gridlock.yaml")
+		if _, err := os.Stat(path); err == nil {
+			fmt.Printf("Keeping existing %s\n", path)
+		} else {
+			if err := writeConfig(path, configs[root]); err != nil {
+				return err
+			}
+			fmt.Printf("Wrote %s (%d windows)\n", path, len(configs[root].Session.Windows))
+		}
+
+		entry := root
+		if home != "" && strings.HasPrefix(root, home) {
+			entry = "~" + strings.TrimPrefix(root, home)
+		}
+		workspace.Projects = append(workspace.Projects, entry)
+	}
+
+	return writeConfig(workspacePath, workspace)
+}
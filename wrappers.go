@@ -0,0 +1,24 @@
+package main
+
+import (
+	"os"
+	"strings"
+)
+
+// captureWrappers returns the wrapper processes to look through when capturing pane commands:
+// the comma separated list given, GRIDLOCK_WRAPPERS, or nil for the defaults
+func captureWrappers(list string) []string {
+	if list == "" {
+		list = os.Getenv("GRIDLOCK_WRAPPERS")
+	}
+	if list == "" {
+		return nil
+	}
+	var wrappers []string
+	for _, name := range strings.Split(list, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			wrappers = append(wrappers, name)
+		}
+	}
+	return wrappers
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// zellij brings a configuration up in zellij: windows become tabs and each layout tree a
+// tree of panes in a KDL layout, which zellij creates the session from
+type zellij struct {
+	dryRun  bool
+	verbose bool
+}
+
+func (z *zellij) run(args ...string) (string, error) {
+	if z.verbose || z.dryRun {
+		fmt.Printf("zellij %s\n", strings.Join(quoteArgs(args), " "))
+	}
+	if z.dryRun {
+		return "", nil
+	}
+	out, err := exec.Command("zellij", args...).CombinedOutput()
+	if err != nil {
+		return string(out), fmt.Errorf("zellij %s: %v: %s", args[0], err, strings.TrimSpace(string(out)))
+	}
+	return string(out), nil
+}
+
+func (z *zellij) sessionExists(name string) bool {
+	out, err := exec.Command("zellij", "list-sessions", "--short", "--no-formatting").Output()
+	if err != nil {
+		return false
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		if strings.TrimSpace(line) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// create writes the layout of the session to the state directory, where it stays for
+// zellij to read, and starts the session from it in the background
+func (z *zellij) create(config *Config, layouts []LayoutNode) error {
+	fmt.Printf("Creating session: %s\n", config.Session.Name)
+	dir, err := stateDir()
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(dir, "zellij", slugify(config.Session.Name)+".kdl")
+	if !z.dryRun {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, []byte(zellijLayout(config, layouts)), 0644); err != nil {
+			return fmt.Errorf("failed to write layout: %v", err)
+		}
+	}
+	_, err = z.run("attach", "--create-background", config.Session.Name, "options", "--default-layout", path)
+	return err
+}
+
+func (z *zellij) attach(name string) error {
+	if z.dryRun {
+		_, err := z.run("attach", name)
+		return err
+	}
+	cmd := exec.Command("zellij", "attach", name)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// zellijLayout translates a configuration into a zellij KDL layout, a tab per window.
+// Columns and rows become panes split vertically and horizontally. A pane's environment,
+// commands and shell are run through sh, which ends in the pane's shell so it stays open.
+// Options only tmux has, such as hooks, styles and keep-open, are left out.
+func zellijLayout(config *Config, layouts []LayoutNode) string {
+	var b strings.Builder
+	b.WriteString("layout {\n")
+	// Without a template, zellij leaves out its tab and status bars
+	b.WriteString("    default_tab_template {\n")
+	b.WriteString("        pane size=1 borderless=true {\n            plugin location=\"zellij:tab-bar\"\n        }\n")
+	b.WriteString("        children\n")
+	b.WriteString("        pane size=2 borderless=true {\n            plugin location=\"zellij:status-bar\"\n        }\n")
+	b.WriteString("    }\n")
+	session := &config.Session
+	for i := range session.Windows {
+		window := &session.Windows[i]
+		attrs := "name=" + kdlString(window.Name)
+		if dir := expandPath(firstNonEmpty(window.WorkingDirectory, session.WorkingDirectory)); dir != "" {
+			attrs += " cwd=" + kdlString(dir)
+		}
+		if window.Focus {
+			attrs += " focus=true"
+		}
+		fmt.Fprintf(&b, "    tab %s {\n", attrs)
+		layout := layouts[i]
+		if layout.IsZero() && len(window.Panes) > 0 {
+			// Like with tmux, a window without a layout gets its first pane
+			layout = LayoutNode{PaneName: window.Panes[0].Name}
+		}
+		writeZellijPane(&b, layout, window, session.WorkingDirectory, 2)
+		b.WriteString("    }\n")
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// writeZellijPane writes the pane of a layout node at an indentation of depth levels
+func writeZellijPane(b *strings.Builder, node LayoutNode, window *WindowConfig, sessionWorkDir string, depth int) {
+	indent := strings.Repeat("    ", depth)
+	var attrs []string
+	if node.Size != "" {
+		if strings.HasSuffix(node.Size, "%") {
+			attrs = append(attrs, "size="+kdlString(node.Size))
+		} else {
+			attrs = append(attrs, "size="+strings.TrimSpace(node.Size))
+		}
+	}
+
+	children, direction := node.Columns, "vertical"
+	if len(node.Rows) > 0 {
+		children, direction = node.Rows, "horizontal"
+	}
+	if len(children) > 0 {
+		attrs = append(attrs, "split_direction="+kdlString(direction))
+		fmt.Fprintf(b, "%spane %s {\n", indent, strings.Join(attrs, " "))
+		for _, child := range children {
+			writeZellijPane(b, child, window, sessionWorkDir, depth+1)
+		}
+		fmt.Fprintf(b, "%s}\n", indent)
+		return
+	}
+
+	pane := findPane(window, node.PaneName)
+	if pane == nil {
+		fmt.Fprintf(b, "%spane %s\n", indent, strings.Join(attrs, " "))
+		return
+	}
+	attrs = append([]string{"name=" + kdlString(pane.Name)}, attrs...)
+	if dir := getWorkDirForNode(&node, window, sessionWorkDir); dir != "" {
+		attrs = append(attrs, "cwd="+kdlString(dir))
+	}
+	if pane.Focus {
+		attrs = append(attrs, "focus=true")
+	}
+	script := paneScript(pane)
+	if script == "" {
+		fmt.Fprintf(b, "%spane %s\n", indent, strings.Join(attrs, " "))
+		return
+	}
+	attrs = append(attrs, "command=\"sh\"")
+	fmt.Fprintf(b, "%spane %s {\n", indent, strings.Join(attrs, " "))
+	fmt.Fprintf(b, "%s    args \"-c\" %s\n", indent, kdlString(script))
+	fmt.Fprintf(b, "%s}\n", indent)
+}
+
+// kdlString quotes a string for KDL
+func kdlString(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`, "\r", `\r`)
+	return `"` + r.Replace(s) + `"`
+}